@@ -5,43 +5,98 @@ import (
 	"call-center-api/pkg/config"
 	"call-center-api/pkg/database"
 	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/middleware"
+	"call-center-api/pkg/tracing"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
-	logger.Init()
-
 	cfg := config.Load()
+	logger.Init("callcenter-api", cfg.LogLevel, cfg.LogFormat)
+
+	// Tracing stays a no-op unless an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Init(context.Background(), "callcenter-api", cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", "error", err)
+	}
+
+	// Initialize database
+	db, err := database.NewPostgres(
+		cfg.DBHost,
+		cfg.DBUser,
+		cfg.DBPassword,
+		cfg.DBName,
+		cfg.DBPort,
+	)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+	slog.Info("Connected to PostgreSQL")
+
+	// Initialize Redis for call flood tracking
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       0,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		logger.Fatal("Failed to connect to Redis", "error", err)
+	}
+	slog.Info("Connected to Redis")
 
 	// Initialize Kafka producer
 	brokers := strings.Split(cfg.KafkaBrokers, ",")
 	kafkaProducer, err := database.NewKafkaProducer(brokers, "incoming_calls")
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to create Kafka producer: %v", err)
+		logger.Fatal("Failed to create Kafka producer", "error", err)
 	}
 
 	// Initialize service
-	service := callcenter.NewCallCenterService(kafkaProducer)
+	service := callcenter.NewCallCenterService(kafkaProducer, db, rdb, cfg.CallFloodLimit, cfg.CallFloodBatchLimit, cfg.CallFloodWindow)
+	service.SetCallbackPolicy(cfg.CallbackMaxAttempts, cfg.CallbackRetryDelay)
 
 	// Initialize handler
-	handler := callcenter.NewCallCenterHandler(service)
+	handler := callcenter.NewCallCenterHandler(service, cfg.PhoneDefaultRegion)
+
+	// Load webhook mapping profiles
+	profiles, err := callcenter.LoadWebhookProfiles(cfg.WebhookProfilesFile, cfg.WebhookSecret)
+	if err != nil {
+		logger.Fatal("Failed to load webhook profiles", "error", err)
+	}
+	handler.SetWebhookProfiles(profiles, cfg.WebhookReplayWindow)
 
 	// Create Fiber app
+	// Bodies over the default limit are streamed rather than refused, so batch ingestion can
+	// read large NDJSON uploads incrementally; BodyLimit holds every other route to the default.
 	app := fiber.New(fiber.Config{
-		AppName: "Call Center API",
+		AppName:           "Call Center API",
+		StreamRequestBody: true,
 	})
 
+	// Request metrics, registered first so every route is timed
+	app.Use(metrics.Middleware())
+
+	// Correlation ID for logs and downstream Kafka messages
+	app.Use(middleware.RequestID())
+
+	// Default body limit everywhere except batch ingestion, which caps its own stream
+	app.Use(middleware.BodyLimit(fiber.DefaultBodyLimit, "/api/v1/calls/batch"))
+
 	// CORS middleware
 	app.Use(func(c *fiber.Ctx) error {
 		c.Set("Access-Control-Allow-Origin", "*")
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(200)
 		}
@@ -49,35 +104,71 @@ func main() {
 	})
 
 	// Setup routes
-	setupRoutes(app, handler)
+	setupRoutes(app, handler, service)
+
+	// Start callback scheduler in background
+	ctx, cancel := context.WithCancel(context.Background())
+	go service.StartCallbackScheduler(ctx)
 
 	// Start server
 	go func() {
 		port := fmt.Sprintf(":%s", cfg.CallCenterPort)
 		if err := app.Listen(port); err != nil {
-			logger.ErrorLogger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
-	logger.InfoLogger.Printf("Call Center API started on port %s", cfg.CallCenterPort)
+	slog.Info("Call Center API started", "port", cfg.CallCenterPort)
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.InfoLogger.Println("Shutting down Call Center API...")
+	slog.Info("Shutting down Call Center API")
+	cancel()
 	kafkaProducer.Close()
+	rdb.Close()
 	app.Shutdown()
+	// Flush buffered spans
+	shutdownTracing(context.Background())
 }
 
-func setupRoutes(app *fiber.App, handler *callcenter.CallCenterHandler) {
-	v1 := app.Group("/api/v1")
+func setupRoutes(app *fiber.App, handler *callcenter.CallCenterHandler, service callcenter.CallCenterService) {
+	// Admin routes (protected)
+	admin := app.Group("/api/v1/admin", middleware.AuthMiddleware(), middleware.AdminOnly())
+	{
+		admin.Post("/api-keys", handler.CreateAPIKey)
+		admin.Get("/api-keys", handler.ListAPIKeys)
+		admin.Delete("/api-keys/:id", handler.RevokeAPIKey)
+		admin.Post("/blocklist", handler.CreateBlockedNumber)
+		admin.Get("/blocklist", handler.ListBlockedNumbers)
+		admin.Delete("/blocklist/:id", handler.DeleteBlockedNumber)
+		admin.Get("/blocked-calls/stats", handler.GetBlockedCallStats)
+		admin.Get("/callbacks", handler.ListCallbacks)
+	}
 
-	v1.Post("/calls", handler.CreateCall)
+	// Provider webhooks (authenticated by HMAC signature)
+	app.Post("/api/v1/webhooks/:provider", handler.ReceiveWebhook)
+
+	// Integration routes (protected by API key)
+	v1 := app.Group("/api/v1", middleware.APIKeyMiddleware(service))
+	{
+		v1.Post("/calls", handler.CreateCall)
+		v1.Post("/calls/batch", handler.CreateCallBatch)
+		v1.Get("/calls/:id", handler.GetCallStatus)
+		v1.Get("/calls/:id/timeline", handler.GetCallTimeline)
+		v1.Delete("/calls/:id", handler.AbandonCall)
+		v1.Post("/callbacks", handler.CreateCallback)
+		v1.Get("/callbacks/:id", handler.GetCallback)
+		v1.Delete("/callbacks/:id", handler.CancelCallback)
+	}
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
+
+	// Prometheus scrape endpoint
+	app.Get("/metrics", metrics.Handler())
 }
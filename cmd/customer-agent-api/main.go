@@ -1,12 +1,21 @@
 package main
 
 import (
+	"call-center-api/internal/alerts"
 	"call-center-api/internal/customeragent"
+	"call-center-api/internal/reporting"
+	"call-center-api/internal/routing"
+	"call-center-api/internal/wallboard"
+	"call-center-api/internal/webhooks"
 	"call-center-api/pkg/config"
 	"call-center-api/pkg/database"
 	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
 	"call-center-api/pkg/middleware"
+	"call-center-api/pkg/tracing"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,9 +26,14 @@ import (
 )
 
 func main() {
-	logger.Init()
-
 	cfg := config.Load()
+	logger.Init("customer-agent-api", cfg.LogLevel, cfg.LogFormat)
+
+	// Tracing stays a no-op unless an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Init(context.Background(), "customer-agent-api", cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", "error", err)
+	}
 
 	// Initialize database
 	db, err := database.NewPostgres(
@@ -30,36 +44,114 @@ func main() {
 		cfg.DBPort,
 	)
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to connect to database: %v", err)
+		logger.Fatal("Failed to connect to database", "error", err)
 	}
-	logger.InfoLogger.Println("Connected to PostgreSQL")
+	slog.Info("Connected to PostgreSQL")
 
 	// Initialize Kafka producer for agent changes
 	brokers := []string{cfg.KafkaBrokers}
 	kafkaProducer, err := database.NewKafkaProducer(brokers, "agent_changes")
 	if err != nil {
-		logger.ErrorLogger.Printf("Warning: Failed to create Kafka producer: %v", err)
+		slog.Warn("Failed to create Kafka producer", "error", err)
 		kafkaProducer = nil // Continue without Kafka
 	} else {
-		logger.InfoLogger.Println("Connected to Kafka producer")
+		slog.Info("Connected to Kafka producer")
 	}
 
 	// Initialize service
 	service := customeragent.NewAgentService(db)
+	service.SetKafkaProducer(kafkaProducer)
 
 	// Initialize handler
 	handler := customeragent.NewAgentHandler(service, db, kafkaProducer)
 
+	// Initialize outbound webhooks, fed from the call and agent lifecycle topics
+	webhookConsumer, err := database.NewKafkaConsumer(brokers, "assigned_calls", "webhook-dispatcher")
+	if err != nil {
+		slog.Warn("Failed to create webhook consumer", "error", err)
+		webhookConsumer = nil // Continue without lifecycle events
+	}
+	webhookService := webhooks.NewWebhookService(db, webhookConsumer, cfg.OutboundWebhookTimeout, cfg.OutboundWebhookMaxAttempts)
+	webhookHandler := webhooks.NewWebhookHandler(webhookService)
+
+	// Initialize routing configuration (business hours, holidays)
+	routingHandler := routing.NewRoutingHandler(routing.NewRoutingService(db))
+
+	// Initialize SLA and max wait alerts, published to the alerts topic and as webhooks
+	alertProducer, err := database.NewKafkaProducer(brokers, "alerts")
+	if err != nil {
+		slog.Warn("Failed to create alert producer", "error", err)
+		alertProducer = nil // Alerts are still recorded and sent as webhooks
+	}
+	alertService := alerts.NewAlertService(db, alertProducer, webhookService, cfg.AlertFireAfter, cfg.AlertResolveAfter)
+	alertHandler := alerts.NewAlertHandler(alertService)
+
+	// Initialize historical reporting over interval rollups
+	reportingService := reporting.NewReportingService(db)
+	reportingHandler := reporting.NewReportingHandler(reportingService)
+
+	// Initialize the supervisor wallboard. Every instance needs every new event, so each
+	// tails all partitions itself instead of joining a consumer group.
+	wallboardConsumer, err := database.NewKafkaTail(brokers, "wallboard", "incoming_calls", "assigned_calls", "agent_changes", "alerts")
+	if err != nil {
+		slog.Warn("Failed to create wallboard consumer", "error", err)
+		wallboardConsumer = nil // The wallboard still refreshes from Postgres
+	}
+	wallboardService := wallboard.NewWallboardService(db, wallboardConsumer)
+	wallboardHandler := wallboard.NewWallboardHandler(wallboardService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if webhookConsumer != nil {
+		go func() {
+			if err := webhookService.StartConsumer(ctx); err != nil {
+				slog.Error("Webhook consumer stopped", "error", err)
+			}
+		}()
+	}
+	go webhookService.StartDispatcher(ctx)
+
+	if wallboardConsumer != nil {
+		go func() {
+			if err := wallboardService.StartConsumer(ctx); err != nil {
+				slog.Error("Wallboard consumer stopped", "error", err)
+			}
+		}()
+	}
+	go wallboardService.StartReconciler(ctx)
+	go wallboardService.StartPublisher(ctx)
+
+	go alertService.StartEvaluator(ctx)
+
+	// Keep recent interval rollups current as calls finish
+	go reportingService.StartRollup(ctx, cfg.RollupInterval, cfg.RollupLookback)
+
+	// Return agents to available when wrap-up or a capped aux code expires
+	if err := service.BackfillStateHistory(); err != nil {
+		slog.Error("Failed to backfill agent state history", "error", err)
+	}
+	go service.StartStateExpiry(ctx)
+
+	// End expired sessions and sessions whose WebSocket did not come back
+	go service.StartSessionSweeper(ctx, cfg.SessionDisconnectGrace)
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName: "Customer Agent API",
 	})
 
+	// Request metrics, registered first so every route is timed
+	app.Use(metrics.Middleware())
+
+	// Correlation ID for logs and downstream Kafka messages
+	app.Use(middleware.RequestID())
+
 	// CORS middleware
 	app.Use(func(c *fiber.Ctx) error {
 		c.Set("Access-Control-Allow-Origin", "*")
 		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(200)
 		}
@@ -67,50 +159,115 @@ func main() {
 	})
 
 	// Setup routes
-	setupRoutes(app, handler)
+	setupRoutes(app, handler, service, webhookHandler, routingHandler, wallboardHandler, reportingHandler, alertHandler)
 
 	go func() {
 		port := fmt.Sprintf(":%s", cfg.CustomerAgentPort)
 		if err := app.Listen(port); err != nil {
-			logger.ErrorLogger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
-	logger.InfoLogger.Printf("Customer Agent API started on port %s", cfg.CustomerAgentPort)
+	slog.Info("Customer Agent API started", "port", cfg.CustomerAgentPort)
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.InfoLogger.Println("Shutting down Customer Agent API...")
+	slog.Info("Shutting down Customer Agent API")
+	cancel()
+	if webhookConsumer != nil {
+		webhookConsumer.Close()
+	}
+	if wallboardConsumer != nil {
+		wallboardConsumer.Close()
+	}
 	if kafkaProducer != nil {
 		kafkaProducer.Close()
 	}
+	if alertProducer != nil {
+		alertProducer.Close()
+	}
 	app.Shutdown()
+	// Flush buffered spans
+	shutdownTracing(context.Background())
 }
 
-func setupRoutes(app *fiber.App, handler *customeragent.AgentHandler) {
+func setupRoutes(app *fiber.App, handler *customeragent.AgentHandler, service customeragent.AgentService, webhookHandler *webhooks.WebhookHandler, routingHandler *routing.RoutingHandler, wallboardHandler *wallboard.WallboardHandler, reportingHandler *reporting.ReportingHandler, alertHandler *alerts.AlertHandler) {
 	// Public routes
 	app.Post("/api/v1/admin/register", handler.RegisterAdmin)
 	app.Post("/api/v1/admin/login", handler.AdminLogin)
 	app.Post("/api/v1/auth/login", handler.Login)
 
 	// Protected routes (for agents)
-	v1 := app.Group("/api/v1", middleware.AuthMiddleware())
+	v1 := app.Group("/api/v1", middleware.AuthMiddleware(), middleware.ActiveSession(service))
 	{
+		v1.Post("/auth/logout", handler.Logout)
 		v1.Get("/calls", handler.GetCalls)
 		v1.Post("/calls/:id/complete", handler.CompleteCall)
+		v1.Get("/me/state", handler.GetState)
+		v1.Put("/me/state", handler.SetState)
+		v1.Get("/aux-codes", handler.ListAuxCodes)
+		v1.Post("/me/wrap-up/end", handler.EndWrapUp)
 	}
 
 	// Admin routes (protected)
-	admin := app.Group("/api/v1", middleware.AuthMiddleware())
+	admin := app.Group("/api/v1", middleware.AuthMiddleware(), middleware.ActiveSession(service))
 	{
 		admin.Post("/agents", handler.CreateAgent)
 		admin.Get("/agents/stats", handler.GetAgentStats)
 		admin.Delete("/agents/:id", handler.DeleteAgent)
 	}
 
+	// Outbound webhook administration (admin only)
+	hooks := app.Group("/api/v1/admin/webhooks", middleware.AuthMiddleware(), middleware.AdminOnly())
+	{
+		hooks.Post("/", webhookHandler.CreateSubscription)
+		hooks.Get("/", webhookHandler.ListSubscriptions)
+		hooks.Put("/:id", webhookHandler.UpdateSubscription)
+		hooks.Delete("/:id", webhookHandler.DeleteSubscription)
+		hooks.Get("/deliveries", webhookHandler.ListDeliveries)
+		hooks.Post("/deliveries/:id/redeliver", webhookHandler.Redeliver)
+	}
+
+	// Routing configuration (admin only)
+	routes := app.Group("/api/v1/admin", middleware.AuthMiddleware(), middleware.AdminOnly())
+	{
+		routes.Get("/business-hours", routingHandler.ListBusinessHours)
+		routes.Put("/business-hours/:queue", routingHandler.SaveBusinessHours)
+		routes.Delete("/business-hours/:queue", routingHandler.DeleteBusinessHours)
+		routes.Get("/business-hours/:queue/state", routingHandler.GetOpenState)
+		routes.Get("/holidays", routingHandler.ListHolidays)
+		routes.Post("/holidays", routingHandler.CreateHoliday)
+		routes.Delete("/holidays/:id", routingHandler.DeleteHoliday)
+		routes.Get("/queues", routingHandler.ListQueues)
+		routes.Post("/queues", routingHandler.CreateQueue)
+		routes.Get("/queues/:name", routingHandler.GetQueue)
+		routes.Put("/queues/:name", routingHandler.UpdateQueue)
+		routes.Delete("/queues/:name", routingHandler.DeleteQueue)
+		routes.Get("/queues/:name/members", routingHandler.ListMembers)
+		routes.Put("/queues/:name/members/:agent_id", routingHandler.SetMembership)
+		routes.Delete("/queues/:name/members/:agent_id", routingHandler.RemoveMembership)
+		routes.Get("/agents/:id/queues", routingHandler.ListAgentQueues)
+		routes.Put("/agents/:id/capacity", handler.SetAgentCapacity)
+		routes.Put("/agents/:id/team", handler.SetAgentTeam)
+		routes.Get("/agents/state-report", handler.GetAgentStateReport)
+		routes.Get("/agents/sessions", handler.ListSessions)
+		routes.Get("/aux-codes", handler.ListAuxCodes)
+		routes.Post("/aux-codes", handler.CreateAuxCode)
+		routes.Delete("/aux-codes/:id", handler.DeleteAuxCode)
+		routes.Get("/queues/:name/overflow-rules", routingHandler.ListOverflowRules)
+		routes.Post("/queues/:name/overflow-rules", routingHandler.CreateOverflowRule)
+		routes.Delete("/overflow-rules/:id", routingHandler.DeleteOverflowRule)
+		routes.Get("/overflow/stats", routingHandler.GetOverflowStats)
+		routes.Get("/wallboard", wallboardHandler.GetWallboard)
+		routes.Get("/reports/intervals", reportingHandler.GetIntervalReport)
+		routes.Post("/reports/backfill", reportingHandler.Backfill)
+		routes.Get("/forecast", reportingHandler.GetForecast)
+		routes.Get("/alerts", alertHandler.ListAlerts)
+	}
+
 	// WebSocket route - needs special handling for auth
 	app.Get("/ws/assigned", func(c *fiber.Ctx) error {
 		// Check if this is a WebSocket upgrade request
@@ -145,14 +302,57 @@ func setupRoutes(app *fiber.App, handler *customeragent.AgentHandler) {
 			})
 		}
 
+		// A token outlives its session after logout or a long disconnect
+		if claims.SessionID == "" || !service.SessionActive(claims.SessionID) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Session ended",
+			})
+		}
+
 		// Upgrade to WebSocket
 		return websocket.New(func(conn *websocket.Conn) {
-			handler.WebSocketHandler(conn, claims.AgentID)
+			handler.WebSocketHandler(conn, claims.AgentID, claims.SessionID)
 		})(c)
 	})
 
+	// Supervisor wallboard stream - admin token in the query string like /ws/assigned
+	app.Get("/ws/wallboard", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return c.Status(fiber.StatusUpgradeRequired).SendString("WebSocket upgrade required")
+		}
+
+		token := c.Query("token")
+		if token == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Token required",
+			})
+		}
+
+		cfg := config.Load()
+		parsedToken, err := jwt.ParseWithClaims(token, &middleware.Claims{}, func(token *jwt.Token) (interface{}, error) {
+			return []byte(cfg.JWTSecret), nil
+		})
+		if err != nil || !parsedToken.Valid {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid token",
+			})
+		}
+
+		claims, ok := parsedToken.Claims.(*middleware.Claims)
+		if !ok || claims.AgentID != "admin" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Admin access required",
+			})
+		}
+
+		return websocket.New(wallboardHandler.WebSocketHandler)(c)
+	})
+
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
+
+	// Prometheus scrape endpoint
+	app.Get("/metrics", metrics.Handler())
 }
@@ -2,12 +2,17 @@ package main
 
 import (
 	"call-center-api/internal/distributor"
+	"call-center-api/internal/routing"
 	"call-center-api/models"
 	"call-center-api/pkg/config"
 	"call-center-api/pkg/database"
 	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/middleware"
+	"call-center-api/pkg/tracing"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
@@ -19,9 +24,14 @@ import (
 )
 
 func main() {
-	logger.Init()
-
 	cfg := config.Load()
+	logger.Init("distributor", cfg.LogLevel, cfg.LogFormat)
+
+	// Tracing stays a no-op unless an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Init(context.Background(), "distributor", cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", "error", err)
+	}
 
 	// Initialize Redis
 	rdb := redis.NewClient(&redis.Options{
@@ -33,9 +43,9 @@ func main() {
 	// Test Redis connection
 	ctx := context.Background()
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		logger.ErrorLogger.Fatalf("Failed to connect to Redis: %v", err)
+		logger.Fatal("Failed to connect to Redis", "error", err)
 	}
-	logger.InfoLogger.Println("Connected to Redis")
+	slog.Info("Connected to Redis")
 
 	// Initialize database
 	db, err := database.NewPostgres(
@@ -46,33 +56,39 @@ func main() {
 		cfg.DBPort,
 	)
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to connect to database: %v", err)
+		logger.Fatal("Failed to connect to database", "error", err)
 	}
-	logger.InfoLogger.Println("Connected to PostgreSQL")
+	slog.Info("Connected to PostgreSQL")
 
 	// Sync active agents from PostgreSQL to Redis
 	if err := syncAgentsToRedis(ctx, db, rdb); err != nil {
-		logger.ErrorLogger.Fatalf("Failed to sync agents to Redis: %v", err)
+		logger.Fatal("Failed to sync agents to Redis", "error", err)
 	}
-	logger.InfoLogger.Println("Synced agents to Redis")
+	slog.Info("Synced agents to Redis")
 
 	// Initialize Kafka consumer for incoming_calls
 	brokers := strings.Split(cfg.KafkaBrokers, ",")
 	kafkaConsumer, err := database.NewKafkaConsumer(brokers, "incoming_calls", cfg.KafkaGroupID)
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to create Kafka consumer: %v", err)
+		logger.Fatal("Failed to create Kafka consumer", "error", err)
 	}
 
 	// Initialize Kafka consumer for agent_changes
 	agentChangeConsumer, err := database.NewKafkaConsumer(brokers, "agent_changes", "distributor-agent-sync")
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to create agent change consumer: %v", err)
+		logger.Fatal("Failed to create agent change consumer", "error", err)
+	}
+
+	// Initialize Kafka consumer for call outcomes, which release agent capacity
+	callEventConsumer, err := database.NewKafkaConsumer(brokers, "assigned_calls", "distributor-capacity")
+	if err != nil {
+		logger.Fatal("Failed to create call event consumer", "error", err)
 	}
 
 	// Initialize Kafka producer for assigned_calls
 	kafkaProducer, err := database.NewKafkaProducer(brokers, "assigned_calls")
 	if err != nil {
-		logger.ErrorLogger.Fatalf("Failed to create Kafka producer: %v", err)
+		logger.Fatal("Failed to create Kafka producer", "error", err)
 	}
 
 	// Initialize service
@@ -81,24 +97,49 @@ func main() {
 	// Set agent change consumer
 	service.SetAgentChangeConsumer(agentChangeConsumer)
 
+	// Enable sticky routing to the caller's last agent
+	service.SetAffinityWindow(cfg.AffinityWindow)
+
+	// Limit simultaneous calls per agent
+	service.SetDefaultCapacity(cfg.DefaultAgentCapacity)
+	service.SetCallEventConsumer(callEventConsumer)
+
+	// Enforce business hours, holidays and queue membership
+	service.SetRoutingService(routing.NewRoutingService(db))
+
 	// Initialize handler
 	handler := distributor.NewDistributorHandler(service)
 
-	// Create Fiber app for health checks
+	// Create Fiber app for health checks and metrics
 	app := fiber.New()
+	app.Use(metrics.Middleware())
+	app.Use(middleware.RequestID())
 	app.Get("/health", handler.HealthCheck)
+	app.Get("/status", handler.Status)
+	app.Get("/metrics", metrics.Handler())
 
 	// Start distributor for incoming calls in background
 	go func() {
 		if err := service.Start(ctx); err != nil {
-			logger.ErrorLogger.Fatalf("Distributor stopped: %v", err)
+			logger.Fatal("Distributor stopped", "error", err)
 		}
 	}()
 
+	// Serve waiting calls as agents become free
+	go service.StartDispatcher(ctx)
+
+	// Release capacity as calls finish, and periodically recount it from Postgres
+	go func() {
+		if err := service.StartCapacityConsumer(ctx); err != nil {
+			slog.Error("Capacity consumer stopped", "error", err)
+		}
+	}()
+	go service.StartReconciler(ctx, cfg.CapacityReconcileInterval)
+
 	// Start agent change consumer in background
 	go func() {
 		if err := service.StartAgentChangeConsumer(ctx); err != nil {
-			logger.ErrorLogger.Printf("Agent change consumer stopped: %v", err)
+			slog.Error("Agent change consumer stopped", "error", err)
 		}
 	}()
 
@@ -106,44 +147,47 @@ func main() {
 	go func() {
 		port := fmt.Sprintf(":%s", cfg.DistributorPort)
 		if err := app.Listen(port); err != nil {
-			logger.ErrorLogger.Fatalf("Failed to start server: %v", err)
+			logger.Fatal("Failed to start server", "error", err)
 		}
 	}()
 
-	logger.InfoLogger.Printf("Distributor started on port %s", cfg.DistributorPort)
+	slog.Info("Distributor started", "port", cfg.DistributorPort)
 
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	logger.InfoLogger.Println("Shutting down Distributor...")
+	slog.Info("Shutting down Distributor")
 	kafkaConsumer.Close()
 	agentChangeConsumer.Close()
+	callEventConsumer.Close()
 	kafkaProducer.Close()
 	rdb.Close()
 	app.Shutdown()
+	// Flush buffered spans
+	shutdownTracing(context.Background())
 }
 
 // syncAgentsToRedis reads all active agents from PostgreSQL and populates Redis
 func syncAgentsToRedis(ctx context.Context, db *gorm.DB, rdb *redis.Client) error {
-	// Fetch all active agents from database
+	// Fetch active agents who are signed in; the rest join once they log in
 	var agents []models.Agent
-	if err := db.Where("is_active = ?", true).Find(&agents).Error; err != nil {
+	if err := db.Where("is_active = ?", true).Scopes(models.LiveSession).Find(&agents).Error; err != nil {
 		return fmt.Errorf("failed to fetch agents: %w", err)
 	}
 
-	if len(agents) == 0 {
-		logger.InfoLogger.Println("No active agents found in database")
-		return nil
-	}
-
 	// Clear existing Redis list
 	if err := rdb.Del(ctx, "available_agents").Err(); err != nil {
 		return fmt.Errorf("failed to clear Redis list: %w", err)
 	}
 
-	// Add all active agents to Redis
+	if len(agents) == 0 {
+		slog.Info("No signed-in agents found in database")
+		return nil
+	}
+
+	// Add all signed-in agents to Redis
 	agentIDs := make([]interface{}, len(agents))
 	for i, agent := range agents {
 		agentIDs[i] = agent.ID
@@ -153,6 +197,6 @@ func syncAgentsToRedis(ctx context.Context, db *gorm.DB, rdb *redis.Client) erro
 		return fmt.Errorf("failed to push agents to Redis: %w", err)
 	}
 
-	logger.InfoLogger.Printf("Synced %d active agents to Redis: %v", len(agents), agentIDs)
+	slog.Info("Synced signed-in agents to Redis", "count", len(agents), "agent_ids", agentIDs)
 	return nil
 }
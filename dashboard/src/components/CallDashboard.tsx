@@ -46,6 +46,8 @@ export default function CallDashboard({ agent, onLogout }: CallDashboardProps) {
           setCalls((prev) => [data.data, ...prev])
           // Play notification sound
           new Audio('data:audio/wav;base64,UklGRnoGAABXQVZFZm10IBAAAAABAAEAQB8AAEAfAAABAAgAZGF0YQoGAACBhYqFbF1fdJivrJBhNjVgodDbq2EcBj+a2/LDciUFLIHO8tiJNwgZaLvt559NEAxQp+PwtmMcBjiR1/LMeSwFJHfH8N2QQAoUXrTp66hVFApGn+DyvmwhBSuBzvLbiTYIGWe77+ekUxAKUp/h8bJoGAY6k9bzy3osBip+zPPZeDAFLnvM8+OIQfM=').play()
+        } else if (data.type === 'call_retracted' && data.data) {
+          setCalls((prev) => prev.map((call) => call.call_id === data.data.call_id ? { ...call, status: data.data.status } : call))
         }
       } catch (err) {
         console.error('Failed to parse WebSocket message:', err)
@@ -0,0 +1,37 @@
+package alerts
+
+import (
+	"call-center-api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlertHandler struct {
+	service AlertService
+}
+
+func NewAlertHandler(service AlertService) *AlertHandler {
+	return &AlertHandler{service: service}
+}
+
+// ListAlerts lists alerts by status: open (default), resolved or all
+func (h *AlertHandler) ListAlerts(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	alerts, err := h.service.ListAlerts(c.Query("status"), limit)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch alerts",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    alerts,
+	})
+}
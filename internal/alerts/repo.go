@@ -0,0 +1,6 @@
+package alerts
+
+// Repository interface for alerts
+type Repository interface {
+	// Add any database operations here if needed in future
+}
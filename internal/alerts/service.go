@@ -0,0 +1,342 @@
+package alerts
+
+import (
+	"call-center-api/internal/webhooks"
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"call-center-api/pkg/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// evalInterval is how often SLA and wait thresholds are checked
+	evalInterval = 10 * time.Second
+	// minSLASample is the fewest calls in a window before service level can breach,
+	// so one early abandon does not page anyone
+	minSLASample = 5
+	// waitingHorizon bounds how far back a call can still be considered waiting
+	waitingHorizon = 24 * time.Hour
+)
+
+type AlertService interface {
+	ListAlerts(status string, limit int) ([]models.Alert, error)
+	StartEvaluator(ctx context.Context)
+}
+
+type alertService struct {
+	db           *gorm.DB
+	producer     *database.KafkaProducer
+	webhooks     webhooks.WebhookService
+	fireAfter    time.Duration
+	resolveAfter time.Duration
+
+	// Debounce state for SLA breaches, by alert key
+	breachingSince map[string]time.Time
+	clearSince     map[string]time.Time
+}
+
+// NewAlertService publishes alerts to producer (the alerts topic) and as webhook events.
+// An SLA breach fires once it has lasted fireAfter and resolves once service level has been
+// back on target for resolveAfter.
+func NewAlertService(db *gorm.DB, producer *database.KafkaProducer, webhookService webhooks.WebhookService, fireAfter, resolveAfter time.Duration) AlertService {
+	return &alertService{
+		db:             db,
+		producer:       producer,
+		webhooks:       webhookService,
+		fireAfter:      fireAfter,
+		resolveAfter:   resolveAfter,
+		breachingSince: map[string]time.Time{},
+		clearSince:     map[string]time.Time{},
+	}
+}
+
+// ListAlerts returns open, resolved or all alerts, newest first
+func (s *alertService) ListAlerts(status string, limit int) ([]models.Alert, error) {
+	query := s.db.Order("fired_at DESC").Limit(limit)
+	switch status {
+	case "", "open":
+		query = query.Where("resolved_at IS NULL")
+	case "resolved":
+		query = query.Where("resolved_at IS NOT NULL")
+	case "all":
+	default:
+		return nil, fmt.Errorf("status must be open, resolved or all")
+	}
+
+	var alerts []models.Alert
+	if err := query.Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// StartEvaluator checks thresholds every evalInterval until ctx is canceled
+func (s *alertService) StartEvaluator(ctx context.Context) {
+	ticker := time.NewTicker(evalInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.evaluate(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to evaluate alerts", "error", err)
+			}
+		}
+	}
+}
+
+// evaluate fires alerts for current breaches and resolves open alerts whose condition cleared
+func (s *alertService) evaluate(ctx context.Context) error {
+	now := time.Now()
+
+	breaches, err := s.slaBreaches()
+	if err != nil {
+		return err
+	}
+	waits, err := s.longWaits(now)
+	if err != nil {
+		return err
+	}
+
+	var open []models.Alert
+	if err := s.db.Where("resolved_at IS NULL").Find(&open).Error; err != nil {
+		return err
+	}
+	openByKey := make(map[string]models.Alert, len(open))
+	for _, alert := range open {
+		openByKey[alert.Key] = alert
+	}
+
+	for key, alert := range breaches {
+		delete(s.clearSince, key)
+		since, ok := s.breachingSince[key]
+		if !ok {
+			since = now
+			s.breachingSince[key] = now
+		}
+		if _, firing := openByKey[key]; !firing && now.Sub(since) >= s.fireAfter {
+			s.fire(ctx, alert)
+		}
+	}
+	for key := range s.breachingSince {
+		if _, ok := breaches[key]; !ok {
+			delete(s.breachingSince, key)
+		}
+	}
+
+	// A call's wait only grows until it leaves the queue, so these fire at once
+	for key, alert := range waits {
+		if _, firing := openByKey[key]; !firing {
+			s.fire(ctx, alert)
+		}
+	}
+
+	for key, alert := range openByKey {
+		switch alert.Type {
+		case models.AlertTypeSLABreach:
+			if _, ok := breaches[key]; ok {
+				continue
+			}
+			since, ok := s.clearSince[key]
+			if !ok {
+				s.clearSince[key] = now
+				continue
+			}
+			if now.Sub(since) >= s.resolveAfter {
+				delete(s.clearSince, key)
+				s.resolve(ctx, alert)
+			}
+		case models.AlertTypeMaxWait:
+			if _, ok := waits[key]; !ok {
+				s.resolve(ctx, alert)
+			}
+		}
+	}
+
+	return nil
+}
+
+// slaBreaches computes each queue's service level over its window from call lifecycle
+// timestamps: answered calls count at assignment, abandoned calls when the caller hung up
+func (s *alertService) slaBreaches() (map[string]models.Alert, error) {
+	var queues []models.Queue
+	if err := s.db.Find(&queues).Error; err != nil {
+		return nil, err
+	}
+	byName := map[string]models.Queue{
+		// Calls without a queues row follow the column defaults
+		models.DefaultQueue: {Name: models.DefaultQueue, SLATargetSeconds: 20, SLATargetPercent: 80, SLAWindowMinutes: 30},
+	}
+	for _, queue := range queues {
+		byName[queue.Name] = queue
+	}
+
+	var rows []struct {
+		Queue            string
+		Answered         int64
+		AnsweredInTarget int64
+		Abandoned        int64
+	}
+	err := s.db.Raw(`
+		SELECT COALESCE(NULLIF(ac.queue, ''), @default_queue) AS queue,
+			COUNT(*) FILTER (WHERE ac.status NOT IN @unanswered) AS answered,
+			COUNT(*) FILTER (WHERE ac.status NOT IN @unanswered
+				AND EXTRACT(EPOCH FROM ac.created_at - ac.received_at) <= COALESCE(q.sla_target_seconds, 20)) AS answered_in_target,
+			COUNT(*) FILTER (WHERE ac.status = @abandoned) AS abandoned
+		FROM assigned_calls AS ac
+		LEFT JOIN queues AS q ON q.name = ac.queue
+		WHERE ac.deleted_at IS NULL
+			AND (ac.assigned_agent_id <> '' OR ac.status = @abandoned)
+			AND COALESCE(ac.abandoned_at, ac.created_at) >= NOW() - make_interval(mins => COALESCE(q.sla_window_minutes, 30))
+		GROUP BY 1`,
+		map[string]interface{}{
+			"default_queue": models.DefaultQueue,
+			"abandoned":     models.CallStatusAbandoned,
+			"unanswered":    []string{models.CallStatusAbandoned, models.CallStatusMissed},
+		},
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	breaches := map[string]models.Alert{}
+	for _, row := range rows {
+		total := row.Answered + row.Abandoned
+		queue, ok := byName[row.Queue]
+		if !ok || total < minSLASample {
+			continue
+		}
+		level := float64(row.AnsweredInTarget) / float64(total)
+		target := queue.SLATargetPercent / 100
+		if level >= target {
+			continue
+		}
+
+		key := models.AlertTypeSLABreach + ":" + queue.Name
+		breaches[key] = models.Alert{
+			Key:       key,
+			Type:      models.AlertTypeSLABreach,
+			Queue:     queue.Name,
+			Value:     level,
+			Threshold: target,
+			Message: fmt.Sprintf("Queue %s answered %.0f%% of calls within %ds over the last %d minutes (target %.0f%%)",
+				queue.Name, level*100, queue.SLATargetSeconds, queue.SLAWindowMinutes, queue.SLATargetPercent),
+		}
+	}
+	return breaches, nil
+}
+
+// longWaits finds queued calls that have waited past their queue's max_wait_seconds
+func (s *alertService) longWaits(now time.Time) (map[string]models.Alert, error) {
+	var rows []struct {
+		CallID         string
+		Queue          string
+		Since          time.Time
+		MaxWaitSeconds int
+	}
+	err := s.db.Raw(`
+		SELECT latest.call_id, latest.queue, first.since, q.max_wait_seconds
+		FROM (
+			SELECT DISTINCT ON (call_id) call_id, type, queue
+			FROM call_events
+			WHERE created_at >= ?
+			ORDER BY call_id, created_at DESC, id DESC
+		) AS latest
+		JOIN (
+			SELECT call_id, MIN(created_at) AS since
+			FROM call_events
+			WHERE created_at >= ?
+			GROUP BY call_id
+		) AS first ON first.call_id = latest.call_id
+		JOIN queues AS q ON q.name = latest.queue
+		WHERE latest.type IN ?
+			AND q.max_wait_seconds > 0
+			AND first.since <= NOW() - make_interval(secs => q.max_wait_seconds)`,
+		now.Add(-waitingHorizon), now.Add(-waitingHorizon),
+		[]string{models.CallEventQueued, models.CallEventOverflowed},
+	).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	waits := make(map[string]models.Alert, len(rows))
+	for _, row := range rows {
+		key := models.AlertTypeMaxWait + ":" + row.CallID
+		waited := now.Sub(row.Since).Seconds()
+		waits[key] = models.Alert{
+			Key:       key,
+			Type:      models.AlertTypeMaxWait,
+			Queue:     row.Queue,
+			CallID:    row.CallID,
+			Value:     waited,
+			Threshold: float64(row.MaxWaitSeconds),
+			Message: fmt.Sprintf("Call %s has waited %.0fs in queue %s (max %ds)",
+				row.CallID, waited, row.Queue, row.MaxWaitSeconds),
+		}
+	}
+	return waits, nil
+}
+
+// fire opens an alert unless another instance already has, and announces it
+func (s *alertService) fire(ctx context.Context, alert models.Alert) {
+	alert.FiredAt = time.Now()
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to record alert", "alert_key", alert.Key, "error", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	s.announce(ctx, models.AlertFired, alert)
+}
+
+// resolve closes an open alert; only the instance that closes it announces it
+func (s *alertService) resolve(ctx context.Context, alert models.Alert) {
+	now := time.Now()
+	result := s.db.Model(&models.Alert{}).
+		Where("id = ? AND resolved_at IS NULL", alert.ID).
+		Update("resolved_at", now)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to resolve alert", "alert_key", alert.Key, "error", result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+	alert.ResolvedAt = &now
+	s.announce(ctx, models.AlertResolved, alert)
+}
+
+// announce publishes an alert change to the alerts topic and to webhook subscribers
+func (s *alertService) announce(ctx context.Context, action string, alert models.Alert) {
+	ctx = logger.WithCallID(ctx, alert.CallID)
+	slog.WarnContext(ctx, "Alert "+action, "alert_key", alert.Key, "type", alert.Type, "queue", alert.Queue, "message", alert.Message)
+
+	if s.producer != nil {
+		data, _ := json.Marshal(alert)
+		key := fmt.Sprintf("%s:%s", action, alert.Key)
+		if err := s.producer.PublishMessage(ctx, key, data); err != nil {
+			slog.WarnContext(ctx, "Failed to publish alert", "alert_key", alert.Key, "error", err)
+		}
+	}
+
+	if s.webhooks != nil {
+		eventType := models.EventAlertFired
+		if action == models.AlertResolved {
+			eventType = models.EventAlertResolved
+		}
+		if err := s.webhooks.Emit(eventType, alert); err != nil {
+			slog.WarnContext(ctx, "Failed to emit alert webhook", "alert_key", alert.Key, "error", err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package alerts
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// slaRow is one queue's service level inputs as the SLA query returns them
+type slaRow struct {
+	queue                               string
+	answered, answeredInTarget, abandon int
+}
+
+// expectEvaluation expects one evaluation's reads: queues, service levels, long waits and open alerts
+func expectEvaluation(mock sqlmock.Sqlmock, sla []slaRow, waits *sqlmock.Rows, open *sqlmock.Rows) {
+	mock.ExpectQuery(`SELECT \* FROM "queues"`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "sla_target_seconds", "sla_target_percent", "sla_window_minutes", "max_wait_seconds"}).
+			AddRow("sales", 20, 80.0, 30, 120))
+	rows := sqlmock.NewRows([]string{"queue", "answered", "answered_in_target", "abandoned"})
+	for _, row := range sla {
+		rows.AddRow(row.queue, row.answered, row.answeredInTarget, row.abandon)
+	}
+	mock.ExpectQuery(`SELECT COALESCE\(NULLIF\(ac.queue, ''\)`).WillReturnRows(rows)
+	if waits == nil {
+		waits = sqlmock.NewRows([]string{"call_id", "queue", "since", "max_wait_seconds"})
+	}
+	mock.ExpectQuery(`SELECT latest.call_id, latest.queue, first.since, q.max_wait_seconds`).WillReturnRows(waits)
+	if open == nil {
+		open = sqlmock.NewRows([]string{"id", "key", "type"})
+	}
+	mock.ExpectQuery(`SELECT \* FROM "alerts" WHERE resolved_at IS NULL`).WillReturnRows(open)
+}
+
+func TestSLABreaches(t *testing.T) {
+	tests := []struct {
+		name string
+		row  slaRow
+		want bool
+	}{
+		{name: "below target", row: slaRow{queue: "sales", answered: 8, answeredInTarget: 6, abandon: 2}, want: true},
+		{name: "on target", row: slaRow{queue: "sales", answered: 10, answeredInTarget: 8}},
+		{name: "too few calls to judge", row: slaRow{queue: "sales", answered: 1, abandon: 3}},
+		// The default queue has no row and follows the column defaults
+		{name: "default queue below target", row: slaRow{queue: models.DefaultQueue, answered: 5, answeredInTarget: 3}, want: true},
+		{name: "deleted queue", row: slaRow{queue: "gone", abandon: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAlertService(db, nil, nil, time.Minute, time.Minute).(*alertService)
+
+			mock.ExpectQuery(`SELECT \* FROM "queues"`).
+				WillReturnRows(sqlmock.NewRows([]string{"name", "sla_target_seconds", "sla_target_percent", "sla_window_minutes"}).
+					AddRow("sales", 20, 80.0, 30))
+			mock.ExpectQuery(`SELECT COALESCE\(NULLIF\(ac.queue, ''\)`).
+				WillReturnRows(sqlmock.NewRows([]string{"queue", "answered", "answered_in_target", "abandoned"}).
+					AddRow(tt.row.queue, tt.row.answered, tt.row.answeredInTarget, tt.row.abandon))
+
+			breaches, err := s.slaBreaches()
+			if err != nil {
+				t.Fatalf("slaBreaches() error = %v", err)
+			}
+			alert, got := breaches[models.AlertTypeSLABreach+":"+tt.row.queue]
+			if got != tt.want {
+				t.Fatalf("breach = %v, want %v (%v)", got, tt.want, breaches)
+			}
+			if got && (alert.Value >= alert.Threshold || alert.Threshold != 0.8) {
+				t.Errorf("alert value %v against threshold %v", alert.Value, alert.Threshold)
+			}
+		})
+	}
+}
+
+func TestEvaluateDebouncesSLABreaches(t *testing.T) {
+	ctx := context.Background()
+	db, mock := testutil.MockDB(t)
+	s := NewAlertService(db, nil, nil, time.Minute, 2*time.Minute).(*alertService)
+	key := models.AlertTypeSLABreach + ":sales"
+	breaching := []slaRow{{queue: "sales", answered: 5, answeredInTarget: 2, abandon: 5}}
+
+	// A breach must last fireAfter before it fires
+	expectEvaluation(mock, breaching, nil, nil)
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	s.breachingSince[key] = time.Now().Add(-time.Minute)
+	expectEvaluation(mock, breaching, nil, nil)
+	mock.ExpectQuery(`INSERT INTO "alerts" .* ON CONFLICT DO NOTHING`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Back on target, the alert stays open until it has been clear for resolveAfter
+	open := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "key", "type", "queue"}).AddRow(1, key, models.AlertTypeSLABreach, "sales")
+	}
+	expectEvaluation(mock, nil, nil, open())
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, breaching := s.breachingSince[key]; breaching {
+		t.Error("breach start kept after the queue recovered")
+	}
+
+	s.clearSince[key] = time.Now().Add(-2 * time.Minute)
+	expectEvaluation(mock, nil, nil, open())
+	mock.ExpectExec(`UPDATE "alerts" SET "resolved_at"=\$1,"updated_at"=\$2 WHERE id = \$3 AND resolved_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvaluateMaxWait(t *testing.T) {
+	ctx := context.Background()
+	db, mock := testutil.MockDB(t)
+	s := NewAlertService(db, nil, nil, time.Hour, time.Hour).(*alertService)
+	key := models.AlertTypeMaxWait + ":c1"
+
+	// Long waits fire at once, without the SLA debounce
+	expectEvaluation(mock, nil,
+		sqlmock.NewRows([]string{"call_id", "queue", "since", "max_wait_seconds"}).AddRow("c1", "sales", time.Now().Add(-3*time.Minute), 120),
+		nil)
+	mock.ExpectQuery(`INSERT INTO "alerts" .* ON CONFLICT DO NOTHING`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Still waiting and already open: nothing to do
+	expectEvaluation(mock, nil,
+		sqlmock.NewRows([]string{"call_id", "queue", "since", "max_wait_seconds"}).AddRow("c1", "sales", time.Now().Add(-4*time.Minute), 120),
+		sqlmock.NewRows([]string{"id", "key", "type"}).AddRow(1, key, models.AlertTypeMaxWait))
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The call left the queue
+	expectEvaluation(mock, nil, nil, sqlmock.NewRows([]string{"id", "key", "type"}).AddRow(1, key, models.AlertTypeMaxWait))
+	mock.ExpectExec(`UPDATE "alerts" SET "resolved_at"=\$1`).WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.evaluate(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
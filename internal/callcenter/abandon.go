@@ -0,0 +1,111 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// abandonMarkerTTL keeps the abandon marker around long enough for the distributor to drain a backlog
+const abandonMarkerTTL = 24 * time.Hour
+
+// ErrCallFinished is returned when abandoning a call that has already ended
+var ErrCallFinished = errors.New("call has already finished")
+
+// AbandonCall records that the caller hung up. If the call is still queued the distributor
+// drops it when it is dequeued; if it was already assigned, it is marked abandoned and
+// retracted from the agent. The returned call is nil while the call is still queued, and
+// ErrCallNotFound is returned for a call that was never accepted.
+func (s *callCenterService) AbandonCall(ctx context.Context, callID string) (*models.AssignedCall, error) {
+	now := time.Now()
+
+	// The distributor saves an assignment before taking the call out of the queue, so a call
+	// that is not queued by the time it is looked up here is either recorded or unknown
+	queued, err := s.callQueued(ctx, callID)
+	if err != nil {
+		return nil, err
+	}
+	if !queued {
+		var recorded int64
+		if err := s.db.Model(&models.AssignedCall{}).Where("call_id = ?", callID).Count(&recorded).Error; err != nil {
+			return nil, err
+		}
+		if recorded == 0 {
+			return nil, ErrCallNotFound
+		}
+	}
+
+	// Set the marker first so a distributor assigning concurrently sees it after saving
+	if err := s.redis.Set(ctx, "abandoned_call:"+callID, now.Format(time.RFC3339Nano), abandonMarkerTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to record abandonment: %w", err)
+	}
+
+	var call models.AssignedCall
+	if err := s.db.Where("call_id = ?", callID).First(&call).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if call.Status != models.CallStatusAssigned {
+		if call.Status == models.CallStatusAbandoned {
+			return &call, nil
+		}
+		return nil, ErrCallFinished
+	}
+
+	// The transition and its timeline event are saved together
+	transitioned := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AssignedCall{}).
+			Where("call_id = ? AND status = ?", callID, models.CallStatusAssigned).
+			Updates(map[string]interface{}{
+				"status":          models.CallStatusAbandoned,
+				"abandoned_at":    now,
+				"time_to_abandon": int64(now.Sub(call.ReceivedAt).Seconds()),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		transitioned = true
+		return tx.Create(&models.CallEvent{CallID: callID, Type: models.CallStatusAbandoned, Queue: call.Queue, AgentID: call.AssignedAgentID}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("call_id = ?", callID).First(&call).Error; err != nil {
+		return nil, err
+	}
+
+	// Only the side that performed the transition retracts the call from the agent
+	if transitioned {
+		if err := s.kafka.PublishAssignedCall(ctx, call); err != nil {
+			slog.WarnContext(ctx, "Failed to publish retraction", "call_id", callID, "error", err)
+		}
+	}
+
+	return &call, nil
+}
+
+// callQueued reports whether a call was accepted and has not left its queue yet, including
+// calls published but not yet parked by the distributor
+func (s *callCenterService) callQueued(ctx context.Context, callID string) (bool, error) {
+	pipe := s.redis.Pipeline()
+	accepted := pipe.Exists(ctx, "accepted_call:"+callID)
+	indexed := pipe.HExists(ctx, "call_queue_index", callID)
+	waiting := pipe.HExists(ctx, "waiting_calls", callID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return accepted.Val() > 0 || indexed.Val() || waiting.Val(), nil
+}
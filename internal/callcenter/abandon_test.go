@@ -0,0 +1,87 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAbandonCall(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		setup      func(client *redis.Client)
+		recorded   string // status of the assigned_calls row, empty when there is none
+		wantErr    error
+		wantMarker bool
+	}{
+		{name: "unknown call", wantErr: ErrCallNotFound},
+		{
+			name:       "published, not yet parked",
+			setup:      func(client *redis.Client) { client.Set(ctx, "accepted_call:c1", "sales", 0) },
+			wantMarker: true,
+		},
+		{
+			name: "waiting in a queue",
+			setup: func(client *redis.Client) {
+				client.HSet(ctx, "call_queue_index", "c1", "sales")
+				client.HSet(ctx, "waiting_calls", "c1", "{}")
+			},
+			wantMarker: true,
+		},
+		{
+			// Claimed by a dispatcher but not yet saved: only the index is left
+			name:       "being assigned",
+			setup:      func(client *redis.Client) { client.HSet(ctx, "call_queue_index", "c1", "sales") },
+			wantMarker: true,
+		},
+		{name: "finished", recorded: models.CallStatusCompleted, wantErr: ErrCallFinished, wantMarker: true},
+		{name: "already abandoned", recorded: models.CallStatusAbandoned, wantMarker: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			client, server := testutil.Redis(t)
+			s := &callCenterService{db: db, redis: client}
+			if tt.setup != nil {
+				tt.setup(client)
+			}
+
+			queued := tt.setup != nil
+			if !queued {
+				count := 0
+				if tt.recorded != "" {
+					count = 1
+				}
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "assigned_calls" WHERE call_id = \$1`).
+					WithArgs("c1").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+			}
+			if queued || tt.recorded != "" {
+				rows := sqlmock.NewRows([]string{"id", "call_id", "queue", "status"})
+				if tt.recorded != "" {
+					rows.AddRow(1, "c1", "sales", tt.recorded)
+				}
+				mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE call_id = \$1`).WillReturnRows(rows)
+			}
+
+			call, err := s.AbandonCall(ctx, "c1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AbandonCall() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && tt.recorded == "" && call != nil {
+				t.Errorf("AbandonCall() = %+v, want nil while queued", call)
+			}
+			if got := server.Exists("abandoned_call:c1"); got != tt.wantMarker {
+				t.Errorf("abandon marker written = %v, want %v", got, tt.wantMarker)
+			}
+		})
+	}
+}
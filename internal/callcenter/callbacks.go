@@ -0,0 +1,254 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// callbackPollInterval is how often the scheduler dispatches due callbacks and checks outcomes
+	callbackPollInterval = 10 * time.Second
+	// callbackLostAfter is how long a dispatched call may be missing from both the queue and
+	// assigned_calls before the attempt is counted as failed
+	callbackLostAfter = acceptedCallTTL + 5*time.Minute
+)
+
+func (s *callCenterService) SetCallbackPolicy(maxAttempts int, retryDelay time.Duration) {
+	s.callbackMaxAttempts = maxAttempts
+	s.callbackRetryDelay = retryDelay
+}
+
+func (s *callCenterService) CreateCallback(callback models.Callback) (*models.Callback, error) {
+	if callback.WindowStart.IsZero() {
+		callback.WindowStart = time.Now()
+	}
+	if callback.WindowEnd != nil && !callback.WindowEnd.After(callback.WindowStart) {
+		return nil, errors.New("window_end must be after window_start")
+	}
+	if callback.Queue == "" {
+		callback.Queue = models.DefaultQueue
+	}
+	if err := s.checkQueue(models.IncomingCall{Queue: callback.Queue}); err != nil {
+		return nil, err
+	}
+
+	callback.Status = models.CallbackScheduled
+	callback.NextAttemptAt = &callback.WindowStart
+
+	if err := s.db.Create(&callback).Error; err != nil {
+		return nil, err
+	}
+	return &callback, nil
+}
+
+func (s *callCenterService) GetCallback(id uint) (*models.Callback, error) {
+	var callback models.Callback
+	if err := s.db.First(&callback, id).Error; err != nil {
+		return nil, errors.New("callback not found")
+	}
+	return &callback, nil
+}
+
+func (s *callCenterService) ListCallbacks(status string) ([]models.Callback, error) {
+	query := s.db.Order("window_start ASC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var callbacks []models.Callback
+	if err := query.Find(&callbacks).Error; err != nil {
+		return nil, err
+	}
+	return callbacks, nil
+}
+
+// CancelCallback stops a callback that has not been completed yet
+func (s *callCenterService) CancelCallback(id uint) (*models.Callback, error) {
+	result := s.db.Model(&models.Callback{}).
+		Where("id = ? AND status IN ?", id, []string{models.CallbackScheduled, models.CallbackDispatched}).
+		Updates(map[string]interface{}{"status": models.CallbackCanceled, "next_attempt_at": nil})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("callback not found or already finished")
+	}
+	return s.GetCallback(id)
+}
+
+// StartCallbackScheduler injects due callbacks as outbound calls and resolves their outcomes until ctx is canceled
+func (s *callCenterService) StartCallbackScheduler(ctx context.Context) {
+	ticker := time.NewTicker(callbackPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.dispatchDueCallbacks(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to dispatch callbacks", "error", err)
+			}
+			if err := s.resolveDispatchedCallbacks(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to resolve callbacks", "error", err)
+			}
+		}
+	}
+}
+
+// dispatchDueCallbacks claims due callbacks with SKIP LOCKED so replicas don't dial the same customer twice
+func (s *callCenterService) dispatchDueCallbacks(ctx context.Context) error {
+	var callbacks []models.Callback
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.CallbackScheduled, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(100).
+			Find(&callbacks).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i := range callbacks {
+			callbacks[i].Attempts++
+			callbacks[i].DispatchedAt = &now
+			callbacks[i].Status = models.CallbackDispatched
+			callbacks[i].NextAttemptAt = nil
+			callbacks[i].LastCallID = fmt.Sprintf("callback-%d-%d", callbacks[i].ID, callbacks[i].Attempts)
+			if err := tx.Save(&callbacks[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, callback := range callbacks {
+		call := models.IncomingCall{
+			CallID:           callback.LastCallID,
+			CustomerNumber:   callback.CustomerNumber,
+			RawNumber:        callback.RawNumber,
+			Queue:            callback.Queue,
+			Direction:        "outbound",
+			Source:           "callback",
+			PreferredAgentID: callback.PreferredAgentID,
+			Timestamp:        time.Now(),
+		}
+
+		// A callback call has no request behind it, so it is correlated by its own ID
+		callCtx := logger.WithCorrelationID(logger.WithCallID(ctx, call.CallID), call.CallID)
+
+		// The queue may have been deleted or the number blocked since the callback was scheduled
+		if err := s.checkQueue(call); err != nil {
+			var unknown *UnknownQueueError
+			if errors.As(err, &unknown) {
+				s.failCallback(callback, err.Error())
+			} else {
+				s.retryCallback(callback, fmt.Sprintf("failed to check queue: %v", err))
+			}
+			continue
+		}
+		if err := s.screenCall(callCtx, call); err != nil {
+			var blocked *BlockedError
+			if errors.As(err, &blocked) {
+				s.failCallback(callback, err.Error())
+			} else {
+				s.retryCallback(callback, fmt.Sprintf("failed to screen call: %v", err))
+			}
+			continue
+		}
+
+		if err := s.kafka.PublishIncomingCall(callCtx, call); err != nil {
+			s.retryCallback(callback, fmt.Sprintf("failed to publish: %v", err))
+			continue
+		}
+		s.markAccepted(callCtx, call)
+		slog.InfoContext(callCtx, "Dispatched callback", "callback_id", callback.ID, "queue", call.Queue)
+	}
+
+	return nil
+}
+
+// resolveDispatchedCallbacks completes callbacks whose call was handled and retries missed
+// ones, including calls that were lost without ever reaching an agent
+func (s *callCenterService) resolveDispatchedCallbacks(ctx context.Context) error {
+	var callbacks []models.Callback
+	if err := s.db.Where("status = ?", models.CallbackDispatched).Find(&callbacks).Error; err != nil {
+		return err
+	}
+
+	for _, callback := range callbacks {
+		status, err := s.GetCallStatus(ctx, callback.LastCallID)
+		if errors.Is(err, ErrCallNotFound) {
+			if callback.DispatchedAt == nil || time.Since(*callback.DispatchedAt) > callbackLostAfter {
+				s.retryCallback(callback, "call lost: no outcome recorded")
+			}
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to check callback call", "callback_id", callback.ID, "call_id", callback.LastCallID, "error", err)
+			continue
+		}
+
+		switch status.Status {
+		case models.CallStatusQueued, models.CallStatusAssigned:
+			// still waiting, or an agent is on the call
+		case models.CallStatusCompleted, models.DispositionCallbackNeeded:
+			s.db.Model(&callback).Updates(map[string]interface{}{"status": models.CallbackCompleted, "last_error": ""})
+		default:
+			reason := status.Status
+			if status.StatusReason != "" {
+				reason = fmt.Sprintf("%s: %s", status.Status, status.StatusReason)
+			}
+			s.retryCallback(callback, reason)
+		}
+	}
+
+	return nil
+}
+
+// failCallback gives up on a callback that can never be dialed
+func (s *callCenterService) failCallback(callback models.Callback, reason string) {
+	updates := map[string]interface{}{"status": models.CallbackFailed, "next_attempt_at": nil, "last_error": reason}
+	if err := s.db.Model(&callback).Updates(updates).Error; err != nil {
+		slog.Error("Failed to fail callback", "callback_id", callback.ID, "error", err)
+		return
+	}
+	slog.Info("Callback failed", "callback_id", callback.ID, "call_id", callback.LastCallID, "reason", reason)
+}
+
+// retryCallback reschedules a missed callback, or fails it when attempts or the window run out
+func (s *callCenterService) retryCallback(callback models.Callback, reason string) {
+	next := time.Now().Add(s.callbackRetryDelay)
+
+	updates := map[string]interface{}{"last_error": reason}
+	switch {
+	case callback.Attempts >= s.callbackMaxAttempts:
+		updates["status"] = models.CallbackFailed
+		updates["next_attempt_at"] = nil
+	case callback.WindowEnd != nil && next.After(*callback.WindowEnd):
+		updates["status"] = models.CallbackFailed
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = reason + " (callback window expired)"
+	default:
+		updates["status"] = models.CallbackScheduled
+		updates["next_attempt_at"] = next
+	}
+
+	if err := s.db.Model(&callback).Updates(updates).Error; err != nil {
+		slog.Error("Failed to reschedule callback", "callback_id", callback.ID, "error", err)
+		return
+	}
+	slog.Info("Callback missed", "callback_id", callback.ID, "call_id", callback.LastCallID, "reason", reason, "status", updates["status"])
+}
@@ -0,0 +1,86 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestResolveDispatchedCallbacks(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	windowEnd := now.Add(time.Minute)
+	any := sqlmock.AnyArg()
+
+	tests := []struct {
+		name       string
+		attempts   int
+		dispatched time.Time
+		windowEnd  *time.Time
+		callStatus string // status recorded for the dispatched call, empty when it has none
+		accepted   bool   // the call is still waiting to be parked
+		wantArgs   []driver.Value
+	}{
+		{
+			name: "answered", attempts: 1, dispatched: now, callStatus: models.CallStatusCompleted,
+			wantArgs: []driver.Value{"", models.CallbackCompleted, any, 1},
+		},
+		{
+			name: "agent asked for another callback", attempts: 1, dispatched: now, callStatus: models.DispositionCallbackNeeded,
+			wantArgs: []driver.Value{"", models.CallbackCompleted, any, 1},
+		},
+		{name: "agent on the call", attempts: 1, dispatched: now, callStatus: models.CallStatusAssigned},
+		{name: "waiting to be parked", attempts: 1, dispatched: now, accepted: true},
+		{
+			name: "abandoned is retried", attempts: 1, dispatched: now, callStatus: models.CallStatusAbandoned,
+			wantArgs: []driver.Value{models.CallStatusAbandoned, any, models.CallbackScheduled, any, 1},
+		},
+		{
+			name: "missed on the last attempt fails", attempts: 3, dispatched: now, callStatus: models.CallStatusMissed,
+			wantArgs: []driver.Value{models.CallStatusMissed, nil, models.CallbackFailed, any, 1},
+		},
+		{
+			name: "retry past the window fails", attempts: 1, dispatched: now, windowEnd: &windowEnd, callStatus: models.CallStatusAbandoned,
+			wantArgs: []driver.Value{models.CallStatusAbandoned + " (callback window expired)", nil, models.CallbackFailed, any, 1},
+		},
+		{name: "recently dispatched, no outcome yet", attempts: 1, dispatched: now.Add(-time.Minute)},
+		{
+			name: "lost without an outcome", attempts: 1, dispatched: now.Add(-callbackLostAfter - time.Minute),
+			wantArgs: []driver.Value{"call lost: no outcome recorded", any, models.CallbackScheduled, any, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			client, _ := testutil.Redis(t)
+			s := &callCenterService{db: db, redis: client}
+			s.SetCallbackPolicy(3, 15*time.Minute)
+			if tt.accepted {
+				client.Set(ctx, "accepted_call:callback-1-1", "sales", 0)
+			}
+
+			mock.ExpectQuery(`SELECT \* FROM "callbacks" WHERE status = \$1`).
+				WithArgs(models.CallbackDispatched).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempts", "last_call_id", "dispatched_at", "window_end"}).
+					AddRow(1, models.CallbackDispatched, tt.attempts, "callback-1-1", tt.dispatched, tt.windowEnd))
+			call := sqlmock.NewRows([]string{"call_id", "queue", "status", "assigned_agent_id"})
+			if tt.callStatus != "" {
+				call.AddRow("callback-1-1", "sales", tt.callStatus, "a1")
+			}
+			mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE call_id = \$1`).WillReturnRows(call)
+			if tt.wantArgs != nil {
+				mock.ExpectExec(`UPDATE "callbacks" SET`).WithArgs(tt.wantArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			if err := s.resolveDispatchedCallbacks(ctx); err != nil {
+				t.Fatalf("resolveDispatchedCallbacks() error = %v", err)
+			}
+		})
+	}
+}
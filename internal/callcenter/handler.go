@@ -1,20 +1,41 @@
 package callcenter
 
 import (
+	"bufio"
+	"bytes"
 	"call-center-api/models"
-	"context"
+	"call-center-api/pkg/logger"
+	"call-center-api/pkg/phone"
+	"call-center-api/pkg/tracing"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = tracing.Tracer("call-center-api/callcenter")
+
 type CallCenterHandler struct {
-	service CallCenterService
+	service         CallCenterService
+	defaultRegion   string
+	webhookProfiles map[string]WebhookProfile
+	replayWindow    time.Duration
+}
+
+func NewCallCenterHandler(service CallCenterService, defaultRegion string) *CallCenterHandler {
+	return &CallCenterHandler{service: service, defaultRegion: defaultRegion}
 }
 
-func NewCallCenterHandler(service CallCenterService) *CallCenterHandler {
-	return &CallCenterHandler{service: service}
+func (h *CallCenterHandler) SetWebhookProfiles(profiles map[string]WebhookProfile, replayWindow time.Duration) {
+	h.webhookProfiles = profiles
+	h.replayWindow = replayWindow
 }
 
 func (h *CallCenterHandler) CreateCall(c *fiber.Ctx) error {
@@ -28,36 +49,844 @@ func (h *CallCenterHandler) CreateCall(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate CallID if not provided
-	if req.CallID == "" {
-		req.CallID = uuid.New().String()
+	if err := h.prepareIncomingCall(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid call",
+			Error:   err.Error(),
+			Code:    validationCode(err),
+		})
 	}
 
-	// Set timestamp if not provided
-	if req.Timestamp.IsZero() {
-		req.Timestamp = time.Now()
+	// The call's trace starts here and follows it through Kafka to the agent's WebSocket
+	ctx, span := tracer.Start(logger.WithCallID(c.UserContext(), req.CallID), "CreateCall")
+	span.SetAttributes(attribute.String("call.id", req.CallID), attribute.String("call.queue", req.Queue))
+	err := h.service.PublishCall(ctx, req)
+	tracing.End(span, err)
+	if err != nil {
+		return publishError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Success: true,
+		Message: "Call processed successfully",
+		Data:    req,
+	})
+}
+
+// GetCallStatus reports which agent got a call, or where it is in the queue
+func (h *CallCenterHandler) GetCallStatus(c *fiber.Ctx) error {
+	status, err := h.service.GetCallStatus(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, ErrCallNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Call not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch call status",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// GetCallTimeline lists a call's routing events, including every queue overflow
+func (h *CallCenterHandler) GetCallTimeline(c *fiber.Ctx) error {
+	events, err := h.service.GetCallTimeline(c.Params("id"))
+	if err != nil {
+		if errors.Is(err, ErrCallNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Call not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch call timeline",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// AbandonCall handles a caller hanging up before the call was answered
+func (h *CallCenterHandler) AbandonCall(c *fiber.Ctx) error {
+	callID := c.Params("id")
+
+	call, err := h.service.AbandonCall(logger.WithCallID(c.UserContext(), callID), callID)
+	if err != nil {
+		if errors.Is(err, ErrCallNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Call not found",
+			})
+		}
+		if errors.Is(err, ErrCallFinished) {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Call can no longer be abandoned",
+				Error:   err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to abandon call",
+			Error:   err.Error(),
+		})
+	}
+
+	if call == nil {
+		return c.Status(fiber.StatusAccepted).JSON(models.Response{
+			Success: true,
+			Message: "Call will be removed from the queue",
+			Data:    fiber.Map{"call_id": callID, "status": models.CallStatusAbandoned},
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Call abandoned successfully",
+		Data:    call,
+	})
+}
+
+// CreateCallback schedules a call back to a customer who does not want to wait
+func (h *CallCenterHandler) CreateCallback(c *fiber.Ctx) error {
+	var req models.CreateCallbackRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+	}
+
+	normalized, err := phone.Normalize(req.CustomerNumber, h.defaultRegion)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid customer number",
+			Error:   err.Error(),
+			Code:    validationCode(err),
+		})
+	}
+
+	callback := models.Callback{
+		CustomerNumber:   normalized,
+		RawNumber:        req.CustomerNumber,
+		Queue:            req.Queue,
+		Reason:           req.Reason,
+		WindowEnd:        req.WindowEnd,
+		PreferredAgentID: req.PreferredAgentID,
+	}
+	if req.WindowStart != nil {
+		callback.WindowStart = *req.WindowStart
 	}
 
-	// Validate required fields
-	if req.CustomerNumber == "" {
+	created, err := h.service.CreateCallback(callback)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to schedule callback",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Success: true,
+		Message: "Callback scheduled successfully",
+		Data:    created,
+	})
+}
+
+func (h *CallCenterHandler) GetCallback(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
 			Success: false,
-			Message: "Customer number is required",
+			Message: "Invalid callback ID",
+		})
+	}
+
+	callback, err := h.service.GetCallback(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Callback not found",
 		})
 	}
 
-	ctx := context.Background()
-	if err := h.service.PublishCall(ctx, req); err != nil {
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    callback,
+	})
+}
+
+func (h *CallCenterHandler) ListCallbacks(c *fiber.Ctx) error {
+	callbacks, err := h.service.ListCallbacks(c.Query("status"))
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
 			Success: false,
-			Message: "Failed to process call",
+			Message: "Failed to fetch callbacks",
 			Error:   err.Error(),
 		})
 	}
 
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    callbacks,
+	})
+}
+
+func (h *CallCenterHandler) CancelCallback(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid callback ID",
+		})
+	}
+
+	callback, err := h.service.CancelCallback(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to cancel callback",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Callback canceled successfully",
+		Data:    callback,
+	})
+}
+
+// CreateCallBatch ingests a JSON array or NDJSON stream of calls with per-item results
+func (h *CallCenterHandler) CreateCallBatch(c *fiber.Ctx) error {
+	if c.Request().Header.ContentLength() > maxBatchBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Success: false,
+			Message: fmt.Sprintf("Batch exceeds maximum of %d bytes", maxBatchBytes),
+		})
+	}
+
+	var body io.Reader = bytes.NewReader(c.Body())
+	if c.Request().IsBodyStream() {
+		body = c.Request().BodyStream()
+	}
+
+	items, err := parseCallBatch(c.Get(fiber.HeaderContentType), &cappedReader{r: body, remaining: maxBatchBytes})
+	switch {
+	case errors.Is(err, errBatchTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Success: false,
+			Message: fmt.Sprintf("Batch exceeds maximum of %d calls", maxBatchSize),
+		})
+	case errors.Is(err, errBatchBodyTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Success: false,
+			Message: fmt.Sprintf("Batch exceeds maximum of %d bytes", maxBatchBytes),
+		})
+	case err != nil:
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+	}
+
+	if len(items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Batch is empty",
+		})
+	}
+
+	results := make([]models.BatchCallResult, len(items))
+	valid := make([]models.IncomingCall, 0, len(items))
+	validIndex := make([]int, 0, len(items))
+
+	for i, item := range items {
+		results[i] = models.BatchCallResult{Index: i}
+		if item.err != nil {
+			results[i].Error = item.err.Error()
+			continue
+		}
+		call := item.call
+		if err := h.prepareIncomingCall(&call); err != nil {
+			results[i].CallID = call.CallID
+			results[i].Error = err.Error()
+			results[i].Code = validationCode(err)
+			continue
+		}
+		results[i].CallID = call.CallID
+		valid = append(valid, call)
+		validIndex = append(validIndex, i)
+	}
+
+	if len(valid) > 0 {
+		errs := h.service.PublishCalls(c.UserContext(), valid)
+		for j, err := range errs {
+			i := validIndex[j]
+			if err != nil {
+				results[i].Error = err.Error()
+				switch {
+				case isBlocked(err):
+					results[i].Code = "blocked"
+				case isUnknownQueue(err):
+					results[i].Code = "unknown_queue"
+				}
+				continue
+			}
+			results[i].Success = true
+		}
+	}
+
+	response := models.BatchCallResponse{
+		Total:   len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Success {
+			response.Accepted++
+		} else {
+			response.Failed++
+		}
+	}
+
+	status := fiber.StatusCreated
+	message := "Batch processed successfully"
+	if response.Failed > 0 {
+		status = fiber.StatusMultiStatus
+		message = "Batch processed with errors"
+	}
+
+	return c.Status(status).JSON(models.Response{
+		Success: response.Accepted > 0,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// ReceiveWebhook accepts signed call events from a telephony provider profile
+func (h *CallCenterHandler) ReceiveWebhook(c *fiber.Ctx) error {
+	profile, ok := h.webhookProfiles[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Unknown webhook provider",
+		})
+	}
+
+	body := c.Body()
+	baseURL := profile.BaseURL
+	if baseURL == "" {
+		baseURL = c.BaseURL()
+	}
+	req := WebhookRequest{
+		URL:         strings.TrimRight(baseURL, "/") + c.OriginalURL(),
+		ContentType: c.Get(fiber.HeaderContentType),
+		Signature:   c.Get(profile.SignatureHeader),
+		Body:        body,
+	}
+	if profile.TimestampHeader != "" {
+		req.Timestamp = c.Get(profile.TimestampHeader)
+	}
+	if err := profile.Verify(req, h.replayWindow, time.Now()); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid webhook signature",
+			Error:   err.Error(),
+		})
+	}
+
+	fields, err := ParseWebhookFields(c.Get(fiber.HeaderContentType), body)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid webhook payload",
+			Error:   err.Error(),
+		})
+	}
+
+	call, err := profile.MapCall(fields)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid webhook payload",
+			Error:   err.Error(),
+		})
+	}
+
+	// Providers retry and signatures can be replayed, so each provider call is accepted once
+	providerCallID := call.CallID
+	if providerCallID != "" {
+		first, err := h.service.ClaimProviderCall(c.UserContext(), profile.Name, providerCallID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Failed to record webhook",
+				Error:   err.Error(),
+			})
+		}
+		if !first {
+			return c.JSON(models.Response{
+				Success: true,
+				Message: "Duplicate webhook ignored",
+				Data:    fiber.Map{"call_id": providerCallID},
+			})
+		}
+	}
+
+	if err := h.prepareIncomingCall(&call); err != nil {
+		h.service.ReleaseProviderCall(c.UserContext(), profile.Name, providerCallID)
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid call",
+			Error:   err.Error(),
+			Code:    validationCode(err),
+		})
+	}
+
+	if err := h.service.PublishCall(logger.WithCallID(c.UserContext(), call.CallID), call); err != nil {
+		// Let the provider's retry through unless the call was deliberately blocked
+		if !isBlocked(err) {
+			h.service.ReleaseProviderCall(c.UserContext(), profile.Name, providerCallID)
+		}
+		return publishError(c, err)
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(models.Response{
 		Success: true,
 		Message: "Call processed successfully",
-		Data:    req,
+		Data:    call,
 	})
 }
+
+func (h *CallCenterHandler) CreateAPIKey(c *fiber.Ctx) error {
+	var req models.CreateAPIKeyRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+	}
+
+	if req.Name == "" || req.Integration == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Name and integration are required",
+		})
+	}
+
+	apiKey, key, err := h.service.IssueAPIKey(req.Name, req.Integration)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create API key",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Success: true,
+		Message: "API key created successfully, store it now as it will not be shown again",
+		Data: models.CreateAPIKeyResponse{
+			APIKey: apiKey,
+			Key:    key,
+		},
+	})
+}
+
+func (h *CallCenterHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.service.ListAPIKeys()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch API keys",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+func (h *CallCenterHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid API key ID",
+		})
+	}
+
+	apiKey, err := h.service.RevokeAPIKey(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "API key not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "API key revoked successfully",
+		Data:    apiKey,
+	})
+}
+
+func (h *CallCenterHandler) CreateBlockedNumber(c *fiber.Ctx) error {
+	var req models.CreateBlockedNumberRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+	}
+
+	entry := models.BlockedNumber{
+		Number:    strings.TrimSpace(req.Number),
+		MatchType: req.MatchType,
+		Reason:    req.Reason,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	// Exact entries are stored in E.164 so they match normalized customer numbers;
+	// prefixes must already be written in E.164 form, e.g. "+1900"
+	if entry.MatchType == models.BlockMatchPrefix {
+		if !strings.HasPrefix(entry.Number, "+") || len(entry.Number) < 2 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Prefix must start with + and a country code",
+			})
+		}
+	} else {
+		normalized, err := phone.Normalize(entry.Number, h.defaultRegion)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid number",
+				Error:   err.Error(),
+				Code:    validationCode(err),
+			})
+		}
+		entry.Number = normalized
+	}
+
+	created, err := h.service.BlockNumber(entry)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to block number",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.Response{
+		Success: true,
+		Message: "Number blocked successfully",
+		Data:    created,
+	})
+}
+
+func (h *CallCenterHandler) ListBlockedNumbers(c *fiber.Ctx) error {
+	entries, err := h.service.ListBlockedNumbers()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch blocklist",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    entries,
+	})
+}
+
+func (h *CallCenterHandler) DeleteBlockedNumber(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid blocklist entry ID",
+		})
+	}
+
+	if err := h.service.UnblockNumber(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Blocklist entry not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Number unblocked successfully",
+	})
+}
+
+// GetBlockedCallStats reports blocked calls per reason; defaults to the last 24 hours
+func (h *CallCenterHandler) GetBlockedCallStats(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	stats, err := h.service.GetBlockedCallStats(from, to)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch blocked call stats",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    stats,
+	})
+}
+
+const (
+	// maxBatchSize bounds the calls in a single batch ingestion request
+	maxBatchSize = 10000
+	// maxBatchBytes bounds the body of a batch ingestion request, which is read as a stream
+	maxBatchBytes = 16 * 1024 * 1024
+)
+
+var (
+	errBatchTooLarge     = fmt.Errorf("batch exceeds maximum of %d calls", maxBatchSize)
+	errBatchBodyTooLarge = fmt.Errorf("batch exceeds maximum of %d bytes", maxBatchBytes)
+)
+
+// prepareIncomingCall fills defaults, validates the call and normalizes its numbers to E.164
+func (h *CallCenterHandler) prepareIncomingCall(call *models.IncomingCall) error {
+	// Clients must not pick their agent; only callbacks route to a preferred agent
+	call.PreferredAgentID = ""
+	// Generate CallID if not provided
+	if call.CallID == "" {
+		call.CallID = uuid.New().String()
+	}
+
+	// Set timestamp if not provided
+	if call.Timestamp.IsZero() {
+		call.Timestamp = time.Now()
+	}
+
+	if call.Queue == "" {
+		call.Queue = models.DefaultQueue
+	}
+
+	// Keep what the caller sent and use the canonical form as the customer key. Both come
+	// from customer_number, so a client-supplied raw_number cannot disagree with it.
+	call.RawNumber = call.CustomerNumber
+	normalized, err := phone.Normalize(call.RawNumber, h.defaultRegion)
+	if err != nil {
+		return err
+	}
+	call.CustomerNumber = normalized
+
+	// The called number is informational, so only normalize it when it parses
+	if call.CalledNumber != "" {
+		if normalized, err := phone.Normalize(call.CalledNumber, h.defaultRegion); err == nil {
+			call.CalledNumber = normalized
+		}
+	}
+
+	return nil
+}
+
+// publishError maps a PublishCall failure to a response, reporting screened calls as 403
+func publishError(c *fiber.Ctx, err error) error {
+	if isBlocked(err) {
+		return c.Status(fiber.StatusForbidden).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Call blocked",
+			Error:   err.Error(),
+			Code:    "blocked",
+		})
+	}
+	if isUnknownQueue(err) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Unknown queue",
+			Error:   err.Error(),
+			Code:    "unknown_queue",
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Success: false,
+		Message: "Failed to process call",
+		Error:   err.Error(),
+	})
+}
+
+func isBlocked(err error) bool {
+	var blockedErr *BlockedError
+	return errors.As(err, &blockedErr)
+}
+
+func isUnknownQueue(err error) bool {
+	var queueErr *UnknownQueueError
+	return errors.As(err, &queueErr)
+}
+
+// validationCode extracts the machine-readable code from a phone validation error
+func validationCode(err error) string {
+	var validationErr *phone.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Code
+	}
+	return ""
+}
+
+// batchItem is one decoded entry of a batch, or the reason it could not be decoded
+type batchItem struct {
+	call models.IncomingCall
+	err  error
+}
+
+// parseCallBatch decodes a JSON array, or NDJSON with one call per line, from a stream. Entries
+// are decoded as they are read, and reading stops once the batch exceeds maxBatchSize.
+func parseCallBatch(contentType string, body io.Reader) ([]batchItem, error) {
+	reader := bufio.NewReader(body)
+
+	if !strings.Contains(contentType, "ndjson") {
+		first, err := peekNonSpace(reader)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if first == '[' {
+			return parseJSONBatch(reader)
+		}
+	}
+
+	var items []batchItem
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxBatchSize {
+			return nil, errBatchTooLarge
+		}
+		var item batchItem
+		item.err = json.Unmarshal(line, &item.call)
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// parseJSONBatch decodes a JSON array one entry at a time, so a malformed entry fails only
+// that item
+func parseJSONBatch(reader io.Reader) ([]batchItem, error) {
+	decoder := json.NewDecoder(reader)
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var items []batchItem
+	for decoder.More() {
+		if len(items) == maxBatchSize {
+			return nil, errBatchTooLarge
+		}
+		var entry json.RawMessage
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+		var item batchItem
+		item.err = json.Unmarshal(entry, &item.call)
+		items = append(items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// peekNonSpace skips leading whitespace and returns the next byte without consuming it
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return next[0], nil
+		}
+	}
+}
+
+// cappedReader fails with errBatchBodyTooLarge once more than remaining bytes are read
+type cappedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *cappedReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, errBatchBodyTooLarge
+	}
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if r.remaining < 0 {
+		return n, errBatchBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,109 @@
+package callcenter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseCallBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		calls       []string // expected call IDs; "!" marks an item that failed to decode
+		wantErr     error
+		anyErr      bool
+	}{
+		{
+			name:        "json array",
+			contentType: "application/json",
+			body:        ` [{"call_id":"a"},{"call_id":"b"}] `,
+			calls:       []string{"a", "b"},
+		},
+		{
+			name:        "json array with a bad item",
+			contentType: "application/json",
+			body:        `[{"call_id":"a"},{"call_id":1},{"call_id":"c"}]`,
+			calls:       []string{"a", "!", "c"},
+		},
+		{
+			name:        "empty json array",
+			contentType: "application/json",
+			body:        `[]`,
+			calls:       nil,
+		},
+		{
+			name:        "malformed json array",
+			contentType: "application/json",
+			body:        `[{"call_id":"a"},`,
+			anyErr:      true,
+		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        "{\"call_id\":\"a\"}\n\n{\"call_id\":\"b\"}\n",
+			calls:       []string{"a", "b"},
+		},
+		{
+			name:        "ndjson with a bad line",
+			contentType: "application/x-ndjson",
+			body:        "{\"call_id\":\"a\"}\n{oops\n",
+			calls:       []string{"a", "!"},
+		},
+		{
+			name:        "ndjson detected without content type",
+			contentType: "",
+			body:        "{\"call_id\":\"a\"}\n",
+			calls:       []string{"a"},
+		},
+		{
+			name:        "too many calls",
+			contentType: "application/x-ndjson",
+			body:        strings.Repeat("{}\n", maxBatchSize+1),
+			wantErr:     errBatchTooLarge,
+		},
+		{
+			name:        "too many json entries",
+			contentType: "application/json",
+			body:        "[" + strings.Repeat("{},", maxBatchSize) + "{}]",
+			wantErr:     errBatchTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := parseCallBatch(tt.contentType, strings.NewReader(tt.body))
+			if tt.wantErr != nil || tt.anyErr {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("parseCallBatch() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCallBatch() error = %v", err)
+			}
+			if len(items) != len(tt.calls) {
+				t.Fatalf("parseCallBatch() returned %d items, want %d", len(items), len(tt.calls))
+			}
+			for i, want := range tt.calls {
+				if want == "!" {
+					if items[i].err == nil {
+						t.Errorf("item %d decoded, want an error", i)
+					}
+					continue
+				}
+				if items[i].err != nil || items[i].call.CallID != want {
+					t.Errorf("item %d = %q (%v), want %q", i, items[i].call.CallID, items[i].err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCallBatchBodyLimit(t *testing.T) {
+	body := &cappedReader{r: strings.NewReader(strings.Repeat("{}\n", 100)), remaining: 10}
+	if _, err := parseCallBatch("application/x-ndjson", body); !errors.Is(err, errBatchBodyTooLarge) {
+		t.Errorf("parseCallBatch() error = %v, want %v", err, errBatchBodyTooLarge)
+	}
+}
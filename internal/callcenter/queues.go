@@ -0,0 +1,60 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"fmt"
+)
+
+// UnknownQueueError is returned when a call names a queue that has not been created
+type UnknownQueueError struct {
+	Queue string
+}
+
+func (e *UnknownQueueError) Error() string {
+	return fmt.Sprintf("unknown queue %q", e.Queue)
+}
+
+// checkQueue verifies the call's queue exists. The default queue always exists.
+func (s *callCenterService) checkQueue(call models.IncomingCall) error {
+	return s.checkQueues([]models.IncomingCall{call})[0]
+}
+
+// checkQueues verifies each call's queue exists with one lookup for all the queues named,
+// returning an error slice aligned with calls
+func (s *callCenterService) checkQueues(calls []models.IncomingCall) []error {
+	errs := make([]error, len(calls))
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, call := range calls {
+		if call.Queue == "" || call.Queue == models.DefaultQueue || seen[call.Queue] {
+			continue
+		}
+		seen[call.Queue] = true
+		names = append(names, call.Queue)
+	}
+	if len(names) == 0 {
+		return errs
+	}
+
+	var existing []string
+	if err := s.db.Model(&models.Queue{}).Where("name IN ?", names).Pluck("name", &existing).Error; err != nil {
+		for i, call := range calls {
+			if seen[call.Queue] {
+				errs[i] = err
+			}
+		}
+		return errs
+	}
+
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+	for i, call := range calls {
+		if seen[call.Queue] && !known[call.Queue] {
+			errs[i] = &UnknownQueueError{Queue: call.Queue}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,83 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCheckQueues(t *testing.T) {
+	tests := []struct {
+		name     string
+		queues   []string // one call per entry
+		lookup   []string // queue names the single lookup is expected to ask for; nil means no query
+		existing []string
+		dbErr    error
+		want     []string // "" ok, "unknown", or "db"
+	}{
+		{
+			name:   "default queue needs no lookup",
+			queues: []string{"", models.DefaultQueue},
+			want:   []string{"", ""},
+		},
+		{
+			name:     "one lookup for a whole batch",
+			queues:   []string{"sales", "support", "sales", "", "billing", "support"},
+			lookup:   []string{"sales", "support", "billing"},
+			existing: []string{"sales", "billing"},
+			want:     []string{"", "unknown", "", "", "", "unknown"},
+		},
+		{
+			name:   "lookup failure fails only calls that needed it",
+			queues: []string{"sales", ""},
+			lookup: []string{"sales"},
+			dbErr:  errors.New("connection reset"),
+			want:   []string{"db", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			if tt.lookup != nil {
+				args := make([]driver.Value, len(tt.lookup))
+				for i, name := range tt.lookup {
+					args[i] = name
+				}
+				query := mock.ExpectQuery(`SELECT "name" FROM "queues" WHERE name IN`).WithArgs(args...)
+				if tt.dbErr != nil {
+					query.WillReturnError(tt.dbErr)
+				} else {
+					rows := sqlmock.NewRows([]string{"name"})
+					for _, name := range tt.existing {
+						rows.AddRow(name)
+					}
+					query.WillReturnRows(rows)
+				}
+			}
+
+			calls := make([]models.IncomingCall, len(tt.queues))
+			for i, queue := range tt.queues {
+				calls[i] = models.IncomingCall{CallID: "c", Queue: queue}
+			}
+			s := &callCenterService{db: db}
+
+			errs := s.checkQueues(calls)
+			for i, want := range tt.want {
+				var queueErr *UnknownQueueError
+				switch {
+				case want == "" && errs[i] != nil:
+					t.Errorf("call %d error = %v, want none", i, errs[i])
+				case want == "unknown" && !errors.As(errs[i], &queueErr):
+					t.Errorf("call %d error = %v, want UnknownQueueError", i, errs[i])
+				case want == "db" && !errors.Is(errs[i], tt.dbErr):
+					t.Errorf("call %d error = %v, want %v", i, errs[i], tt.dbErr)
+				}
+			}
+		})
+	}
+}
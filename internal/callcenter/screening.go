@@ -0,0 +1,251 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm/clause"
+)
+
+// BlockedError is returned when a call is rejected by the blocklist or flood protection
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("call blocked: %s", e.Reason)
+}
+
+// blocklistChunk caps the candidate numbers sent to Postgres in one blocklist lookup
+const blocklistChunk = 2000
+
+// screenCall rejects blocklisted numbers and call floods, recording each rejection as a blocked call
+func (s *callCenterService) screenCall(ctx context.Context, call models.IncomingCall) error {
+	return s.screenCalls(ctx, []models.IncomingCall{call}, s.floodLimit)[0]
+}
+
+// screenCalls screens calls with one blocklist lookup and one Redis round trip, returning an
+// error slice aligned with calls. floodLimit 0 turns flood protection off.
+func (s *callCenterService) screenCalls(ctx context.Context, calls []models.IncomingCall, floodLimit int) []error {
+	errs := make([]error, len(calls))
+
+	numbers := make([]string, len(calls))
+	for i, call := range calls {
+		numbers[i] = call.CustomerNumber
+	}
+	entries, err := s.findBlockedNumbers(numbers)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	flooding := s.floodingCalls(ctx, numbers, floodLimit)
+
+	var blocked []models.AssignedCall
+	for i, call := range calls {
+		var code, reason string
+		if entry := entries[call.CustomerNumber]; entry != nil {
+			code, reason = models.BlockReasonBlocklist, "blocklisted"
+			if entry.Reason != "" {
+				reason = fmt.Sprintf("blocklisted: %s", entry.Reason)
+			}
+		} else if flooding[i] {
+			code = models.BlockReasonFlood
+			reason = fmt.Sprintf("call flood: more than %d calls within %s", floodLimit, s.floodWindow)
+		} else {
+			continue
+		}
+
+		blocked = append(blocked, blockedCall(call, code, reason))
+		errs[i] = &BlockedError{Reason: reason}
+		slog.InfoContext(ctx, "Blocked call", "call_id", call.CallID, "customer_number", call.CustomerNumber, "reason", reason)
+	}
+
+	// Blocked calls are stored so they show up in reporting. A retried call ID is already
+	// recorded, and must not cost the rest of its chunk.
+	if len(blocked) > 0 {
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(blocked, publishBatchSize).Error; err != nil {
+			slog.ErrorContext(ctx, "Failed to save blocked calls", "count", len(blocked), "error", err)
+		}
+	}
+	return errs
+}
+
+// findBlockedNumbers maps each number to the unexpired rule blocking it, preferring an exact
+// rule over the longest matching prefix. Prefix rules are found by looking up every prefix
+// of the numbers, so rule values are never interpreted as patterns.
+func (s *callCenterService) findBlockedNumbers(numbers []string) (map[string]*models.BlockedNumber, error) {
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, number := range numbers {
+		for end := 1; end <= len(number); end++ {
+			if prefix := number[:end]; !seen[prefix] {
+				seen[prefix] = true
+				candidates = append(candidates, prefix)
+			}
+		}
+	}
+
+	rules := make(map[string][]models.BlockedNumber)
+	for start := 0; start < len(candidates); start += blocklistChunk {
+		end := start + blocklistChunk
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+
+		var entries []models.BlockedNumber
+		err := s.db.
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Where("number IN ?", candidates[start:end]).
+			Find(&entries).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			rules[entry.Number] = append(rules[entry.Number], entry)
+		}
+	}
+
+	matches := make(map[string]*models.BlockedNumber)
+	for _, number := range numbers {
+		if _, done := matches[number]; done {
+			continue
+		}
+		matches[number] = matchBlockRule(number, rules)
+	}
+	return matches, nil
+}
+
+// matchBlockRule picks the rule blocking number from the rules keyed by their number, or nil
+func matchBlockRule(number string, rules map[string][]models.BlockedNumber) *models.BlockedNumber {
+	for i, rule := range rules[number] {
+		if rule.MatchType == models.BlockMatchExact {
+			return &rules[number][i]
+		}
+	}
+	for end := len(number); end > 0; end-- {
+		prefix := number[:end]
+		for i, rule := range rules[prefix] {
+			if rule.MatchType == models.BlockMatchPrefix {
+				return &rules[prefix][i]
+			}
+		}
+	}
+	return nil
+}
+
+// floodingCalls counts calls per number in a fixed Redis window and flags the calls beyond the
+// limit. Each window is created with its TTL in the same transaction as the increment, so a
+// counter can never outlive its window. Redis failures let the calls through.
+func (s *callCenterService) floodingCalls(ctx context.Context, numbers []string, limit int) []bool {
+	flooding := make([]bool, len(numbers))
+	if s.redis == nil || limit <= 0 {
+		return flooding
+	}
+
+	perNumber := make(map[string]int64)
+	for _, number := range numbers {
+		perNumber[number]++
+	}
+
+	counts := make(map[string]*redis.IntCmd, len(perNumber))
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for number, n := range perNumber {
+			key := "call_rate:" + number
+			pipe.SetNX(ctx, key, 0, s.floodWindow)
+			counts[number] = pipe.IncrBy(ctx, key, n)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to update call rates", "error", err)
+		return flooding
+	}
+
+	// The calls of a number take the last n slots of its window, in order
+	next := make(map[string]int64, len(perNumber))
+	for number, cmd := range counts {
+		next[number] = cmd.Val() - perNumber[number] + 1
+	}
+	for i, number := range numbers {
+		flooding[i] = next[number] > int64(limit)
+		next[number]++
+	}
+	return flooding
+}
+
+// blockedCall is the assigned_calls record of a rejected call
+func blockedCall(call models.IncomingCall, code, reason string) models.AssignedCall {
+	return models.AssignedCall{
+		CallID:         call.CallID,
+		CustomerNumber: call.CustomerNumber,
+		RawNumber:      call.RawNumber,
+		ReceivedAt:     call.Timestamp,
+		Timestamp:      call.Timestamp,
+		Status:         models.CallStatusBlocked,
+		StatusReason:   reason,
+		ReasonCode:     code,
+	}
+}
+
+func (s *callCenterService) BlockNumber(entry models.BlockedNumber) (*models.BlockedNumber, error) {
+	if entry.MatchType == "" {
+		entry.MatchType = models.BlockMatchExact
+	}
+	if entry.MatchType != models.BlockMatchExact && entry.MatchType != models.BlockMatchPrefix {
+		return nil, errors.New("match_type must be exact or prefix")
+	}
+
+	if err := s.db.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *callCenterService) ListBlockedNumbers() ([]models.BlockedNumber, error) {
+	var entries []models.BlockedNumber
+	if err := s.db.Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *callCenterService) UnblockNumber(id uint) error {
+	result := s.db.Delete(&models.BlockedNumber{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("blocklist entry not found")
+	}
+	return nil
+}
+
+// blockReasonCode is the reason code of a blocked call; calls blocked before codes were
+// stored are classified from their status reason
+const blockReasonCode = `CASE
+	WHEN reason_code <> '' THEN reason_code
+	WHEN status_reason LIKE 'call flood%' THEN 'flood'
+	ELSE 'blocklist'
+END`
+
+// GetBlockedCallStats counts blocked calls per reason code in [from, to)
+func (s *callCenterService) GetBlockedCallStats(from, to time.Time) ([]models.BlockedCallStat, error) {
+	var stats []models.BlockedCallStat
+	err := s.db.Model(&models.AssignedCall{}).
+		Select(blockReasonCode+" AS reason, COUNT(*) AS count").
+		Where("status = ? AND received_at >= ? AND received_at < ?", models.CallStatusBlocked, from, to).
+		Group("reason").
+		Order("count DESC").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
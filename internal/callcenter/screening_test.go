@@ -0,0 +1,172 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMatchBlockRule(t *testing.T) {
+	rules := map[string][]models.BlockedNumber{
+		"+1900":        {{Number: "+1900", MatchType: models.BlockMatchPrefix, Reason: "premium"}},
+		"+1900555":     {{Number: "+1900555", MatchType: models.BlockMatchPrefix, Reason: "scam range"}},
+		"+19005550100": {{Number: "+19005550100", MatchType: models.BlockMatchExact, Reason: "known spammer"}},
+		"+16502530000": {{Number: "+16502530000", MatchType: models.BlockMatchPrefix, Reason: "whole number as prefix"}},
+		"+4420":        {{Number: "+4420", MatchType: models.BlockMatchExact, Reason: "exact only"}},
+		"+1650253000%": {{Number: "+1650253000%", MatchType: models.BlockMatchPrefix, Reason: "not a pattern"}},
+	}
+
+	tests := []struct {
+		number string
+		want   string // reason of the matching rule, "" for none
+	}{
+		{number: "+19005550100", want: "known spammer"},
+		{number: "+19005550199", want: "scam range"},
+		{number: "+19001234567", want: "premium"},
+		{number: "+16502530000", want: "whole number as prefix"},
+		{number: "+442070313000", want: ""},
+		{number: "+16502530001", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			rule := matchBlockRule(tt.number, rules)
+			switch {
+			case tt.want == "" && rule != nil:
+				t.Errorf("matchBlockRule() = %q, want no match", rule.Reason)
+			case tt.want != "" && rule == nil:
+				t.Errorf("matchBlockRule() = no match, want %q", tt.want)
+			case tt.want != "" && rule.Reason != tt.want:
+				t.Errorf("matchBlockRule() = %q, want %q", rule.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestFloodingCalls(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		limit   int
+		batches [][]string
+		advance time.Duration // time passed before the last batch
+		want    []bool        // flags for the last batch
+	}{
+		{
+			name:    "within limit",
+			limit:   2,
+			batches: [][]string{{"a", "a", "b"}},
+			want:    []bool{false, false, false},
+		},
+		{
+			name:    "calls beyond the limit in one batch",
+			limit:   2,
+			batches: [][]string{{"a", "b", "a", "a", "a"}},
+			want:    []bool{false, false, false, true, true},
+		},
+		{
+			name:    "window carries across batches",
+			limit:   2,
+			batches: [][]string{{"a", "a"}, {"a", "b"}},
+			want:    []bool{true, false},
+		},
+		{
+			name:    "window expires",
+			limit:   2,
+			batches: [][]string{{"a", "a"}, {"a"}},
+			advance: 2 * time.Minute,
+			want:    []bool{false},
+		},
+		{
+			name:    "limit zero turns protection off",
+			limit:   0,
+			batches: [][]string{{"a", "a", "a"}},
+			want:    []bool{false, false, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := testutil.Redis(t)
+			s := &callCenterService{redis: client, floodWindow: time.Minute}
+
+			var got []bool
+			for i, batch := range tt.batches {
+				if i == len(tt.batches)-1 {
+					server.FastForward(tt.advance)
+				}
+				got = s.floodingCalls(ctx, batch, tt.limit)
+			}
+
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("floodingCalls() = %v, want %v", got, tt.want)
+				}
+			}
+			if tt.limit > 0 {
+				if ttl := server.TTL("call_rate:a"); ttl <= 0 || ttl > time.Minute {
+					t.Errorf("counter TTL = %v, want within the window", ttl)
+				}
+			}
+		})
+	}
+}
+
+func TestScreenCalls(t *testing.T) {
+	ctx := context.Background()
+	db, mock := testutil.MockDB(t)
+	client, _ := testutil.Redis(t)
+	s := &callCenterService{db: db, redis: client, floodWindow: time.Minute}
+
+	mock.ExpectQuery(`SELECT \* FROM "blocked_numbers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "match_type", "reason"}).
+			AddRow(1, "+1900", models.BlockMatchPrefix, "premium"))
+	// Blocked calls are saved in one statement that tolerates call IDs already recorded
+	mock.ExpectQuery(`INSERT INTO "assigned_calls" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	calls := []models.IncomingCall{
+		{CallID: "c1", CustomerNumber: "+19005550100"},
+		{CallID: "c2", CustomerNumber: "+16502530000"},
+		{CallID: "c3", CustomerNumber: "+16502530000"},
+	}
+	errs := s.screenCalls(ctx, calls, 1)
+
+	want := []string{"blocklisted: premium", "", "call flood: more than 1 calls within 1m0s"}
+	for i, reason := range want {
+		var blocked *BlockedError
+		switch {
+		case reason == "" && errs[i] != nil:
+			t.Errorf("call %d error = %v, want none", i, errs[i])
+		case reason != "" && !errors.As(errs[i], &blocked):
+			t.Errorf("call %d error = %v, want BlockedError", i, errs[i])
+		case reason != "" && blocked.Reason != reason:
+			t.Errorf("call %d reason = %q, want %q", i, blocked.Reason, reason)
+		}
+	}
+}
+
+func TestBlockedCallReasonCode(t *testing.T) {
+	tests := []struct {
+		code   string
+		reason string
+	}{
+		{code: models.BlockReasonBlocklist, reason: "blocklisted: premium"},
+		{code: models.BlockReasonFlood, reason: "call flood: more than 5 calls within 1m0s"},
+		{code: models.BlockReasonFlood, reason: "call flood: more than 10 calls within 5m0s"},
+	}
+
+	for _, tt := range tests {
+		record := blockedCall(models.IncomingCall{CallID: "c1"}, tt.code, tt.reason)
+		if record.Status != models.CallStatusBlocked || record.ReasonCode != tt.code || record.StatusReason != tt.reason {
+			t.Errorf("blockedCall() = status %q, code %q, reason %q; want blocked, %q, %q",
+				record.Status, record.ReasonCode, record.StatusReason, tt.code, tt.reason)
+		}
+	}
+}
@@ -4,20 +4,214 @@ import (
 	"call-center-api/models"
 	"call-center-api/pkg/database"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
+// publishBatchSize caps how many calls go to Kafka in one SendMessages round trip
+const publishBatchSize = 500
+
+// apiKeyPrefix marks keys issued by the call center so they are easy to spot in logs and configs
+const apiKeyPrefix = "cck_"
+
 type CallCenterService interface {
 	PublishCall(ctx context.Context, call models.IncomingCall) error
+	PublishCalls(ctx context.Context, calls []models.IncomingCall) []error
+	AbandonCall(ctx context.Context, callID string) (*models.AssignedCall, error)
+	GetCallStatus(ctx context.Context, callID string) (*models.CallStatus, error)
+	GetCallTimeline(callID string) ([]models.CallEvent, error)
+	ClaimProviderCall(ctx context.Context, provider, callID string) (bool, error)
+	ReleaseProviderCall(ctx context.Context, provider, callID string)
+	SetCallbackPolicy(maxAttempts int, retryDelay time.Duration)
+	CreateCallback(callback models.Callback) (*models.Callback, error)
+	GetCallback(id uint) (*models.Callback, error)
+	ListCallbacks(status string) ([]models.Callback, error)
+	CancelCallback(id uint) (*models.Callback, error)
+	StartCallbackScheduler(ctx context.Context)
+	BlockNumber(entry models.BlockedNumber) (*models.BlockedNumber, error)
+	ListBlockedNumbers() ([]models.BlockedNumber, error)
+	UnblockNumber(id uint) error
+	GetBlockedCallStats(from, to time.Time) ([]models.BlockedCallStat, error)
+	IssueAPIKey(name, integration string) (*models.APIKey, string, error)
+	ListAPIKeys() ([]models.APIKey, error)
+	RevokeAPIKey(id uint) (*models.APIKey, error)
+	AuthenticateAPIKey(key string) (*models.APIKey, error)
 }
 
 type callCenterService struct {
-	kafka *database.KafkaProducer
+	kafka           *database.KafkaProducer
+	db              *gorm.DB
+	redis           *redis.Client
+	floodLimit      int
+	floodBatchLimit int
+	floodWindow     time.Duration
+
+	callbackMaxAttempts int
+	callbackRetryDelay  time.Duration
 }
 
-func NewCallCenterService(kafka *database.KafkaProducer) CallCenterService {
-	return &callCenterService{kafka: kafka}
+func NewCallCenterService(
+	kafka *database.KafkaProducer,
+	db *gorm.DB,
+	redis *redis.Client,
+	floodLimit int,
+	floodBatchLimit int,
+	floodWindow time.Duration,
+) CallCenterService {
+	return &callCenterService{
+		kafka:           kafka,
+		db:              db,
+		redis:           redis,
+		floodLimit:      floodLimit,
+		floodBatchLimit: floodBatchLimit,
+		floodWindow:     floodWindow,
+	}
 }
 
 func (s *callCenterService) PublishCall(ctx context.Context, call models.IncomingCall) error {
-	return s.kafka.PublishIncomingCall(ctx, call)
+	if err := s.checkQueue(call); err != nil {
+		return err
+	}
+	if err := s.screenCall(ctx, call); err != nil {
+		return err
+	}
+	if err := s.kafka.PublishIncomingCall(ctx, call); err != nil {
+		return err
+	}
+	s.markAccepted(ctx, call)
+	return nil
+}
+
+// PublishCalls screens and publishes calls in chunks, returning an error slice aligned with calls.
+// Batches are often replays of earlier traffic, so they are held to the batch flood limit.
+func (s *callCenterService) PublishCalls(ctx context.Context, calls []models.IncomingCall) []error {
+	errs := make([]error, len(calls))
+	queued := make([]models.IncomingCall, 0, len(calls))
+	queuedIndex := make([]int, 0, len(calls))
+
+	for i, err := range s.checkQueues(calls) {
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		queued = append(queued, calls[i])
+		queuedIndex = append(queuedIndex, i)
+	}
+
+	allowed := make([]models.IncomingCall, 0, len(queued))
+	allowedIndex := make([]int, 0, len(queued))
+	for j, err := range s.screenCalls(ctx, queued, s.floodBatchLimit) {
+		if err != nil {
+			errs[queuedIndex[j]] = err
+			continue
+		}
+		allowed = append(allowed, queued[j])
+		allowedIndex = append(allowedIndex, queuedIndex[j])
+	}
+
+	for start := 0; start < len(allowed); start += publishBatchSize {
+		end := start + publishBatchSize
+		if end > len(allowed) {
+			end = len(allowed)
+		}
+		for j, err := range s.kafka.PublishIncomingCalls(ctx, allowed[start:end]) {
+			errs[allowedIndex[start+j]] = err
+			if err == nil {
+				s.markAccepted(ctx, allowed[start+j])
+			}
+		}
+	}
+	return errs
+}
+
+// IssueAPIKey creates a new key for an integration and returns it in plaintext exactly once
+func (s *callCenterService) IssueAPIKey(name, integration string) (*models.APIKey, string, error) {
+	if name == "" || integration == "" {
+		return nil, "", errors.New("name and integration are required")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey := &models.APIKey{
+		Name:        name,
+		Integration: integration,
+		Prefix:      key[:len(apiKeyPrefix)+8],
+		KeyHash:     hashAPIKey(key),
+	}
+
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, key, nil
+}
+
+func (s *callCenterService) ListAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *callCenterService) RevokeAPIKey(id uint) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.First(&apiKey, id).Error; err != nil {
+		return nil, errors.New("api key not found")
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := s.db.Save(&apiKey).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &apiKey, nil
+}
+
+// apiKeyUsageResolution is how stale last_used_at may get before a request refreshes it
+const apiKeyUsageResolution = time.Minute
+
+// AuthenticateAPIKey resolves a plaintext key to an active API key and records its use.
+// last_used_at is only written once it is older than apiKeyUsageResolution, so a busy
+// integration does not update the key's row on every request.
+func (s *callCenterService) AuthenticateAPIKey(key string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := s.db.Where("key_hash = ? AND revoked_at IS NULL", hashAPIKey(key)).First(&apiKey).Error; err != nil {
+		return nil, errors.New("invalid api key")
+	}
+
+	now := time.Now()
+	if apiKey.LastUsedAt != nil && now.Sub(*apiKey.LastUsedAt) < apiKeyUsageResolution {
+		return &apiKey, nil
+	}
+
+	// The condition keeps concurrent requests from all writing the same refresh
+	err := s.db.Model(&apiKey).
+		Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-apiKeyUsageResolution)).
+		UpdateColumn("last_used_at", now).Error
+	if err != nil {
+		return nil, err
+	}
+	apiKey.LastUsedAt = &now
+
+	return &apiKey, nil
+}
+
+// hashAPIKey returns the hex SHA-256 digest used to look keys up without storing them
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
 }
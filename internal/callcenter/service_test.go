@@ -0,0 +1,60 @@
+package callcenter
+
+import (
+	"call-center-api/pkg/testutil"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	recent := time.Now().Add(-10 * time.Second)
+	stale := time.Now().Add(-5 * time.Minute)
+
+	tests := []struct {
+		name      string
+		lastUsed  interface{}
+		wantWrite bool
+	}{
+		{name: "never used", lastUsed: nil, wantWrite: true},
+		{name: "used a while ago", lastUsed: stale, wantWrite: true},
+		{name: "used within the last minute", lastUsed: recent, wantWrite: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := &callCenterService{db: db}
+
+			mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE \(key_hash = \$1 AND revoked_at IS NULL\) AND "api_keys"."deleted_at" IS NULL`).
+				WithArgs(hashAPIKey("secret")).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "last_used_at"}).AddRow(7, "pbx", tt.lastUsed))
+			if tt.wantWrite {
+				mock.ExpectExec(`UPDATE "api_keys" SET "last_used_at"=\$1 WHERE \(last_used_at IS NULL OR last_used_at < \$2\) AND .*"id" = \$3`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			apiKey, err := s.AuthenticateAPIKey("secret")
+			if err != nil {
+				t.Fatalf("AuthenticateAPIKey() error = %v", err)
+			}
+			if apiKey.LastUsedAt == nil {
+				t.Fatal("LastUsedAt is nil")
+			}
+			if !tt.wantWrite && !apiKey.LastUsedAt.Equal(recent) {
+				t.Errorf("LastUsedAt = %v, want the stored %v", apiKey.LastUsedAt, recent)
+			}
+		})
+	}
+
+	t.Run("unknown key", func(t *testing.T) {
+		db, mock := testutil.MockDB(t)
+		s := &callCenterService{db: db}
+		mock.ExpectQuery(`SELECT \* FROM "api_keys"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if _, err := s.AuthenticateAPIKey("nope"); err == nil {
+			t.Error("AuthenticateAPIKey() succeeded for an unknown key")
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// throughputWindow is how far back assignments are counted to estimate wait times
+	throughputWindow = 15 * time.Minute
+	// acceptedCallTTL is how long a published call reports as queued before the distributor picks it up
+	acceptedCallTTL = 10 * time.Minute
+)
+
+// ErrCallNotFound is returned when a call is neither queued nor recorded
+var ErrCallNotFound = errors.New("call not found")
+
+// markAccepted remembers a published call so status lookups report it as queued until the
+// distributor parks it. The queue structures themselves are written only by the distributor.
+func (s *callCenterService) markAccepted(ctx context.Context, call models.IncomingCall) {
+	queue := call.Queue
+	if queue == "" {
+		queue = models.DefaultQueue
+	}
+
+	if err := s.redis.Set(ctx, "accepted_call:"+call.CallID, queue, acceptedCallTTL).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to track accepted call", "call_id", call.CallID, "error", err)
+	}
+}
+
+// providerCallTTL is how long a provider's call ID is remembered to reject retried or replayed webhooks
+const providerCallTTL = 24 * time.Hour
+
+// ClaimProviderCall records a provider's call ID and reports whether this is the first time it was seen
+func (s *callCenterService) ClaimProviderCall(ctx context.Context, provider, callID string) (bool, error) {
+	return s.redis.SetNX(ctx, providerCallKey(provider, callID), time.Now().Unix(), providerCallTTL).Result()
+}
+
+// ReleaseProviderCall forgets a claimed provider call ID so the provider's retry can be accepted
+func (s *callCenterService) ReleaseProviderCall(ctx context.Context, provider, callID string) {
+	if callID == "" {
+		return
+	}
+	if err := s.redis.Del(ctx, providerCallKey(provider, callID)).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to release webhook call ID", "provider", provider, "call_id", callID, "error", err)
+	}
+}
+
+func providerCallKey(provider, callID string) string {
+	return "webhook_call:" + provider + ":" + callID
+}
+
+// GetCallStatus reports a call's lifecycle state from assigned_calls, or its queue position while waiting
+func (s *callCenterService) GetCallStatus(ctx context.Context, callID string) (*models.CallStatus, error) {
+	var call models.AssignedCall
+	err := s.db.Where("call_id = ?", callID).First(&call).Error
+	if err == nil {
+		status := &models.CallStatus{
+			CallID:          call.CallID,
+			Queue:           call.Queue,
+			Status:          call.Status,
+			StatusReason:    call.StatusReason,
+			AssignedAgentID: call.AssignedAgentID,
+			AbandonedAt:     call.AbandonedAt,
+		}
+		if !call.ReceivedAt.IsZero() {
+			status.ReceivedAt = &call.ReceivedAt
+		}
+		if call.AssignedAgentID != "" {
+			status.AssignedAt = &call.Timestamp
+		}
+		return status, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	queue, err := s.redis.HGet(ctx, "call_queue_index", callID).Result()
+	if err == redis.Nil {
+		// Published but not yet parked by the distributor
+		accepted, err := s.redis.Get(ctx, "accepted_call:"+callID).Result()
+		if err == redis.Nil {
+			return nil, ErrCallNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &models.CallStatus{CallID: callID, Queue: accepted, Status: models.CallStatusQueued}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rank, err := s.redis.ZRank(ctx, "call_queue:"+queue, callID).Result()
+	if err == redis.Nil {
+		return nil, ErrCallNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	position := rank + 1
+	status := &models.CallStatus{
+		CallID:        callID,
+		Queue:         queue,
+		Status:        models.CallStatusQueued,
+		QueuePosition: &position,
+	}
+
+	if score, err := s.redis.ZScore(ctx, "call_queue:"+queue, callID).Result(); err == nil {
+		receivedAt := time.Unix(0, int64(score))
+		status.ReceivedAt = &receivedAt
+	}
+
+	if abandoned, _ := s.redis.Exists(ctx, "abandoned_call:"+callID).Result(); abandoned > 0 {
+		status.Status = models.CallStatusAbandoned
+		status.QueuePosition = nil
+		return status, nil
+	}
+
+	if wait, ok := s.estimateWait(queue, position); ok {
+		status.EstimatedWaitSeconds = &wait
+	}
+
+	return status, nil
+}
+
+// estimateWait divides the queue position by the queue's recent assignment rate
+func (s *callCenterService) estimateWait(queue string, position int64) (int64, bool) {
+	var assigned int64
+	err := s.db.Model(&models.AssignedCall{}).
+		Where("queue = ? AND assigned_agent_id <> '' AND timestamp >= ?", queue, time.Now().Add(-throughputWindow)).
+		Count(&assigned).Error
+	if err != nil || assigned == 0 {
+		return 0, false
+	}
+
+	perCall := throughputWindow.Seconds() / float64(assigned)
+	return int64(float64(position) * perCall), true
+}
+
+// GetCallTimeline returns the routing events recorded for a call, oldest first
+func (s *callCenterService) GetCallTimeline(callID string) ([]models.CallEvent, error) {
+	var events []models.CallEvent
+	if err := s.db.Where("call_id = ?", callID).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrCallNotFound
+	}
+	return events, nil
+}
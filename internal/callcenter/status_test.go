@@ -0,0 +1,114 @@
+package callcenter
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGetCallStatus(t *testing.T) {
+	ctx := context.Background()
+	received := time.Date(2030, 1, 14, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		recorded     *sqlmock.Rows
+		setup        func(client *redis.Client)
+		assignedRate int // assignments in the throughput window, -1 when not looked up
+		wantErr      error
+		wantStatus   string
+		wantQueue    string
+		wantPosition int64
+		wantWait     int64
+	}{
+		{
+			name: "recorded call",
+			recorded: sqlmock.NewRows([]string{"call_id", "queue", "status", "assigned_agent_id", "received_at", "timestamp"}).
+				AddRow("c1", "sales", models.CallStatusAssigned, "a1", received, received.Add(time.Minute)),
+			assignedRate: -1,
+			wantStatus:   models.CallStatusAssigned, wantQueue: "sales",
+		},
+		{
+			name:         "published, not yet parked",
+			setup:        func(client *redis.Client) { client.Set(ctx, "accepted_call:c1", "sales", 0) },
+			assignedRate: -1,
+			wantStatus:   models.CallStatusQueued, wantQueue: "sales",
+		},
+		{
+			name: "second in its queue",
+			setup: func(client *redis.Client) {
+				client.HSet(ctx, "call_queue_index", "c1", "sales")
+				client.ZAdd(ctx, "call_queue:sales",
+					redis.Z{Score: float64(received.Add(-time.Minute).UnixNano()), Member: "c0"},
+					redis.Z{Score: float64(received.UnixNano()), Member: "c1"})
+			},
+			// 30 assignments in 15 minutes is one every 30 seconds
+			assignedRate: 30,
+			wantStatus:   models.CallStatusQueued, wantQueue: "sales", wantPosition: 2, wantWait: 60,
+		},
+		{
+			name: "queued but abandoned",
+			setup: func(client *redis.Client) {
+				client.HSet(ctx, "call_queue_index", "c1", "sales")
+				client.ZAdd(ctx, "call_queue:sales", redis.Z{Score: float64(received.UnixNano()), Member: "c1"})
+				client.Set(ctx, "abandoned_call:c1", received.Format(time.RFC3339Nano), 0)
+			},
+			assignedRate: -1,
+			wantStatus:   models.CallStatusAbandoned, wantQueue: "sales",
+		},
+		{name: "unknown call", assignedRate: -1, wantErr: ErrCallNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			client, _ := testutil.Redis(t)
+			s := &callCenterService{db: db, redis: client}
+			if tt.setup != nil {
+				tt.setup(client)
+			}
+
+			rows := tt.recorded
+			if rows == nil {
+				rows = sqlmock.NewRows([]string{"call_id"})
+			}
+			mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE call_id = \$1`).WillReturnRows(rows)
+			if tt.assignedRate >= 0 {
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "assigned_calls" WHERE \(queue = \$1`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.assignedRate))
+			}
+
+			status, err := s.GetCallStatus(ctx, "c1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetCallStatus() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if status.Status != tt.wantStatus || status.Queue != tt.wantQueue {
+				t.Errorf("status = %s in %s, want %s in %s", status.Status, status.Queue, tt.wantStatus, tt.wantQueue)
+			}
+			if tt.wantPosition == 0 {
+				if status.QueuePosition != nil {
+					t.Errorf("queue position = %d, want none", *status.QueuePosition)
+				}
+				return
+			}
+			if status.QueuePosition == nil || *status.QueuePosition != tt.wantPosition {
+				t.Errorf("queue position = %v, want %d", status.QueuePosition, tt.wantPosition)
+			}
+			if status.EstimatedWaitSeconds == nil || *status.EstimatedWaitSeconds != tt.wantWait {
+				t.Errorf("estimated wait = %v, want %d", status.EstimatedWaitSeconds, tt.wantWait)
+			}
+			if status.ReceivedAt == nil || !status.ReceivedAt.Equal(received) {
+				t.Errorf("received at = %v, want %v", status.ReceivedAt, received)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package callcenter
+
+import (
+	"bytes"
+	"call-center-api/models"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature schemes a webhook profile can verify
+const (
+	// SchemeTimestamp signs "<timestamp>.<body>" and rejects timestamps outside the replay window
+	SchemeTimestamp = "timestamp"
+	// SchemeTwilio is Twilio's X-Twilio-Signature: HMAC-SHA1 over the full request URL followed
+	// by the sorted POST parameters, or over the URL alone with a bodySHA256 query parameter
+	// for JSON bodies
+	SchemeTwilio = "twilio"
+)
+
+// WebhookProfile describes how a telephony provider signs and shapes its webhooks
+type WebhookProfile struct {
+	Name              string `json:"name"`
+	Secret            string `json:"secret"`
+	SignatureScheme   string `json:"signature_scheme"` // timestamp (default) or twilio
+	SignatureHeader   string `json:"signature_header"`
+	TimestampHeader   string `json:"timestamp_header"`
+	SignatureAlgo     string `json:"signature_algo"`     // sha256 (default) or sha1
+	SignatureEncoding string `json:"signature_encoding"` // hex (default) or base64
+	// BaseURL is the public scheme and host the provider calls, for schemes that sign the URL.
+	// Defaults to the request's own base URL, which may differ behind a proxy.
+	BaseURL        string `json:"base_url"`
+	CallerField    string `json:"caller_field"`
+	CalledField    string `json:"called_field"`
+	CallIDField    string `json:"call_id_field"`
+	DirectionField string `json:"direction_field"`
+}
+
+// DefaultWebhookProfiles returns the built-in provider mappings
+func DefaultWebhookProfiles() map[string]WebhookProfile {
+	return map[string]WebhookProfile{
+		"generic": {
+			Name:            "generic",
+			SignatureHeader: "X-Signature",
+			TimestampHeader: "X-Timestamp",
+			CallerField:     "caller_number",
+			CalledField:     "called_number",
+			CallIDField:     "call_sid",
+			DirectionField:  "direction",
+		},
+		"twilio": {
+			Name:            "twilio",
+			SignatureScheme: SchemeTwilio,
+			SignatureHeader: "X-Twilio-Signature",
+			CallerField:     "From",
+			CalledField:     "To",
+			CallIDField:     "CallSid",
+			DirectionField:  "Direction",
+		},
+	}
+}
+
+// LoadWebhookProfiles merges profiles from a JSON file over the built-in ones.
+// Profiles without their own secret fall back to defaultSecret.
+func LoadWebhookProfiles(path, defaultSecret string) (map[string]WebhookProfile, error) {
+	profiles := DefaultWebhookProfiles()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhook profiles: %w", err)
+		}
+
+		var custom []WebhookProfile
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("failed to parse webhook profiles: %w", err)
+		}
+
+		for _, profile := range custom {
+			if profile.Name == "" {
+				return nil, errors.New("webhook profile name is required")
+			}
+			profiles[profile.Name] = profile
+		}
+	}
+
+	for name, profile := range profiles {
+		if profile.Secret == "" {
+			profile.Secret = defaultSecret
+		}
+		if profile.SignatureHeader == "" {
+			profile.SignatureHeader = "X-Signature"
+		}
+		if profile.SignatureScheme == "" {
+			profile.SignatureScheme = SchemeTimestamp
+		}
+		if profile.SignatureScheme != SchemeTimestamp && profile.SignatureScheme != SchemeTwilio {
+			return nil, fmt.Errorf("webhook profile %s: unknown signature scheme %q", name, profile.SignatureScheme)
+		}
+		if profile.TimestampHeader == "" && profile.SignatureScheme == SchemeTimestamp {
+			profile.TimestampHeader = "X-Timestamp"
+		}
+		profiles[name] = profile
+	}
+
+	return profiles, nil
+}
+
+// WebhookRequest is the part of an inbound webhook a signature covers
+type WebhookRequest struct {
+	URL         string // full URL the provider called, including the query string
+	ContentType string
+	Signature   string
+	Timestamp   string
+	Body        []byte
+}
+
+// Verify checks the request's signature according to the profile's scheme
+func (p WebhookProfile) Verify(req WebhookRequest, window time.Duration, now time.Time) error {
+	if p.Secret == "" {
+		return errors.New("webhook secret not configured")
+	}
+	if p.SignatureScheme == SchemeTwilio {
+		return p.verifyTwilio(req)
+	}
+	return p.verifyTimestamped(req.Signature, req.Timestamp, req.Body, window, now)
+}
+
+// verifyTimestamped checks the signature over "<timestamp>.<body>" and that the timestamp is within the replay window
+func (p WebhookProfile) verifyTimestamped(signature, timestamp string, body []byte, window time.Duration, now time.Time) error {
+	if signature == "" || timestamp == "" {
+		return errors.New("missing signature or timestamp")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age < 0 {
+		age = -age
+	}
+	if age > window {
+		return errors.New("timestamp outside replay window")
+	}
+
+	var newHash func() hash.Hash = sha256.New
+	if strings.EqualFold(p.SignatureAlgo, "sha1") {
+		newHash = sha1.New
+	}
+
+	mac := hmac.New(newHash, []byte(p.Secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	var expected []byte
+	if strings.EqualFold(p.SignatureEncoding, "base64") {
+		expected = []byte(base64.StdEncoding.EncodeToString(sum))
+	} else {
+		expected = []byte(hex.EncodeToString(sum))
+	}
+
+	if !hmac.Equal(expected, []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// verifyTwilio checks an X-Twilio-Signature. Twilio sends no timestamp, so replays are
+// stopped by deduplicating on the call SID instead.
+func (p WebhookProfile) verifyTwilio(req WebhookRequest) error {
+	if req.Signature == "" {
+		return errors.New("missing signature")
+	}
+
+	signed := req.URL
+	if strings.Contains(req.ContentType, "application/json") {
+		// JSON bodies are covered by a SHA-256 of the body in the signed URL
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return errors.New("invalid request URL")
+		}
+		sum := sha256.Sum256(req.Body)
+		if !hmac.Equal([]byte(u.Query().Get("bodySHA256")), []byte(hex.EncodeToString(sum[:]))) {
+			return errors.New("body hash mismatch")
+		}
+	} else {
+		values, err := url.ParseQuery(string(req.Body))
+		if err != nil {
+			return errors.New("invalid form body")
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var b strings.Builder
+		b.WriteString(req.URL)
+		for _, key := range keys {
+			for _, value := range values[key] {
+				b.WriteString(key)
+				b.WriteString(value)
+			}
+		}
+		signed = b.String()
+	}
+
+	mac := hmac.New(sha1.New, []byte(p.Secret))
+	mac.Write([]byte(signed))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(req.Signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// MapCall converts a provider payload into an IncomingCall.
+// For outbound calls the customer is the called party rather than the caller.
+func (p WebhookProfile) MapCall(fields map[string]string) (models.IncomingCall, error) {
+	call := models.IncomingCall{
+		CallID:       fields[p.CallIDField],
+		CalledNumber: fields[p.CalledField],
+		Direction:    normalizeDirection(fields[p.DirectionField]),
+		Source:       p.Name,
+	}
+
+	call.CustomerNumber = fields[p.CallerField]
+	if call.Direction == "outbound" {
+		call.CustomerNumber, call.CalledNumber = call.CalledNumber, call.CustomerNumber
+	}
+
+	if call.CustomerNumber == "" {
+		return call, errors.New("customer number missing from payload")
+	}
+	return call, nil
+}
+
+// ParseWebhookFields flattens a JSON object or form-encoded body into string fields
+func ParseWebhookFields(contentType string, body []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	if strings.Contains(contentType, "application/json") {
+		// Numbers are kept as sent, so a phone number posted as a JSON number is not
+		// reformatted as a float (15551234567 would become 1.5551234567e+10)
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			return nil, errors.New("unexpected data after JSON object")
+		}
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				fields[key] = v
+			case json.Number:
+				fields[key] = v.String()
+			case nil:
+			default:
+				fields[key] = fmt.Sprint(v)
+			}
+		}
+		return fields, nil
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for key := range values {
+		fields[key] = values.Get(key)
+	}
+	return fields, nil
+}
+
+// normalizeDirection folds provider variants such as "outbound-api" into inbound/outbound
+func normalizeDirection(direction string) string {
+	if strings.HasPrefix(strings.ToLower(direction), "outbound") {
+		return "outbound"
+	}
+	return "inbound"
+}
@@ -0,0 +1,266 @@
+package callcenter
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signTimestamped(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signTwilio(secret, signed string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookProfileVerifyTimestamp(t *testing.T) {
+	profile := WebhookProfile{Name: "generic", Secret: "secret", SignatureScheme: SchemeTimestamp}
+	now := time.Unix(1900000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := `{"call_sid":"CA1"}`
+
+	tests := []struct {
+		name    string
+		req     WebhookRequest
+		now     time.Time
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			req:  WebhookRequest{Signature: signTimestamped("secret", timestamp, body), Timestamp: timestamp, Body: []byte(body)},
+			now:  now,
+		},
+		{
+			name: "within window",
+			req:  WebhookRequest{Signature: signTimestamped("secret", timestamp, body), Timestamp: timestamp, Body: []byte(body)},
+			now:  now.Add(4 * time.Minute),
+		},
+		{
+			name:    "outside window",
+			req:     WebhookRequest{Signature: signTimestamped("secret", timestamp, body), Timestamp: timestamp, Body: []byte(body)},
+			now:     now.Add(6 * time.Minute),
+			wantErr: true,
+		},
+		{
+			name:    "tampered body",
+			req:     WebhookRequest{Signature: signTimestamped("secret", timestamp, body), Timestamp: timestamp, Body: []byte(`{"call_sid":"CA2"}`)},
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name:    "wrong secret",
+			req:     WebhookRequest{Signature: signTimestamped("other", timestamp, body), Timestamp: timestamp, Body: []byte(body)},
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name:    "missing timestamp",
+			req:     WebhookRequest{Signature: signTimestamped("secret", timestamp, body), Body: []byte(body)},
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			req:     WebhookRequest{Signature: signTimestamped("secret", "soon", body), Timestamp: "soon", Body: []byte(body)},
+			now:     now,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := profile.Verify(tt.req, 5*time.Minute, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookProfileVerifyTwilio(t *testing.T) {
+	profile := DefaultWebhookProfiles()["twilio"]
+	profile.Secret = "12345"
+
+	const formURL = "https://mycompany.com/myapp.php?foo=1&bar=2"
+	const form = "CallSid=CA1234567890ABCDE&Caller=%2B12349013030&Digits=1234&From=%2B12349013030&To=%2B18005551212"
+
+	jsonBody := `{"CallSid":"CA1","From":"+12349013030"}`
+	sum := sha256.Sum256([]byte(jsonBody))
+	jsonURL := "https://mycompany.com/voice?bodySHA256=" + hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		req     WebhookRequest
+		wantErr bool
+	}{
+		{
+			// Example from Twilio's webhook security documentation
+			name: "form body",
+			req: WebhookRequest{URL: formURL, ContentType: "application/x-www-form-urlencoded",
+				Signature: "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", Body: []byte(form)},
+		},
+		{
+			name: "form body signed for another URL",
+			req: WebhookRequest{URL: "https://mycompany.com/other.php", ContentType: "application/x-www-form-urlencoded",
+				Signature: "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", Body: []byte(form)},
+			wantErr: true,
+		},
+		{
+			name: "tampered form body",
+			req: WebhookRequest{URL: formURL, ContentType: "application/x-www-form-urlencoded",
+				Signature: "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", Body: []byte(form + "&Extra=1")},
+			wantErr: true,
+		},
+		{
+			name: "json body",
+			req: WebhookRequest{URL: jsonURL, ContentType: "application/json",
+				Signature: signTwilio("12345", jsonURL), Body: []byte(jsonBody)},
+		},
+		{
+			name: "tampered json body",
+			req: WebhookRequest{URL: jsonURL, ContentType: "application/json",
+				Signature: signTwilio("12345", jsonURL), Body: []byte(`{"CallSid":"CA2"}`)},
+			wantErr: true,
+		},
+		{
+			name: "missing signature",
+			req: WebhookRequest{URL: formURL, ContentType: "application/x-www-form-urlencoded",
+				Body: []byte(form)},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Twilio signatures carry no timestamp, so the replay window does not apply
+			err := profile.Verify(tt.req, time.Minute, time.Unix(0, 0))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookProfileMapCall(t *testing.T) {
+	profiles := DefaultWebhookProfiles()
+
+	tests := []struct {
+		name      string
+		profile   string
+		fields    map[string]string
+		callID    string
+		customer  string
+		called    string
+		direction string
+		wantErr   bool
+	}{
+		{
+			name:    "twilio inbound",
+			profile: "twilio",
+			fields:  map[string]string{"CallSid": "CA1", "From": "+12349013030", "To": "+18005551212", "Direction": "inbound"},
+			callID:  "CA1", customer: "+12349013030", called: "+18005551212", direction: "inbound",
+		},
+		{
+			name:    "twilio outbound swaps parties",
+			profile: "twilio",
+			fields:  map[string]string{"CallSid": "CA2", "From": "+18005551212", "To": "+12349013030", "Direction": "outbound-api"},
+			callID:  "CA2", customer: "+12349013030", called: "+18005551212", direction: "outbound",
+		},
+		{
+			name:    "generic without direction",
+			profile: "generic",
+			fields:  map[string]string{"call_sid": "g1", "caller_number": "+12349013030"},
+			callID:  "g1", customer: "+12349013030", direction: "inbound",
+		},
+		{
+			name:    "missing caller",
+			profile: "twilio",
+			fields:  map[string]string{"CallSid": "CA3", "To": "+18005551212"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call, err := profiles[tt.profile].MapCall(tt.fields)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MapCall() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if call.CallID != tt.callID || call.CustomerNumber != tt.customer ||
+				call.CalledNumber != tt.called || call.Direction != tt.direction {
+				t.Errorf("MapCall() = %+v, want call %s from %s to %s (%s)",
+					call, tt.callID, tt.customer, tt.called, tt.direction)
+			}
+			if call.Source != tt.profile {
+				t.Errorf("Source = %q, want %q", call.Source, tt.profile)
+			}
+		})
+	}
+}
+
+func TestParseWebhookFields(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        map[string]string
+		wantErr     bool
+	}{
+		{
+			name:        "json strings",
+			contentType: "application/json",
+			body:        `{"call_sid": "g1", "caller_number": "+12349013030"}`,
+			want:        map[string]string{"call_sid": "g1", "caller_number": "+12349013030"},
+		},
+		{
+			name:        "json numbers keep their digits",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"call_sid": 9007199254740993, "caller_number": 15551234567, "duration": 12.5}`,
+			want:        map[string]string{"call_sid": "9007199254740993", "caller_number": "15551234567", "duration": "12.5"},
+		},
+		{
+			name:        "json null and bool",
+			contentType: "application/json",
+			body:        `{"called_number": null, "recorded": true}`,
+			want:        map[string]string{"recorded": "true"},
+		},
+		{
+			name:        "json trailing data",
+			contentType: "application/json",
+			body:        `{"call_sid": "g1"} {}`,
+			wantErr:     true,
+		},
+		{
+			name:        "form encoded",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "CallSid=CA1&From=%2B12349013030",
+			want:        map[string]string{"CallSid": "CA1", "From": "+12349013030"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWebhookFields(tt.contentType, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWebhookFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseWebhookFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
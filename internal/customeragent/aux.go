@@ -0,0 +1,40 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"errors"
+)
+
+func (s *agentService) CreateAuxCode(code models.AuxCode) (*models.AuxCode, error) {
+	code.ID = 0
+	if code.Code == "" || code.Name == "" {
+		return nil, errors.New("code and name are required")
+	}
+	if code.MaxDurationSeconds < 0 {
+		return nil, errors.New("max_duration_seconds must not be negative")
+	}
+
+	if err := s.db.Create(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (s *agentService) ListAuxCodes() ([]models.AuxCode, error) {
+	var codes []models.AuxCode
+	if err := s.db.Order("code ASC").Find(&codes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *agentService) DeleteAuxCode(id uint) error {
+	result := s.db.Delete(&models.AuxCode{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("aux code not found")
+	}
+	return nil
+}
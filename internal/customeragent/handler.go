@@ -4,15 +4,25 @@ import (
 	"call-center-api/models"
 	"call-center-api/pkg/config"
 	"call-center-api/pkg/database"
+	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
+	"go.opentelemetry.io/otel/attribute"
 	"gorm.io/gorm"
 )
 
+var tracer = tracing.Tracer("call-center-api/customeragent")
+
 type AgentHandler struct {
 	service       AgentService
 	db            *gorm.DB
@@ -47,7 +57,7 @@ func (h *AgentHandler) RegisterAdmin(c *fiber.Ctx) error {
 		})
 	}
 
-	agent, err := h.service.RegisterAgent(req.Name, req.Password, false)
+	agent, err := h.service.RegisterAgent(req.Name, req.Password, "", false)
 	if err != nil {
 		return c.Status(500).JSON(models.ErrorResponse{
 			Success: false,
@@ -133,6 +143,30 @@ func (h *AgentHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+// Logout ends the session behind the caller's token, taking the agent out of routing
+func (h *AgentHandler) Logout(c *fiber.Ctx) error {
+	sessionID, _ := c.Locals("session_id").(string)
+	if sessionID == "" {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Token has no session",
+		})
+	}
+
+	if err := h.service.Logout(c.Locals("agent_id").(string), sessionID); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to log out",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Logged out successfully",
+	})
+}
+
 func (h *AgentHandler) GetCalls(c *fiber.Ctx) error {
 	agentID := c.Locals("agent_id").(string)
 
@@ -164,8 +198,9 @@ func (h *AgentHandler) CompleteCall(c *fiber.Ctx) error {
 	callID := c.Params("id")
 
 	var req struct {
-		Notes  string `json:"notes"`
-		Status string `json:"status"`
+		Notes      string     `json:"notes"`
+		Status     string     `json:"status"`
+		CallbackAt *time.Time `json:"callback_at"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -175,15 +210,30 @@ func (h *AgentHandler) CompleteCall(c *fiber.Ctx) error {
 		})
 	}
 
-	call, err := h.service.CompleteCall(callID, agentID, req.Notes, req.Status)
+	call, err := h.service.CompleteCall(callID, agentID, req.Notes, req.Status, req.CallbackAt)
 	if err != nil {
-		return c.Status(500).JSON(models.ErrorResponse{
+		status := 500
+		switch {
+		case errors.Is(err, ErrInvalidCompletionStatus):
+			status = 400
+		case errors.Is(err, ErrCallNotActive):
+			status = 409
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
 			Success: false,
 			Message: "Failed to complete call",
 			Error:   err.Error(),
 		})
 	}
 
+	// Publish the status change so lifecycle consumers see the completion
+	if h.kafkaProducer != nil {
+		ctx := logger.WithAgentID(logger.WithCallID(c.UserContext(), callID), agentID)
+		if err := h.kafkaProducer.PublishAssignedCall(ctx, *call); err != nil {
+			slog.WarnContext(ctx, "Failed to publish call completion event", "error", err)
+		}
+	}
+
 	return c.JSON(models.Response{
 		Success: true,
 		Message: "Call completed successfully",
@@ -191,10 +241,220 @@ func (h *AgentHandler) CompleteCall(c *fiber.Ctx) error {
 	})
 }
 
+// GetState returns the calling agent's routing state
+func (h *AgentHandler) GetState(c *fiber.Ctx) error {
+	agent, err := h.service.GetAgentState(c.Locals("agent_id").(string))
+	if err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Agent not found",
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    agent,
+	})
+}
+
+// SetState moves the calling agent to available or to an aux code
+func (h *AgentHandler) SetState(c *fiber.Ctx) error {
+	var req models.SetAgentStateRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	agent, err := h.service.SetAgentState(c.Locals("agent_id").(string), req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to change state",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "State updated successfully",
+		Data:    agent,
+	})
+}
+
+// EndWrapUp ends the calling agent's wrap-up period early
+func (h *AgentHandler) EndWrapUp(c *fiber.Ctx) error {
+	agent, err := h.service.EndWrapUp(c.Locals("agent_id").(string))
+	if err != nil {
+		return c.Status(409).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to end wrap-up",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Wrap-up ended",
+		Data:    agent,
+	})
+}
+
+func (h *AgentHandler) CreateAuxCode(c *fiber.Ctx) error {
+	var req models.AuxCode
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	code, err := h.service.CreateAuxCode(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create aux code",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(models.Response{
+		Success: true,
+		Message: "Aux code created successfully",
+		Data:    code,
+	})
+}
+
+func (h *AgentHandler) ListAuxCodes(c *fiber.Ctx) error {
+	codes, err := h.service.ListAuxCodes()
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch aux codes",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    codes,
+	})
+}
+
+func (h *AgentHandler) DeleteAuxCode(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid aux code ID",
+		})
+	}
+
+	if err := h.service.DeleteAuxCode(uint(id)); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Aux code not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Aux code deleted successfully",
+	})
+}
+
+// GetAgentStateReport reports time per state, occupancy and aux adherence per agent;
+// defaults to the last 24 hours
+func (h *AgentHandler) GetAgentStateReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	report, err := h.service.GetAgentStateReport(from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to build agent state report",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// ListSessions lists sign-in sessions, optionally for one agent; defaults to the last 24 hours
+func (h *AgentHandler) ListSessions(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	sessions, err := h.service.ListSessions(c.Query("agent_id"), from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch sessions",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    sessions,
+	})
+}
+
 func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 	var req struct {
 		AgentName string `json:"agent_name"`
 		Password  string `json:"password"`
+		Team      string `json:"team"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -204,7 +464,7 @@ func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 		})
 	}
 
-	agent, err := h.service.RegisterAgent(req.AgentName, req.Password, false)
+	agent, err := h.service.RegisterAgent(req.AgentName, req.Password, req.Team, false)
 	if err != nil {
 		return c.Status(500).JSON(models.ErrorResponse{
 			Success: false,
@@ -217,11 +477,12 @@ func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 	if h.kafkaProducer != nil {
 		agentData, _ := json.Marshal(agent)
 		key := fmt.Sprintf("create_agent:%s", agent.ID)
-		if err := h.kafkaProducer.PublishMessage(context.Background(), key, agentData); err != nil {
-			fmt.Printf("Warning: Failed to publish agent creation event: %v\n", err)
+		ctx := logger.WithAgentID(c.UserContext(), agent.ID)
+		if err := h.kafkaProducer.PublishMessage(ctx, key, agentData); err != nil {
+			slog.WarnContext(ctx, "Failed to publish agent creation event", "error", err)
 			// Don't fail the request if Kafka publish fails
 		} else {
-			fmt.Printf("Published agent creation event: %s\n", key)
+			slog.InfoContext(ctx, "Published agent creation event", "key", key)
 		}
 	}
 
@@ -232,8 +493,33 @@ func (h *AgentHandler) CreateAgent(c *fiber.Ctx) error {
 	})
 }
 
+// GetAgentStats aggregates call metrics per agent, filtered by queue and team; defaults to all time
 func (h *AgentHandler) GetAgentStats(c *fiber.Ctx) error {
-	stats, err := h.service.GetAgentStats()
+	var from time.Time
+	to := time.Now()
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	stats, err := h.service.GetAgentStats(from, to, c.Query("queue"), c.Query("team"))
 	if err != nil {
 		return c.Status(500).JSON(models.ErrorResponse{
 			Success: false,
@@ -248,6 +534,62 @@ func (h *AgentHandler) GetAgentStats(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AgentHandler) SetAgentCapacity(c *fiber.Ctx) error {
+	var req struct {
+		MaxConcurrentCalls int `json:"max_concurrent_calls"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	agent, err := h.service.SetAgentCapacity(c.Params("id"), req.MaxConcurrentCalls)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to update agent capacity",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Agent capacity updated successfully",
+		Data:    agent,
+	})
+}
+
+func (h *AgentHandler) SetAgentTeam(c *fiber.Ctx) error {
+	var req struct {
+		Team string `json:"team"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	agent, err := h.service.SetAgentTeam(c.Params("id"), req.Team)
+	if err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to update agent team",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Agent team updated successfully",
+		Data:    agent,
+	})
+}
+
 func (h *AgentHandler) DeleteAgent(c *fiber.Ctx) error {
 	agentID := c.Params("id")
 
@@ -280,10 +622,11 @@ func (h *AgentHandler) DeleteAgent(c *fiber.Ctx) error {
 	if h.kafkaProducer != nil {
 		agentData, _ := json.Marshal(agent)
 		key := fmt.Sprintf("delete_agent:%s", agent.ID)
-		if err := h.kafkaProducer.PublishMessage(context.Background(), key, agentData); err != nil {
-			fmt.Printf("Warning: Failed to publish agent deletion event: %v\n", err)
+		ctx := logger.WithAgentID(c.UserContext(), agent.ID)
+		if err := h.kafkaProducer.PublishMessage(ctx, key, agentData); err != nil {
+			slog.WarnContext(ctx, "Failed to publish agent deletion event", "error", err)
 		} else {
-			fmt.Printf("Published agent deletion event: %s\n", key)
+			slog.InfoContext(ctx, "Published agent deletion event", "key", key)
 		}
 	}
 
@@ -294,24 +637,50 @@ func (h *AgentHandler) DeleteAgent(c *fiber.Ctx) error {
 	})
 }
 
-func (h *AgentHandler) WebSocketHandler(c *websocket.Conn, agentID string) {
+func (h *AgentHandler) WebSocketHandler(c *websocket.Conn, agentID, sessionID string) {
+	logCtx := logger.WithAgentID(context.Background(), agentID)
 	defer func() {
 		c.Close()
-		fmt.Printf("WebSocket closed for agent: %s\n", agentID)
+		slog.InfoContext(logCtx, "WebSocket closed")
 	}()
 
-	fmt.Printf("WebSocket connected for agent: %s\n", agentID)
+	slog.InfoContext(logCtx, "WebSocket connected", "session_id", sessionID)
+
+	metrics.WebSocketConnections.WithLabelValues("assigned").Inc()
+	defer metrics.WebSocketConnections.WithLabelValues("assigned").Dec()
+
+	// The agent is routable while any of the session's sockets is open; the session ends
+	// only if they all stay closed past the grace period
+	if !h.service.SessionConnected(agentID, sessionID) {
+		slog.WarnContext(logCtx, "Rejected WebSocket for ended session", "session_id", sessionID)
+		c.WriteJSON(fiber.Map{"error": "Session ended, log in again"})
+		return
+	}
+	defer h.service.SessionDisconnected(agentID, sessionID)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	go func() {
+		ticker := time.NewTicker(sessionHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				h.service.SessionHeartbeat(sessionID)
+			}
+		}
+	}()
+
 	// Create Kafka consumer for this agent's assigned_calls
 	cfg := config.Load()
 	brokers := []string{cfg.KafkaBrokers}
 
 	consumer, err := h.service.GetKafkaConsumer(brokers, "assigned_calls", "ws-"+agentID)
 	if err != nil {
-		fmt.Printf("Error creating Kafka consumer: %v\n", err)
+		slog.ErrorContext(logCtx, "Failed to create Kafka consumer", "error", err)
 		c.WriteJSON(fiber.Map{"error": "Failed to connect to message stream"})
 		return
 	}
@@ -323,7 +692,7 @@ func (h *AgentHandler) WebSocketHandler(c *websocket.Conn, agentID string) {
 		"message": fmt.Sprintf("Connected as agent %s", agentID),
 	})
 	if err != nil {
-		fmt.Printf("Error sending connected message: %v\n", err)
+		slog.ErrorContext(logCtx, "Failed to send connected message", "error", err)
 		return
 	}
 
@@ -332,31 +701,39 @@ func (h *AgentHandler) WebSocketHandler(c *websocket.Conn, agentID string) {
 
 	// Start consuming messages in background
 	go func() {
-		fmt.Printf("Starting Kafka consumer for agent %s on topic assigned_calls with group ws-%s\n", agentID, agentID)
-		err := h.service.ConsumeAssignedCalls(ctx, consumer, func(call models.AssignedCall) error {
-			fmt.Printf("Received call from Kafka: %s for agent %s (current agent: %s)\n", call.CallID, call.AssignedAgentID, agentID)
-			// Only send if assigned to this agent
-			if call.AssignedAgentID == agentID {
-				fmt.Printf("Sending call %s to agent %s via WebSocket\n", call.CallID, agentID)
+		slog.InfoContext(logCtx, "Starting Kafka consumer", "topic", "assigned_calls", "group", "ws-"+agentID)
+		err := h.service.ConsumeAssignedCalls(ctx, consumer, func(callCtx context.Context, call models.AssignedCall) error {
+			// Only send new and retracted calls assigned to this agent
+			if call.AssignedAgentID == agentID && (call.Status == models.CallStatusAssigned || call.Status == models.CallStatusAbandoned) {
+				messageType := "new_call"
+				if call.Status == models.CallStatusAbandoned {
+					messageType = "call_retracted"
+				}
 				data := fiber.Map{
-					"type": "new_call",
+					"type": messageType,
 					"data": call,
 				}
-				if err := c.WriteJSON(data); err != nil {
-					fmt.Printf("Error sending message to WebSocket: %v\n", err)
+
+				// The call's trace ends once the agent's socket has it
+				_, span := tracer.Start(callCtx, "deliver "+messageType)
+				span.SetAttributes(attribute.String("call.id", call.CallID), attribute.String("agent.id", agentID))
+				err := c.WriteJSON(data)
+				tracing.End(span, err)
+				if err != nil {
+					slog.ErrorContext(callCtx, "Failed to send call over WebSocket", "type", messageType, "error", err)
 					// Don't return error - just log it and continue consuming
 					return nil
 				}
-				fmt.Printf("Successfully sent call %s to agent %s\n", call.CallID, agentID)
+				slog.InfoContext(callCtx, "Sent call over WebSocket", "type", messageType)
 			} else {
-				fmt.Printf("Skipping call %s (assigned to %s, not %s)\n", call.CallID, call.AssignedAgentID, agentID)
+				slog.DebugContext(callCtx, "Skipping call for another agent", "socket_agent_id", agentID)
 			}
 			return nil
 		})
 		if err != nil {
-			fmt.Printf("Kafka consumer stopped for agent %s: %v\n", agentID, err)
+			slog.InfoContext(logCtx, "Kafka consumer stopped", "error", err)
 		}
-		fmt.Printf("Kafka consumer goroutine exiting for agent %s\n", agentID)
+		slog.DebugContext(logCtx, "Kafka consumer goroutine exiting")
 	}()
 
 	// Keep connection alive - blocking read loop
@@ -364,18 +741,18 @@ func (h *AgentHandler) WebSocketHandler(c *websocket.Conn, agentID string) {
 		_, message, err := c.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
-				fmt.Printf("WebSocket error for agent %s: %v\n", agentID, err)
+				slog.WarnContext(logCtx, "WebSocket error", "error", err)
 			} else {
-				fmt.Printf("WebSocket closed normally for agent %s\n", agentID)
+				slog.InfoContext(logCtx, "WebSocket closed normally")
 			}
 			close(wsClosed)
 			break
 		}
 		// Handle ping/pong or other client messages
-		fmt.Printf("Received message from agent %s: %s\n", agentID, string(message))
+		slog.DebugContext(logCtx, "Received WebSocket message", "message", string(message))
 	}
 
 	// WebSocket closed, cancel Kafka consumer context
 	cancel()
-	fmt.Printf("WebSocket disconnected, stopping Kafka consumer for agent: %s\n", agentID)
+	slog.InfoContext(logCtx, "WebSocket disconnected, stopping Kafka consumer")
 }
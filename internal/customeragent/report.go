@@ -0,0 +1,226 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// GetAgentStateReport summarizes state history in [from, to) per agent. Intervals are clipped
+// to the period, and the current open interval counts up to now.
+func (s *agentService) GetAgentStateReport(from, to time.Time) ([]models.AgentStateReport, error) {
+	var spans []struct {
+		AgentID string
+		State   string
+		AuxCode string
+		Seconds float64
+	}
+	err := s.db.Model(&models.AgentStateInterval{}).
+		Select(`agent_id, state, aux_code,
+			SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(ended_at, NOW()), ?) - GREATEST(started_at, ?))) AS seconds`, to, from).
+		Where("started_at < ? AND COALESCE(ended_at, NOW()) > ?", to, from).
+		Group("agent_id, state, aux_code").
+		Scan(&spans).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var auxCounts []struct {
+		AgentID   string
+		Intervals int64
+		Capped    int64
+	}
+	err = s.db.Model(&models.AgentStateInterval{}).
+		Select("agent_id, COUNT(*) AS intervals, COUNT(capped_at) AS capped").
+		Where("state = ? AND started_at >= ? AND started_at < ?", models.AgentStateAux, from, to).
+		Group("agent_id").
+		Scan(&auxCounts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var loggedIn []struct {
+		AgentID string
+		Seconds float64
+	}
+	err = s.db.Model(&models.AgentSession{}).
+		Select(`agent_id,
+			SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(ended_at, NOW()), ?) - GREATEST(started_at, ?))) AS seconds`, to, from).
+		Where("started_at < ? AND COALESCE(ended_at, NOW()) > ?", to, from).
+		Group("agent_id").
+		Scan(&loggedIn).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var handled []struct {
+		AgentID string
+		Seconds float64
+	}
+	err = s.db.Raw(handleSecondsQuery, map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"queue":     "",
+		"unhandled": unhandledStatuses,
+	}).Scan(&handled).Error
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make(map[string]*models.AgentStateReport)
+	report := func(agentID string) *models.AgentStateReport {
+		if r, ok := reports[agentID]; ok {
+			return r
+		}
+		r := &models.AgentStateReport{AgentID: agentID, AuxByCode: map[string]int64{}}
+		reports[agentID] = r
+		return r
+	}
+
+	for _, span := range spans {
+		r := report(span.AgentID)
+		seconds := int64(span.Seconds)
+		switch span.State {
+		case models.AgentStateAvailable:
+			r.AvailableSeconds += seconds
+		case models.AgentStateWrapUp:
+			r.WrapUpSeconds += seconds
+		case models.AgentStateAux, models.AgentStateAuxOverrun:
+			r.AuxSeconds += seconds
+			r.AuxByCode[span.AuxCode] += seconds
+		}
+	}
+	for _, count := range auxCounts {
+		r := report(count.AgentID)
+		r.AuxIntervals = count.Intervals
+		r.AuxCapped = count.Capped
+	}
+	for _, h := range handled {
+		report(h.AgentID).HandleSeconds = int64(h.Seconds)
+	}
+	for _, session := range loggedIn {
+		report(session.AgentID).LoggedInSeconds = int64(session.Seconds)
+	}
+
+	result := make([]models.AgentStateReport, 0, len(reports))
+	for _, r := range reports {
+		r.Occupancy = occupancy(float64(r.HandleSeconds), float64(r.WrapUpSeconds), float64(r.AvailableSeconds+r.WrapUpSeconds))
+		r.Adherence = 1
+		if r.AuxIntervals > 0 {
+			r.Adherence = float64(r.AuxIntervals-r.AuxCapped) / float64(r.AuxIntervals)
+		}
+		result = append(result, *r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].AgentID < result[j].AgentID })
+	return result, nil
+}
+
+// unhandledStatuses are call outcomes that involved no handling by the agent
+var unhandledStatuses = []string{models.CallStatusAssigned, models.CallStatusAbandoned, models.CallStatusMissed}
+
+// staffedStates are the states in which an agent counts as staffed for occupancy
+var staffedStates = []string{models.AgentStateAvailable, models.AgentStateWrapUp}
+
+// handleSecondsQuery sums handle time, from assignment to completion, per agent for calls
+// that arrived in the period, optionally in one queue. It selects calls the same way as the
+// calls in agentStatsQuery and is the handle time used for occupancy everywhere.
+const handleSecondsQuery = `
+SELECT assigned_agent_id AS agent_id,
+	SUM(EXTRACT(EPOCH FROM timestamp - created_at)) AS seconds
+FROM assigned_calls
+WHERE deleted_at IS NULL
+	AND assigned_agent_id <> ''
+	AND received_at >= @from AND received_at < @to
+	AND (@queue = '' OR queue = @queue)
+	AND status NOT IN @unhandled
+GROUP BY assigned_agent_id`
+
+// occupancy is the share of staffed time (available plus wrap-up) spent handling calls or in
+// wrap-up, capped at 1. Both the state report and agent stats use it.
+func occupancy(handleSeconds, wrapUpSeconds, staffedSeconds float64) float64 {
+	if staffedSeconds <= 0 {
+		return 0
+	}
+	return math.Min(1, (handleSeconds+wrapUpSeconds)/staffedSeconds)
+}
+
+// agentStatsQuery aggregates calls and state history per agent in one pass. Calls are
+// counted by arrival time; state intervals are clipped to the period like the state report,
+// and occupancy inputs come from handleSecondsQuery.
+const agentStatsQuery = `
+WITH calls AS (
+	SELECT ac.assigned_agent_id AS agent_id,
+		COUNT(*) AS total_calls,
+		COUNT(*) FILTER (WHERE ac.status = @completed) AS completed_calls,
+		COUNT(*) FILTER (WHERE ac.status = @abandoned) AS abandoned_calls,
+		COUNT(*) FILTER (WHERE ac.status = @missed) AS missed_calls,
+		AVG(EXTRACT(EPOCH FROM ac.timestamp - ac.created_at)) FILTER (WHERE ac.status NOT IN @unhandled) AS avg_handle_seconds,
+		AVG(EXTRACT(EPOCH FROM ac.created_at - ac.received_at)) AS avg_wait_seconds
+	FROM assigned_calls AS ac
+	WHERE ac.deleted_at IS NULL
+		AND ac.assigned_agent_id <> ''
+		AND ac.received_at >= @from AND ac.received_at < @to
+		AND (@queue = '' OR ac.queue = @queue)
+	GROUP BY ac.assigned_agent_id
+), handled AS (` + handleSecondsQuery + `
+), states AS (
+	SELECT agent_id,
+		SUM(seconds) FILTER (WHERE state IN @staffed) AS staffed_seconds,
+		SUM(seconds) FILTER (WHERE state = @wrap_up) AS wrap_up_seconds
+	FROM (
+		SELECT agent_id, state,
+			EXTRACT(EPOCH FROM LEAST(COALESCE(ended_at, NOW()), @to) - GREATEST(started_at, @from)) AS seconds
+		FROM agent_state_history
+		WHERE started_at < @to AND COALESCE(ended_at, NOW()) > @from
+	) AS clipped
+	GROUP BY agent_id
+)
+SELECT a.id AS agent_id,
+	a.name AS agent_name,
+	a.team,
+	CASE WHEN a.is_active THEN 'active' ELSE 'inactive' END AS status,
+	COALESCE(c.total_calls, 0) AS total_calls,
+	COALESCE(c.completed_calls, 0) AS completed_calls,
+	COALESCE(c.abandoned_calls, 0) AS abandoned_calls,
+	COALESCE(c.missed_calls, 0) AS missed_calls,
+	COALESCE(c.avg_handle_seconds, 0) AS avg_handle_seconds,
+	COALESCE(c.avg_wait_seconds, 0) AS avg_wait_seconds,
+	COALESCE(h.seconds, 0) AS handle_seconds,
+	COALESCE(s.wrap_up_seconds, 0) AS wrap_up_seconds,
+	COALESCE(s.staffed_seconds, 0) AS staffed_seconds
+FROM agents AS a
+LEFT JOIN calls AS c ON c.agent_id = a.id
+LEFT JOIN handled AS h ON h.agent_id = a.id
+LEFT JOIN states AS s ON s.agent_id = a.id
+WHERE a.deleted_at IS NULL
+	AND (@team = '' OR a.team = @team)
+	AND (@queue = '' OR c.agent_id IS NOT NULL
+		OR EXISTS (SELECT 1 FROM queue_memberships AS m WHERE m.agent_id = a.id AND m.queue = @queue))
+ORDER BY a.id`
+
+// GetAgentStats reports call outcomes, handle and wait times and occupancy per agent for calls
+// that arrived in [from, to). A queue limits calls to that queue and agents to those who are
+// members or took its calls; a team limits agents to that team.
+func (s *agentService) GetAgentStats(from, to time.Time, queue, team string) ([]models.AgentStats, error) {
+	stats := []models.AgentStats{}
+	err := s.db.Raw(agentStatsQuery, map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"queue":     queue,
+		"team":      team,
+		"completed": models.CallStatusCompleted,
+		"abandoned": models.CallStatusAbandoned,
+		"missed":    models.CallStatusMissed,
+		"unhandled": unhandledStatuses,
+		"staffed":   staffedStates,
+		"wrap_up":   models.AgentStateWrapUp,
+	}).Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].Occupancy = occupancy(stats[i].HandleSeconds, stats[i].WrapUpSeconds, stats[i].StaffedSeconds)
+	}
+	return stats, nil
+}
@@ -0,0 +1,81 @@
+package customeragent
+
+import (
+	"call-center-api/pkg/testutil"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestOccupancy(t *testing.T) {
+	tests := []struct {
+		name                    string
+		handle, wrapUp, staffed float64
+		want                    float64
+	}{
+		{name: "no staffed time", handle: 60, wrapUp: 10, staffed: 0, want: 0},
+		{name: "partly busy", handle: 1800, wrapUp: 600, staffed: 3600, want: 2.0 / 3},
+		{name: "capped at one", handle: 3600, wrapUp: 600, staffed: 3600, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := occupancy(tt.handle, tt.wrapUp, tt.staffed); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("occupancy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Handle time must come from the same calls the counts do, or occupancy for a queue mixes in
+// other queues' calls and calls that arrived before the period
+func TestHandleSecondsQuerySelectsStatsCalls(t *testing.T) {
+	for _, predicate := range []string{
+		"received_at >= @from AND received_at < @to",
+		"(@queue = '' OR queue = @queue)",
+	} {
+		if !strings.Contains(handleSecondsQuery, predicate) {
+			t.Errorf("handleSecondsQuery lacks %q", predicate)
+		}
+	}
+	if strings.Contains(handleSecondsQuery, "created_at >=") {
+		t.Error("handleSecondsQuery selects calls by assignment time")
+	}
+}
+
+func TestGetAgentStats(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	s := NewAgentService(db).(*agentService)
+
+	from := time.Date(2030, 1, 14, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	columns := []string{"agent_id", "agent_name", "team", "status", "total_calls", "completed_calls",
+		"abandoned_calls", "missed_calls", "avg_handle_seconds", "avg_wait_seconds",
+		"handle_seconds", "wrap_up_seconds", "staffed_seconds"}
+	mock.ExpectQuery(regexp.QuoteMeta("WITH calls AS (")).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("a1", "Ann", "blue", "active", 4, 3, 0, 1, 120, 15, 360, 180, 1080).
+			AddRow("a2", "Bo", "blue", "inactive", 0, 0, 0, 0, 0, 0, 0, 0, 0))
+
+	stats, err := s.GetAgentStats(from, to, "sales", "blue")
+	if err != nil {
+		t.Fatalf("GetAgentStats() error = %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("got %d rows, want 2", len(stats))
+	}
+	if stats[0].AgentID != "a1" || stats[0].CompletedCalls != 3 || stats[0].MissedCalls != 1 {
+		t.Errorf("stats[0] = %+v", stats[0])
+	}
+	if math.Abs(stats[0].Occupancy-0.5) > 1e-9 {
+		t.Errorf("a1 occupancy = %v, want 0.5", stats[0].Occupancy)
+	}
+	if stats[1].Occupancy != 0 {
+		t.Errorf("a2 occupancy = %v, want 0 without staffed time", stats[1].Occupancy)
+	}
+}
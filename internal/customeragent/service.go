@@ -7,6 +7,9 @@ import (
 	"call-center-api/pkg/middleware"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,25 +19,45 @@ import (
 )
 
 type AgentService interface {
-	RegisterAgent(name, password string, isAdmin bool) (*models.Agent, error)
+	RegisterAgent(name, password, team string, isAdmin bool) (*models.Agent, error)
 	Login(agentID, password string) (string, error)
 	GenerateAdminToken(username string) (string, error)
 	GetAssignedCalls(agentID string) ([]models.AssignedCall, error)
-	CompleteCall(callID, agentID, notes, status string) (*models.AssignedCall, error)
-	GetAgentStats() ([]map[string]interface{}, error)
+	CompleteCall(callID, agentID, notes, status string, callbackAt *time.Time) (*models.AssignedCall, error)
+	GetAgentStats(from, to time.Time, queue, team string) ([]models.AgentStats, error)
+	SetAgentCapacity(agentID string, maxConcurrentCalls int) (*models.Agent, error)
+	SetAgentTeam(agentID, team string) (*models.Agent, error)
+	GetAgentState(agentID string) (*models.Agent, error)
+	SetAgentState(agentID string, req models.SetAgentStateRequest) (*models.Agent, error)
+	EndWrapUp(agentID string) (*models.Agent, error)
+	StartStateExpiry(ctx context.Context)
+	BackfillStateHistory() error
+	SetKafkaProducer(producer *database.KafkaProducer)
+	Logout(agentID, sessionID string) error
+	SessionActive(sessionID string) bool
+	SessionConnected(agentID, sessionID string) bool
+	SessionHeartbeat(sessionID string)
+	SessionDisconnected(agentID, sessionID string)
+	StartSessionSweeper(ctx context.Context, grace time.Duration)
+	ListSessions(agentID string, from, to time.Time) ([]models.AgentSession, error)
+	CreateAuxCode(code models.AuxCode) (*models.AuxCode, error)
+	ListAuxCodes() ([]models.AuxCode, error)
+	DeleteAuxCode(id uint) error
+	GetAgentStateReport(from, to time.Time) ([]models.AgentStateReport, error)
 	GetKafkaConsumer(brokers []string, topic, groupID string) (*database.KafkaConsumer, error)
-	ConsumeAssignedCalls(ctx context.Context, consumer *database.KafkaConsumer, handler func(models.AssignedCall) error) error
+	ConsumeAssignedCalls(ctx context.Context, consumer *database.KafkaConsumer, handler func(context.Context, models.AssignedCall) error) error
 }
 
 type agentService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	kafkaProducer *database.KafkaProducer
 }
 
 func NewAgentService(db *gorm.DB) AgentService {
 	return &agentService{db: db}
 }
 
-func (s *agentService) RegisterAgent(name, password string, isAdmin bool) (*models.Agent, error) {
+func (s *agentService) RegisterAgent(name, password, team string, isAdmin bool) (*models.Agent, error) {
 	// Generate 6-digit agent ID
 	agentID := generateAgentID()
 
@@ -50,11 +73,20 @@ func (s *agentService) RegisterAgent(name, password string, isAdmin bool) (*mode
 		Password:  string(hashedPassword),
 		IsAdmin:   isAdmin,
 		IsActive:  true,
+		Team:      team,
+		State:     models.AgentStateAvailable,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := s.db.Create(agent).Error; err != nil {
+	// The agent is created with its first state interval open
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(agent).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.AgentStateInterval{AgentID: agent.ID, State: agent.State, StartedAt: agent.CreatedAt}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -71,13 +103,20 @@ func (s *agentService) Login(agentID, password string) (string, error) {
 		return "", errors.New("invalid credentials")
 	}
 
+	// Every login opens a session, which makes the agent routable
+	session, err := s.startSession(agent.ID)
+	if err != nil {
+		return "", err
+	}
+
 	// Generate JWT token
 	cfg := config.Load()
 	claims := middleware.Claims{
-		AgentID: agent.ID,
+		AgentID:   agent.ID,
+		SessionID: session.ID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(session.StartedAt),
 		},
 	}
 
@@ -118,38 +157,31 @@ func (s *agentService) GetAssignedCalls(agentID string) ([]models.AssignedCall,
 	return calls, nil
 }
 
-func (s *agentService) GetAgentStats() ([]map[string]interface{}, error) {
-	var agents []models.Agent
-	if err := s.db.Find(&agents).Error; err != nil {
-		return nil, err
-	}
-
-	var stats []map[string]interface{}
-	for _, agent := range agents {
-		var totalCalls int64
-		var completedCalls int64
+// ErrCallNotActive is returned when completing a call that was already completed or abandoned
+var ErrCallNotActive = errors.New("call is no longer active")
 
-		s.db.Model(&models.AssignedCall{}).Where("assigned_agent_id = ?", agent.ID).Count(&totalCalls)
-		s.db.Model(&models.AssignedCall{}).Where("assigned_agent_id = ? AND status = ?", agent.ID, "completed").Count(&completedCalls)
+// ErrInvalidCompletionStatus is returned for a status an agent cannot close a call with
+var ErrInvalidCompletionStatus = fmt.Errorf("status must be %s, %s or %s",
+	models.CallStatusCompleted, models.CallStatusMissed, models.DispositionCallbackNeeded)
 
-		status := "inactive"
-		if agent.IsActive {
-			status = "active"
-		}
+// completionStatuses are the dispositions an agent can close a call with
+var completionStatuses = map[string]bool{
+	models.CallStatusCompleted:       true,
+	models.CallStatusMissed:          true,
+	models.DispositionCallbackNeeded: true,
+}
 
-		stats = append(stats, map[string]interface{}{
-			"agent_id":        agent.ID,
-			"agent_name":      agent.Name,
-			"status":          status,
-			"total_calls":     totalCalls,
-			"completed_calls": completedCalls,
-		})
+// CompleteCall closes an assigned call with status, which defaults to completed. The update
+// only applies while the call is still assigned, so concurrent completions cannot both succeed
+// and release the agent's capacity slot twice.
+func (s *agentService) CompleteCall(callID, agentID, notes, status string, callbackAt *time.Time) (*models.AssignedCall, error) {
+	if status == "" {
+		status = models.CallStatusCompleted
+	}
+	if !completionStatuses[status] {
+		return nil, ErrInvalidCompletionStatus
 	}
 
-	return stats, nil
-}
-
-func (s *agentService) CompleteCall(callID, agentID, notes, status string) (*models.AssignedCall, error) {
 	var call models.AssignedCall
 	if err := s.db.Where("call_id = ?", callID).First(&call).Error; err != nil {
 		return nil, errors.New("call not found")
@@ -160,25 +192,93 @@ func (s *agentService) CompleteCall(callID, agentID, notes, status string) (*mod
 		return nil, errors.New("unauthorized: call not assigned to you")
 	}
 
-	// Update call
 	call.Status = status
-	if notes != "" {
-		// You could add a Notes field to the model
-	}
 	call.Timestamp = time.Now()
 
-	if err := s.db.Save(&call).Error; err != nil {
+	// The completion and any requested callback are saved together, so a failed callback
+	// never leaves the call completed without it
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AssignedCall{}).
+			Where("call_id = ? AND assigned_agent_id = ? AND status = ?", callID, agentID, models.CallStatusAssigned).
+			Updates(map[string]interface{}{"status": status, "timestamp": call.Timestamp})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCallNotActive
+		}
+
+		// Schedule a callback to the same agent when requested
+		if status == models.DispositionCallbackNeeded {
+			windowStart := time.Now()
+			if callbackAt != nil {
+				windowStart = *callbackAt
+			}
+			callback := models.Callback{
+				CustomerNumber:   call.CustomerNumber,
+				RawNumber:        call.RawNumber,
+				Queue:            call.Queue,
+				Reason:           notes,
+				WindowStart:      windowStart,
+				PreferredAgentID: agentID,
+				OriginCallID:     call.CallID,
+				Status:           models.CallbackScheduled,
+				NextAttemptAt:    &windowStart,
+			}
+			if err := tx.Create(&callback).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	// Wrap-up is best-effort: the call is already completed, and failing here would keep the
+	// completion event from being published and leak the agent's capacity slot
+	if err := s.startWrapUp(agentID, call.Queue, status); err != nil {
+		slog.Error("Failed to start wrap-up", "agent_id", agentID, "call_id", call.CallID, "error", err)
+	}
+
 	return &call, nil
 }
 
+// SetAgentCapacity sets how many calls an agent may hold at once; 0 uses the queue default
+func (s *agentService) SetAgentCapacity(agentID string, maxConcurrentCalls int) (*models.Agent, error) {
+	if maxConcurrentCalls < 0 {
+		return nil, errors.New("max_concurrent_calls must not be negative")
+	}
+
+	var agent models.Agent
+	if err := s.db.Where("id = ?", agentID).First(&agent).Error; err != nil {
+		return nil, errors.New("agent not found")
+	}
+
+	if err := s.db.Model(&agent).Update("max_concurrent_calls", maxConcurrentCalls).Error; err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// SetAgentTeam moves an agent to a reporting team; an empty team removes them from their current one
+func (s *agentService) SetAgentTeam(agentID, team string) (*models.Agent, error) {
+	var agent models.Agent
+	if err := s.db.Where("id = ?", agentID).First(&agent).Error; err != nil {
+		return nil, errors.New("agent not found")
+	}
+
+	if err := s.db.Model(&agent).Update("team", strings.TrimSpace(team)).Error; err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
 func (s *agentService) GetKafkaConsumer(brokers []string, topic, groupID string) (*database.KafkaConsumer, error) {
 	return database.NewKafkaConsumer(brokers, topic, groupID)
 }
 
-func (s *agentService) ConsumeAssignedCalls(ctx context.Context, consumer *database.KafkaConsumer, handler func(models.AssignedCall) error) error {
+func (s *agentService) ConsumeAssignedCalls(ctx context.Context, consumer *database.KafkaConsumer, handler func(context.Context, models.AssignedCall) error) error {
 	return consumer.ConsumeAssignedCalls(ctx, handler)
 }
 
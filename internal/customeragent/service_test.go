@@ -0,0 +1,88 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCompleteCall(t *testing.T) {
+	callRow := func(agentID, status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "call_id", "customer_number", "queue", "assigned_agent_id", "status"}).
+			AddRow(1, "c1", "+16502530000", "sales", agentID, status)
+	}
+
+	tests := []struct {
+		name       string
+		status     string
+		owner      string
+		stored     string // status stored when the call is read
+		updated    int64  // rows the conditional update changes; -1 when it must not run
+		callback   bool   // a callback row is expected
+		wantStatus string
+		wantErr    error
+		anyErr     bool
+	}{
+		{name: "default status", owner: "a1", stored: models.CallStatusAssigned, updated: 1, wantStatus: models.CallStatusCompleted},
+		{name: "missed", status: models.CallStatusMissed, owner: "a1", stored: models.CallStatusAssigned, updated: 1, wantStatus: models.CallStatusMissed},
+		{
+			name: "callback needed", status: models.DispositionCallbackNeeded, owner: "a1",
+			stored: models.CallStatusAssigned, updated: 1, callback: true, wantStatus: models.DispositionCallbackNeeded,
+		},
+		{name: "assigned is not a completion", status: models.CallStatusAssigned, updated: -1, wantErr: ErrInvalidCompletionStatus},
+		{name: "arbitrary status", status: "done-ish", updated: -1, wantErr: ErrInvalidCompletionStatus},
+		{name: "another agent's call", owner: "a2", stored: models.CallStatusAssigned, updated: -1, anyErr: true},
+		// A concurrent completion won between the read and the update
+		{name: "completed concurrently", owner: "a1", stored: models.CallStatusAssigned, updated: 0, wantErr: ErrCallNotActive},
+		{name: "already completed", owner: "a1", stored: models.CallStatusCompleted, updated: 0, wantErr: ErrCallNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAgentService(db).(*agentService)
+
+			if tt.owner != "" {
+				mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE call_id = \$1`).
+					WillReturnRows(callRow(tt.owner, tt.stored))
+			}
+			if tt.updated >= 0 {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE "assigned_calls" SET .* WHERE \(call_id = \$\d+ AND assigned_agent_id = \$\d+ AND status = \$\d+\)`).
+					WillReturnResult(sqlmock.NewResult(0, tt.updated))
+				if tt.updated == 0 {
+					mock.ExpectRollback()
+				} else {
+					if tt.callback {
+						mock.ExpectQuery(`INSERT INTO "callbacks"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+					}
+					mock.ExpectCommit()
+					// Wrap-up looks up the queue; without one there is no wrap-up
+					mock.ExpectQuery(`SELECT \* FROM "queues"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				}
+			}
+
+			call, err := s.CompleteCall("c1", "a1", "", tt.status, nil)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CompleteCall() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.anyErr:
+				if err == nil {
+					t.Fatal("CompleteCall() succeeded, want an error")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("CompleteCall() error = %v", err)
+				}
+				if call.Status != tt.wantStatus {
+					t.Errorf("status = %q, want %q", call.Status, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"call-center-api/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// sessionTTL matches the lifetime of the JWT issued at login
+	sessionTTL = 24 * time.Hour
+	// sessionSweepInterval is how often expired and abandoned sessions are ended
+	sessionSweepInterval = 5 * time.Second
+	// sessionHeartbeatInterval is how often an open WebSocket refreshes its session's heartbeat
+	sessionHeartbeatInterval = 10 * time.Second
+)
+
+// SetKafkaProducer enables session change events on agent_changes, which the distributor
+// uses to add agents to and remove them from the rotation
+func (s *agentService) SetKafkaProducer(producer *database.KafkaProducer) {
+	s.kafkaProducer = producer
+}
+
+func (s *agentService) startSession(agentID string) (*models.AgentSession, error) {
+	now := time.Now()
+	// A session starts without a WebSocket, so one that never connects is ended after the grace period
+	session := &models.AgentSession{
+		ID:             uuid.New().String(),
+		AgentID:        agentID,
+		StartedAt:      now,
+		ExpiresAt:      now.Add(sessionTTL),
+		DisconnectedAt: &now,
+	}
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, err
+	}
+
+	s.publishSessionChange(agentID)
+	return session, nil
+}
+
+// endSession closes a session once; it reports whether this call ended it
+func (s *agentService) endSession(session models.AgentSession, reason string) bool {
+	result := s.db.Model(&models.AgentSession{}).
+		Where("id = ? AND ended_at IS NULL", session.ID).
+		Updates(map[string]interface{}{"ended_at": time.Now(), "end_reason": reason})
+	if result.Error != nil {
+		slog.Error("Failed to end session", "session_id", session.ID, "agent_id", session.AgentID, "error", result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	s.publishSessionChange(session.AgentID)
+	return true
+}
+
+// publishSessionChange tells the distributor to re-check whether the agent has a live session
+func (s *agentService) publishSessionChange(agentID string) {
+	if s.kafkaProducer == nil {
+		return
+	}
+
+	data, _ := json.Marshal(models.Agent{ID: agentID})
+	key := fmt.Sprintf("session_change:%s", agentID)
+	ctx := logger.WithAgentID(context.Background(), agentID)
+	if err := s.kafkaProducer.PublishMessage(ctx, key, data); err != nil {
+		slog.WarnContext(ctx, "Failed to publish session change", "error", err)
+	}
+}
+
+// Logout ends the session the agent's token belongs to
+func (s *agentService) Logout(agentID, sessionID string) error {
+	var session models.AgentSession
+	if err := s.db.Where("id = ? AND agent_id = ?", sessionID, agentID).First(&session).Error; err != nil {
+		return errors.New("session not found")
+	}
+	if !s.endSession(session, models.SessionEndLogout) {
+		return errors.New("session already ended")
+	}
+	return nil
+}
+
+// SessionActive reports whether the session exists and has not ended or expired
+func (s *agentService) SessionActive(sessionID string) bool {
+	var count int64
+	err := s.db.Model(&models.AgentSession{}).
+		Where("id = ? AND ended_at IS NULL AND expires_at > ?", sessionID, time.Now()).
+		Count(&count).Error
+	return err == nil && count > 0
+}
+
+// SessionConnected counts a newly opened WebSocket on the session, canceling any disconnect
+// grace period. It reports false if the session has already ended, in which case the socket
+// must not be used.
+func (s *agentService) SessionConnected(agentID, sessionID string) bool {
+	if sessionID == "" {
+		return false
+	}
+	now := time.Now()
+	result := s.db.Model(&models.AgentSession{}).
+		Where("id = ? AND agent_id = ? AND ended_at IS NULL AND expires_at > ?", sessionID, agentID, now).
+		Updates(map[string]interface{}{
+			"connections":     gorm.Expr("connections + 1"),
+			"connected_at":    now,
+			"heartbeat_at":    now,
+			"disconnected_at": nil,
+		})
+	if result.Error != nil {
+		slog.Error("Failed to record session connection", "session_id", sessionID, "agent_id", agentID, "error", result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	s.publishSessionChange(agentID)
+	return true
+}
+
+// SessionHeartbeat shows the session still has a WebSocket open on a running instance
+func (s *agentService) SessionHeartbeat(sessionID string) {
+	s.db.Model(&models.AgentSession{}).
+		Where("id = ? AND ended_at IS NULL", sessionID).
+		Update("heartbeat_at", time.Now())
+}
+
+// SessionDisconnected uncounts a closed WebSocket. Closing the last one makes the agent
+// unroutable and starts the grace period after which the session is ended.
+func (s *agentService) SessionDisconnected(agentID, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	err := s.db.Model(&models.AgentSession{}).
+		Where("id = ? AND ended_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"connections":     gorm.Expr("GREATEST(connections - 1, 0)"),
+			"disconnected_at": gorm.Expr("CASE WHEN connections <= 1 THEN ? ELSE disconnected_at END", time.Now()),
+		}).Error
+	if err != nil {
+		slog.Error("Failed to record session disconnection", "session_id", sessionID, "agent_id", agentID, "error", err)
+		return
+	}
+
+	s.publishSessionChange(agentID)
+}
+
+// StartSessionSweeper ends sessions past their expiry and sessions whose WebSockets have been
+// gone longer than grace, until ctx is canceled. Sockets whose instance died without closing
+// them are recognized by a stale heartbeat.
+func (s *agentService) StartSessionSweeper(ctx context.Context, grace time.Duration) {
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+
+			var expired []models.AgentSession
+			if err := s.db.Where("ended_at IS NULL AND expires_at <= ?", now).Find(&expired).Error; err != nil {
+				slog.ErrorContext(ctx, "Failed to load expired sessions", "error", err)
+				continue
+			}
+			for _, session := range expired {
+				s.endSession(session, models.SessionEndExpired)
+			}
+
+			var stale []models.AgentSession
+			if err := s.db.Where("ended_at IS NULL AND connections > 0 AND heartbeat_at <= ?", now.Add(-grace)).Find(&stale).Error; err != nil {
+				slog.ErrorContext(ctx, "Failed to load stale sessions", "error", err)
+				continue
+			}
+			for _, session := range stale {
+				s.db.Model(&models.AgentSession{}).
+					Where("id = ? AND heartbeat_at = ?", session.ID, session.HeartbeatAt).
+					Updates(map[string]interface{}{"connections": 0, "disconnected_at": session.HeartbeatAt})
+				s.publishSessionChange(session.AgentID)
+			}
+
+			var dropped []models.AgentSession
+			if err := s.db.Where("ended_at IS NULL AND connections = 0 AND disconnected_at <= ?", now.Add(-grace)).Find(&dropped).Error; err != nil {
+				slog.ErrorContext(ctx, "Failed to load disconnected sessions", "error", err)
+				continue
+			}
+			for _, session := range dropped {
+				s.endSession(session, models.SessionEndDisconnect)
+			}
+		}
+	}
+}
+
+// ListSessions returns sessions overlapping [from, to), optionally for one agent
+func (s *agentService) ListSessions(agentID string, from, to time.Time) ([]models.AgentSession, error) {
+	query := s.db.Where("started_at < ? AND (ended_at IS NULL OR ended_at > ?)", to, from).Order("started_at DESC")
+	if agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+
+	var sessions []models.AgentSession
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,76 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name    string
+		found   bool
+		ended   int64 // rows the end update changes
+		wantErr bool
+	}{
+		{name: "open session", found: true, ended: 1},
+		{name: "already ended", found: true, ended: 0, wantErr: true},
+		{name: "another agent's session", found: false, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAgentService(db).(*agentService)
+
+			rows := sqlmock.NewRows([]string{"id", "agent_id"})
+			if tt.found {
+				rows.AddRow("s1", "a1")
+			}
+			mock.ExpectQuery(`SELECT \* FROM "agent_sessions" WHERE id = \$1 AND agent_id = \$2`).
+				WithArgs("s1", "a1").WillReturnRows(rows)
+			if tt.found {
+				mock.ExpectExec(`UPDATE "agent_sessions" SET "end_reason"=\$1,"ended_at"=\$2 WHERE id = \$3 AND ended_at IS NULL`).
+					WithArgs(models.SessionEndLogout, sqlmock.AnyArg(), "s1").
+					WillReturnResult(sqlmock.NewResult(0, tt.ended))
+			}
+
+			if err := s.Logout("a1", "s1"); (err != nil) != tt.wantErr {
+				t.Errorf("Logout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionConnected(t *testing.T) {
+	tests := []struct {
+		name      string
+		sessionID string
+		updated   int64 // rows the connection update changes; -1 when it must not run
+		want      bool
+	}{
+		{name: "open session", sessionID: "s1", updated: 1, want: true},
+		// Ended, expired or belonging to another agent
+		{name: "session not open", sessionID: "s1", updated: 0, want: false},
+		{name: "token without a session", sessionID: "", updated: -1, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAgentService(db).(*agentService)
+
+			if tt.updated >= 0 {
+				mock.ExpectExec(`UPDATE "agent_sessions" SET "connected_at"=\$1,"connections"=connections \+ 1,"disconnected_at"=\$2,"heartbeat_at"=\$3 WHERE id = \$4 AND agent_id = \$5 AND ended_at IS NULL AND expires_at > \$6`).
+					WithArgs(sqlmock.AnyArg(), nil, sqlmock.AnyArg(), tt.sessionID, "a1", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, tt.updated))
+			}
+
+			if got := s.SessionConnected("a1", tt.sessionID); got != tt.want {
+				t.Errorf("SessionConnected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
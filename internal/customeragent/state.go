@@ -0,0 +1,217 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// statePollInterval is how often expired wrap-up periods and capped aux codes are ended
+const statePollInterval = time.Second
+
+// transition moves an agent to a new state and records the change in agent_state_history.
+// When from is not empty the change only applies if the agent is still in that state, so
+// concurrent transitions cannot both win; it reports whether the change applied.
+func (s *agentService) transition(agentID, from, state, auxCode string, until *time.Time, capped bool) (bool, error) {
+	applied := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.Agent{}).Where("id = ?", agentID)
+		if from != "" {
+			query = query.Where("state = ?", from)
+		}
+		result := query.Updates(map[string]interface{}{"state": state, "aux_code": auxCode, "state_until": until})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		applied = true
+
+		now := time.Now()
+		closing := map[string]interface{}{"ended_at": now}
+		if capped {
+			closing["capped_at"] = now
+		}
+		if err := tx.Model(&models.AgentStateInterval{}).
+			Where("agent_id = ? AND ended_at IS NULL", agentID).
+			Updates(closing).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AgentStateInterval{
+			AgentID:   agentID,
+			State:     state,
+			AuxCode:   auxCode,
+			StartedAt: now,
+		}).Error
+	})
+
+	if err == nil && applied {
+		s.publishStateChange(models.Agent{ID: agentID, State: state, AuxCode: auxCode})
+	}
+	return applied, err
+}
+
+// publishStateChange announces a committed state change so live views such as the wallboard can follow it
+func (s *agentService) publishStateChange(agent models.Agent) {
+	if s.kafkaProducer == nil {
+		return
+	}
+
+	data, _ := json.Marshal(agent)
+	key := fmt.Sprintf("state_change:%s", agent.ID)
+	ctx := logger.WithAgentID(context.Background(), agent.ID)
+	if err := s.kafkaProducer.PublishMessage(ctx, key, data); err != nil {
+		slog.WarnContext(ctx, "Failed to publish state change", "state", agent.State, "error", err)
+	}
+}
+
+// wrapUpDuration returns the wrap-up period for a disposition in a queue; the per-disposition
+// override wins over the queue default
+func (s *agentService) wrapUpDuration(queueName, disposition string) time.Duration {
+	var queue models.Queue
+	if err := s.db.Where("name = ?", queueName).First(&queue).Error; err != nil {
+		return 0
+	}
+	if seconds, ok := queue.DispositionWrapUp[disposition]; ok {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(queue.WrapUpSeconds) * time.Second
+}
+
+// startWrapUp puts an available agent in wrap_up after a call, keeping them out of routing
+// until it ends. Agents who already left available (e.g. on break) stay where they are.
+func (s *agentService) startWrapUp(agentID, queue, disposition string) error {
+	duration := s.wrapUpDuration(queue, disposition)
+	if duration <= 0 {
+		return nil
+	}
+
+	until := time.Now().Add(duration)
+	_, err := s.transition(agentID, models.AgentStateAvailable, models.AgentStateWrapUp, "", &until, false)
+	return err
+}
+
+func (s *agentService) GetAgentState(agentID string) (*models.Agent, error) {
+	var agent models.Agent
+	if err := s.db.Where("id = ?", agentID).First(&agent).Error; err != nil {
+		return nil, errors.New("agent not found")
+	}
+	return &agent, nil
+}
+
+// EndWrapUp lets an agent finish after-call work early and take calls again
+func (s *agentService) EndWrapUp(agentID string) (*models.Agent, error) {
+	applied, err := s.transition(agentID, models.AgentStateWrapUp, models.AgentStateAvailable, "", nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, errors.New("agent is not in wrap-up")
+	}
+	return s.GetAgentState(agentID)
+}
+
+// SetAgentState moves an agent between available and an aux code. Leaving available always
+// requires a reason code, and capped codes end automatically after their maximum duration.
+func (s *agentService) SetAgentState(agentID string, req models.SetAgentStateRequest) (*models.Agent, error) {
+	current, err := s.GetAgentState(agentID)
+	if err != nil {
+		return nil, err
+	}
+	if current.State == req.State && current.AuxCode == req.AuxCode {
+		return current, nil
+	}
+
+	switch req.State {
+	case models.AgentStateAvailable:
+		if _, err := s.transition(agentID, "", models.AgentStateAvailable, "", nil, false); err != nil {
+			return nil, err
+		}
+
+	case models.AgentStateAux:
+		if req.AuxCode == "" {
+			return nil, errors.New("aux_code is required when leaving available")
+		}
+		var code models.AuxCode
+		if err := s.db.Where("code = ?", req.AuxCode).First(&code).Error; err != nil {
+			return nil, fmt.Errorf("unknown aux code %q", req.AuxCode)
+		}
+
+		var until *time.Time
+		if code.MaxDurationSeconds > 0 {
+			end := time.Now().Add(time.Duration(code.MaxDurationSeconds) * time.Second)
+			until = &end
+		}
+		if _, err := s.transition(agentID, "", models.AgentStateAux, code.Code, until, false); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("state must be %s or %s", models.AgentStateAvailable, models.AgentStateAux)
+	}
+
+	return s.GetAgentState(agentID)
+}
+
+// BackfillStateHistory opens a state interval, starting now, for every agent without an open
+// one, so agents created before state history was recorded show up in reports
+func (s *agentService) BackfillStateHistory() error {
+	result := s.db.Exec(`INSERT INTO agent_state_history (agent_id, state, aux_code, started_at)
+		SELECT a.id, a.state, a.aux_code, NOW()
+		FROM agents AS a
+		WHERE a.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM agent_state_history AS h
+				WHERE h.agent_id = a.id AND h.ended_at IS NULL)`)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("Backfilled agent state history", "agents", result.RowsAffected)
+	}
+	return nil
+}
+
+// StartStateExpiry ends wrap-up periods and capped aux codes that have run out, until ctx is
+// canceled. Wrap-up returns the agent to available; a capped aux code moves them to
+// aux_overrun, which keeps them out of routing since they may not be back at their desk.
+func (s *agentService) StartStateExpiry(ctx context.Context) {
+	ticker := time.NewTicker(statePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var agents []models.Agent
+			if err := s.db.Select("id", "state", "aux_code").
+				Where("state IN ? AND state_until <= ?", []string{models.AgentStateWrapUp, models.AgentStateAux}, time.Now()).
+				Find(&agents).Error; err != nil {
+				slog.ErrorContext(ctx, "Failed to load expired agent states", "error", err)
+				continue
+			}
+
+			for _, agent := range agents {
+				var err error
+				if agent.State == models.AgentStateAux {
+					_, err = s.transition(agent.ID, agent.State, models.AgentStateAuxOverrun, agent.AuxCode, nil, true)
+				} else {
+					_, err = s.transition(agent.ID, agent.State, models.AgentStateAvailable, "", nil, false)
+				}
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to end expired agent state", "agent_id", agent.ID, "state", agent.State, "error", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package customeragent
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// endsIn matches a state_until argument set about d from now
+type endsIn time.Duration
+
+func (d endsIn) Match(v driver.Value) bool {
+	until, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	diff := time.Until(until) - time.Duration(d)
+	return diff > -5*time.Second && diff <= 0
+}
+
+// expectTransition expects an agent's state update, conditional on the from state when it is
+// set, and, when it applies, the state history rows it closes and opens
+func expectTransition(mock sqlmock.Sqlmock, agentID, from, to, auxCode string, until driver.Value, applied bool) {
+	mock.ExpectBegin()
+	rows := int64(0)
+	if applied {
+		rows = 1
+	}
+	update := `UPDATE "agents" SET "aux_code"=\$1,"state"=\$2,"state_until"=\$3,"updated_at"=\$4 WHERE id = \$5`
+	args := []driver.Value{auxCode, to, until, sqlmock.AnyArg(), agentID}
+	if from != "" {
+		update += ` AND state = \$6`
+		args = append(args, from)
+	}
+	mock.ExpectExec(update).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, rows))
+	if applied {
+		mock.ExpectExec(`UPDATE "agent_state_history" SET .*"ended_at"=\$\d+ WHERE agent_id = \$\d+ AND ended_at IS NULL`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`INSERT INTO "agent_state_history"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestStartWrapUp(t *testing.T) {
+	tests := []struct {
+		name        string
+		queue       *sqlmock.Rows
+		disposition string
+		available   bool
+		want        time.Duration // 0 when no wrap-up starts
+	}{
+		{
+			name:        "queue default",
+			queue:       sqlmock.NewRows([]string{"name", "wrap_up_seconds"}).AddRow("sales", 30),
+			disposition: models.CallStatusCompleted, available: true,
+			want: 30 * time.Second,
+		},
+		{
+			name: "disposition override",
+			queue: sqlmock.NewRows([]string{"name", "wrap_up_seconds", "disposition_wrap_up"}).
+				AddRow("sales", 30, `{"callback-needed": 120}`),
+			disposition: models.DispositionCallbackNeeded, available: true,
+			want: 2 * time.Minute,
+		},
+		{
+			name: "override of zero turns wrap-up off",
+			queue: sqlmock.NewRows([]string{"name", "wrap_up_seconds", "disposition_wrap_up"}).
+				AddRow("sales", 30, `{"missed": 0}`),
+			disposition: models.CallStatusMissed,
+		},
+		{
+			name:        "queue without wrap-up",
+			queue:       sqlmock.NewRows([]string{"name", "wrap_up_seconds"}).AddRow("sales", 0),
+			disposition: models.CallStatusCompleted,
+		},
+		{
+			name:        "unknown queue",
+			queue:       sqlmock.NewRows([]string{"name"}),
+			disposition: models.CallStatusCompleted,
+		},
+		{
+			// The agent went on break before completing the call
+			name:        "agent not available",
+			queue:       sqlmock.NewRows([]string{"name", "wrap_up_seconds"}).AddRow("sales", 30),
+			disposition: models.CallStatusCompleted, available: false,
+			want: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAgentService(db).(*agentService)
+
+			mock.ExpectQuery(`SELECT \* FROM "queues" WHERE name = \$1`).WithArgs("sales").WillReturnRows(tt.queue)
+			if tt.want > 0 {
+				expectTransition(mock, "a1", models.AgentStateAvailable, models.AgentStateWrapUp, "", endsIn(tt.want), tt.available)
+			}
+
+			if err := s.startWrapUp("a1", "sales", tt.disposition); err != nil {
+				t.Fatalf("startWrapUp() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestEndWrapUp(t *testing.T) {
+	t.Run("in wrap-up", func(t *testing.T) {
+		db, mock := testutil.MockDB(t)
+		s := NewAgentService(db).(*agentService)
+
+		expectTransition(mock, "a1", models.AgentStateWrapUp, models.AgentStateAvailable, "", nil, true)
+		mock.ExpectQuery(`SELECT \* FROM "agents" WHERE id = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).AddRow("a1", models.AgentStateAvailable))
+
+		agent, err := s.EndWrapUp("a1")
+		if err != nil {
+			t.Fatalf("EndWrapUp() error = %v", err)
+		}
+		if agent.State != models.AgentStateAvailable {
+			t.Errorf("state = %q, want available", agent.State)
+		}
+	})
+
+	t.Run("not in wrap-up", func(t *testing.T) {
+		db, mock := testutil.MockDB(t)
+		s := NewAgentService(db).(*agentService)
+
+		expectTransition(mock, "a1", models.AgentStateWrapUp, models.AgentStateAvailable, "", nil, false)
+		if _, err := s.EndWrapUp("a1"); err == nil {
+			t.Error("EndWrapUp() succeeded for an agent not in wrap-up")
+		}
+	})
+}
+
+func TestSetAgentState(t *testing.T) {
+	agentRow := func(state, auxCode string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "state", "aux_code"}).AddRow("a1", state, auxCode)
+	}
+	auxRow := func(code string, maxSeconds int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "code", "name", "max_duration_seconds"}).AddRow(1, code, code, maxSeconds)
+	}
+
+	tests := []struct {
+		name    string
+		current *sqlmock.Rows
+		req     models.SetAgentStateRequest
+		code    *sqlmock.Rows // aux code lookup result, nil when not looked up
+		until   driver.Value  // state_until expected when the transition runs
+		after   *sqlmock.Rows // agent read back after the transition, nil when none runs
+		wantErr bool
+	}{
+		{
+			name:    "capped aux code ends automatically",
+			current: agentRow(models.AgentStateAvailable, ""),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAux, AuxCode: "lunch"},
+			code:    auxRow("lunch", 1800),
+			until:   endsIn(30 * time.Minute),
+			after:   agentRow(models.AgentStateAux, "lunch"),
+		},
+		{
+			name:    "uncapped aux code",
+			current: agentRow(models.AgentStateAvailable, ""),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAux, AuxCode: "training"},
+			code:    auxRow("training", 0),
+			until:   nil,
+			after:   agentRow(models.AgentStateAux, "training"),
+		},
+		{
+			name:    "back from overrun",
+			current: agentRow(models.AgentStateAuxOverrun, "lunch"),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAvailable},
+			until:   nil,
+			after:   agentRow(models.AgentStateAvailable, ""),
+		},
+		{
+			name:    "same state is a no-op",
+			current: agentRow(models.AgentStateAux, "lunch"),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAux, AuxCode: "lunch"},
+		},
+		{
+			name:    "aux requires a code",
+			current: agentRow(models.AgentStateAvailable, ""),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAux},
+			wantErr: true,
+		},
+		{
+			name:    "unknown aux code",
+			current: agentRow(models.AgentStateAvailable, ""),
+			req:     models.SetAgentStateRequest{State: models.AgentStateAux, AuxCode: "nap"},
+			code:    sqlmock.NewRows([]string{"id"}),
+			wantErr: true,
+		},
+		{
+			name:    "wrap-up cannot be set directly",
+			current: agentRow(models.AgentStateAvailable, ""),
+			req:     models.SetAgentStateRequest{State: models.AgentStateWrapUp},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewAgentService(db).(*agentService)
+
+			mock.ExpectQuery(`SELECT \* FROM "agents" WHERE id = \$1`).WillReturnRows(tt.current)
+			if tt.code != nil {
+				mock.ExpectQuery(`SELECT \* FROM "aux_codes" WHERE code = \$1`).WithArgs(tt.req.AuxCode).WillReturnRows(tt.code)
+			}
+			if tt.after != nil {
+				expectTransition(mock, "a1", "", tt.req.State, tt.req.AuxCode, tt.until, true)
+				mock.ExpectQuery(`SELECT \* FROM "agents" WHERE id = \$1`).WillReturnRows(tt.after)
+			}
+
+			agent, err := s.SetAgentState("a1", tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetAgentState() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (agent.State != tt.req.State || agent.AuxCode != tt.req.AuxCode) {
+				t.Errorf("agent is %s/%s, want %s/%s", agent.State, agent.AuxCode, tt.req.State, tt.req.AuxCode)
+			}
+		})
+	}
+}
+
+// Ending a capped aux code by expiry marks the closed interval as capped, which adherence reads
+func TestTransitionCapped(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	s := NewAgentService(db).(*agentService)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "agents" SET .* WHERE id = \$\d+ AND state = \$\d+`).
+		WithArgs("lunch", models.AgentStateAuxOverrun, nil, sqlmock.AnyArg(), "a1", models.AgentStateAux).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "agent_state_history" SET "capped_at"=\$1,"ended_at"=\$2 WHERE agent_id = \$3 AND ended_at IS NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "agent_state_history"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	applied, err := s.transition("a1", models.AgentStateAux, models.AgentStateAuxOverrun, "lunch", nil, true)
+	if err != nil || !applied {
+		t.Fatalf("transition() = %v, %v; want applied", applied, err)
+	}
+}
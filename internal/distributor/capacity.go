@@ -0,0 +1,187 @@
+package distributor
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// moveSlotScript moves a call's capacity slot to another agent, or frees it when the new
+// agent is empty. call_slots remembers which agent holds each call's slot, so a transfer
+// frees the previous agent's slot and duplicate events are no-ops. Counts never go below zero.
+var moveSlotScript = redis.NewScript(`
+local holder = redis.call("HGET", KEYS[2], ARGV[1])
+if holder == ARGV[2] then
+	return 0
+end
+if holder then
+	local open = tonumber(redis.call("HGET", KEYS[1], holder) or "0")
+	if open <= 1 then
+		redis.call("HDEL", KEYS[1], holder)
+	else
+		redis.call("HINCRBY", KEYS[1], holder, -1)
+	end
+end
+if ARGV[2] == "" then
+	redis.call("HDEL", KEYS[2], ARGV[1])
+else
+	redis.call("HINCRBY", KEYS[1], ARGV[2], 1)
+	redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+end
+return 1
+`)
+
+// SetDefaultCapacity sets the simultaneous call limit for agents whose queue and profile
+// set none; zero leaves them unlimited
+func (s *distributorService) SetDefaultCapacity(capacity int) {
+	s.defaultCapacity = capacity
+}
+
+// SetCallEventConsumer sets the assigned_calls consumer used to release capacity
+func (s *distributorService) SetCallEventConsumer(consumer *database.KafkaConsumer) {
+	s.callEventConsumer = consumer
+}
+
+// routable drops candidates who are not signed in, not available (e.g. in wrap-up) or who
+// already hold as many calls as they are allowed. If their state cannot be read it returns
+// none, leaving the calls queued until the next tick.
+func (s *distributorService) routable(ctx context.Context, queue models.Queue, candidates []string) []string {
+	var agents []models.Agent
+	if err := s.db.Select("id", "max_concurrent_calls", "state").
+		Where("id IN ?", candidates).
+		Scopes(models.LiveSession).
+		Find(&agents).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to load agent capacities", "error", err)
+		return nil
+	}
+	limits := make(map[string]int, len(agents))
+	available := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		limits[agent.ID] = agent.MaxConcurrentCalls
+		available[agent.ID] = agent.State == models.AgentStateAvailable
+	}
+
+	open, err := s.redis.HMGet(ctx, "agent_open_calls", candidates...).Result()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load agent open calls", "error", err)
+		return nil
+	}
+
+	eligible := make([]string, 0, len(candidates))
+	for i, agentID := range candidates {
+		if !available[agentID] {
+			continue
+		}
+
+		limit := limits[agentID]
+		if limit == 0 {
+			limit = queue.AgentCapacity
+		}
+		if limit == 0 {
+			limit = s.defaultCapacity
+		}
+
+		var count int
+		if value, ok := open[i].(string); ok {
+			fmt.Sscan(value, &count)
+		}
+		if limit == 0 || count < limit {
+			eligible = append(eligible, agentID)
+		}
+	}
+	return eligible
+}
+
+// holdCapacity makes agentID hold the call's capacity slot, moving it from any previous agent
+func (s *distributorService) holdCapacity(ctx context.Context, callID, agentID string) {
+	if err := moveSlotScript.Run(ctx, s.redis, []string{"agent_open_calls", "call_slots"}, callID, agentID).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to hold agent capacity", "call_id", callID, "agent_id", agentID, "error", err)
+	}
+}
+
+// releaseCapacity frees the slot the call was holding, if any
+func (s *distributorService) releaseCapacity(ctx context.Context, callID string) {
+	if err := moveSlotScript.Run(ctx, s.redis, []string{"agent_open_calls", "call_slots"}, callID, "").Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to release agent capacity", "call_id", callID, "error", err)
+	}
+}
+
+// StartCapacityConsumer releases a call's slot whenever it leaves the assigned state
+// (completed, dispositioned or abandoned), and moves it when an assigned call is
+// transferred to another agent
+func (s *distributorService) StartCapacityConsumer(ctx context.Context) error {
+	if s.callEventConsumer == nil {
+		return fmt.Errorf("call event consumer not initialized")
+	}
+
+	return s.callEventConsumer.ConsumeAssignedCalls(ctx, func(callCtx context.Context, call models.AssignedCall) error {
+		if call.Status == models.CallStatusAssigned {
+			if call.AssignedAgentID != "" {
+				s.holdCapacity(callCtx, call.CallID, call.AssignedAgentID)
+			}
+			return nil
+		}
+		s.releaseCapacity(callCtx, call.CallID)
+		return nil
+	})
+}
+
+// StartReconciler recomputes open call counts from Postgres every interval, correcting drift
+// from missed or duplicated events, until ctx is canceled
+func (s *distributorService) StartReconciler(ctx context.Context, interval time.Duration) {
+	s.reconcileCapacity(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reconcileCapacity(ctx)
+		}
+	}
+}
+
+// reconcileCapacity replaces agent_open_calls and call_slots with the assigned calls recorded
+// per agent. It holds the dispatch lock so no assignment is counted between the query and the write.
+func (s *distributorService) reconcileCapacity(ctx context.Context) {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	var rows []struct {
+		CallID          string
+		AssignedAgentID string
+	}
+	err := s.db.Model(&models.AssignedCall{}).
+		Select("call_id, assigned_agent_id").
+		Where("status = ? AND assigned_agent_id <> ''", models.CallStatusAssigned).
+		Scan(&rows).Error
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reconcile agent capacity", "error", err)
+		return
+	}
+
+	open := make(map[string]int64)
+	for _, row := range rows {
+		open[row.AssignedAgentID]++
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, "agent_open_calls", "call_slots")
+	for agentID, count := range open {
+		pipe.HSet(ctx, "agent_open_calls", agentID, count)
+	}
+	for _, row := range rows {
+		pipe.HSet(ctx, "call_slots", row.CallID, row.AssignedAgentID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to write reconciled agent capacity", "error", err)
+	}
+}
@@ -15,3 +15,12 @@ func NewDistributorHandler(service DistributorService) *DistributorHandler {
 func (h *DistributorHandler) HealthCheck(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "ok"})
 }
+
+// Status reports whether each queue is open for business
+func (h *DistributorHandler) Status(c *fiber.Ctx) error {
+	queues, err := h.service.QueueStates()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"status": "ok", "queues": queues})
+}
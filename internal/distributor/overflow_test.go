@@ -0,0 +1,269 @@
+package distributor
+
+import (
+	"call-center-api/internal/routing"
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// overflowRouting serves overflow rules from memory
+type overflowRouting struct {
+	routing.RoutingService
+	rules map[string][]models.OverflowRule
+}
+
+func (r overflowRouting) ListOverflowRules(queue string) ([]models.OverflowRule, error) {
+	return r.rules[queue], nil
+}
+
+func newOverflowService(t *testing.T, rules map[string][]models.OverflowRule) *distributorService {
+	client, _ := testutil.Redis(t)
+	s := NewDistributorService(nil, nil, client, testutil.DryRunDB(t)).(*distributorService)
+	s.SetRoutingService(overflowRouting{rules: rules})
+	return s
+}
+
+// parkAt parks a call in queue that arrived at arrived and entered the queue at queued
+func parkAt(t *testing.T, s *distributorService, callID, queue string, arrived, queued time.Time) {
+	t.Helper()
+	call := waitingCall{
+		IncomingCall:  models.IncomingCall{CallID: callID, CustomerNumber: "+16502530000", Queue: queue, Timestamp: arrived},
+		EnqueuedAt:    queued,
+		QueuedAt:      queued,
+		OriginalQueue: queue,
+	}
+	if err := s.parkCall(context.Background(), call); err != nil {
+		t.Fatalf("parkCall() error = %v", err)
+	}
+}
+
+func waitingIn(t *testing.T, s *distributorService, callID string) (waitingCall, bool) {
+	t.Helper()
+	data, err := s.redis.HGet(context.Background(), "waiting_calls", callID).Result()
+	if err != nil {
+		return waitingCall{}, false
+	}
+	var call waitingCall
+	if err := json.Unmarshal([]byte(data), &call); err != nil {
+		t.Fatalf("invalid waiting call: %v", err)
+	}
+	return call, true
+}
+
+func TestOverflowCall(t *testing.T) {
+	ctx := context.Background()
+	arrived := time.Now().Add(-10 * time.Minute)
+
+	tests := []struct {
+		name      string
+		claimed   bool // another dispatcher already took the waiting_calls entry
+		keep      bool
+		wantMoved bool
+	}{
+		{name: "to the back", wantMoved: true},
+		{name: "keeping position", keep: true, wantMoved: true},
+		{name: "already claimed", claimed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newOverflowService(t, nil)
+			parkAt(t, s, "c1", "sales", arrived, arrived)
+			call, _ := waitingIn(t, s, "c1")
+			if tt.claimed {
+				s.redis.HDel(ctx, "waiting_calls", "c1")
+			}
+
+			rule := models.OverflowRule{Queue: "sales", TargetQueue: "support", KeepPosition: tt.keep}
+			before := time.Now()
+			if moved := s.overflowCall(ctx, call, rule, "test"); moved != tt.wantMoved {
+				t.Fatalf("overflowCall() = %v, want %v", moved, tt.wantMoved)
+			}
+
+			if !tt.wantMoved {
+				// The call stays where it was, and nothing is recreated for it
+				if n, _ := s.redis.ZCard(ctx, "call_queue:support").Result(); n != 0 {
+					t.Errorf("target queue has %d calls, want 0", n)
+				}
+				if n, _ := s.redis.ZCard(ctx, "call_queue:sales").Result(); n != 1 {
+					t.Errorf("source queue has %d calls, want 1", n)
+				}
+				return
+			}
+
+			if n, _ := s.redis.ZCard(ctx, "call_queue:sales").Result(); n != 0 {
+				t.Errorf("source queue has %d calls, want 0", n)
+			}
+			if n, _ := s.redis.ZCard(ctx, "call_queue_entered:sales").Result(); n != 0 {
+				t.Errorf("source entry set has %d calls, want 0", n)
+			}
+			if queue, _ := s.redis.HGet(ctx, "call_queue_index", "c1").Result(); queue != "support" {
+				t.Errorf("call_queue_index = %q, want support", queue)
+			}
+
+			score, err := s.redis.ZScore(ctx, "call_queue:support", "c1").Result()
+			if err != nil {
+				t.Fatalf("call missing from target queue: %v", err)
+			}
+			if tt.keep && score != float64(arrived.UnixNano()) {
+				t.Errorf("score = %v, want original arrival %v", score, float64(arrived.UnixNano()))
+			}
+			if !tt.keep && score < float64(before.UnixNano()) {
+				t.Errorf("score = %v, want the back of the queue", score)
+			}
+
+			moved, ok := waitingIn(t, s, "c1")
+			if !ok {
+				t.Fatal("waiting_calls entry missing after overflow")
+			}
+			if moved.Queue != "support" || moved.Overflows != 1 || len(moved.Visited) != 1 || moved.Visited[0] != "sales" {
+				t.Errorf("moved call = queue %s, overflows %d, visited %v", moved.Queue, moved.Overflows, moved.Visited)
+			}
+			if moved.QueuedAt.Before(before) {
+				t.Errorf("QueuedAt = %v, want the time of the move", moved.QueuedAt)
+			}
+			if !moved.EnqueuedAt.Equal(call.EnqueuedAt) {
+				t.Errorf("EnqueuedAt = %v, want it unchanged at %v", moved.EnqueuedAt, call.EnqueuedAt)
+			}
+		})
+	}
+}
+
+func TestApplyOverflow(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	hop := func(from, to string) models.OverflowRule {
+		return models.OverflowRule{Queue: from, TargetQueue: to, MaxWaitSeconds: 60, KeepPosition: true}
+	}
+
+	tests := []struct {
+		name    string
+		rules   map[string][]models.OverflowRule
+		arrived time.Duration // how long ago the caller says the call arrived
+		queued  time.Duration // how long ago the call entered sales
+		queues  []string      // queues evaluated in order within one tick
+		want    string
+	}{
+		{
+			name:    "waited past the limit",
+			rules:   map[string][]models.OverflowRule{"sales": {hop("sales", "support")}},
+			arrived: 2 * time.Minute, queued: 2 * time.Minute,
+			queues: []string{"sales"},
+			want:   "support",
+		},
+		{
+			name:    "within the limit",
+			rules:   map[string][]models.OverflowRule{"sales": {hop("sales", "support")}},
+			arrived: 30 * time.Second, queued: 30 * time.Second,
+			queues: []string{"sales"},
+			want:   "sales",
+		},
+		{
+			name:    "old arrival time but recently queued",
+			rules:   map[string][]models.OverflowRule{"sales": {hop("sales", "support")}},
+			arrived: time.Hour, queued: 10 * time.Second,
+			queues: []string{"sales"},
+			want:   "sales",
+		},
+		{
+			name: "kept position does not cascade through every hop",
+			rules: map[string][]models.OverflowRule{
+				"sales":   {hop("sales", "support")},
+				"support": {hop("support", "billing")},
+			},
+			arrived: time.Hour, queued: 2 * time.Minute,
+			queues: []string{"sales", "support"},
+			want:   "support",
+		},
+		{
+			name: "never back into a visited queue",
+			rules: map[string][]models.OverflowRule{
+				"sales":   {hop("sales", "support")},
+				"support": {hop("support", "sales")},
+			},
+			arrived: 2 * time.Minute, queued: 2 * time.Minute,
+			queues: []string{"sales"},
+			want:   "support",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newOverflowService(t, tt.rules)
+			parkAt(t, s, "c1", "sales", now.Add(-tt.arrived), now.Add(-tt.queued))
+
+			for _, queue := range tt.queues {
+				s.applyOverflow(ctx, queue)
+			}
+
+			if queue, _ := s.redis.HGet(ctx, "call_queue_index", "c1").Result(); queue != tt.want {
+				t.Errorf("call is in %q, want %q", queue, tt.want)
+			}
+			if _, ok := waitingIn(t, s, "c1"); !ok {
+				t.Error("waiting_calls entry missing")
+			}
+		})
+	}
+}
+
+func TestOverflowCandidates(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		rules []models.OverflowRule
+		want  []string
+	}{
+		{
+			name:  "wait limit",
+			rules: []models.OverflowRule{{MaxWaitSeconds: 60}},
+			want:  []string{"old", "moved"},
+		},
+		{
+			name:  "size limit",
+			rules: []models.OverflowRule{{MaxQueueSize: 2}},
+			want:  []string{"moved", "fresh"},
+		},
+		{
+			name:  "both without duplicates",
+			rules: []models.OverflowRule{{MaxWaitSeconds: 60}, {MaxQueueSize: 1}},
+			want:  []string{"old", "recent", "moved", "fresh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newOverflowService(t, nil)
+			// Queue order follows arrival; "recent" arrived early but only just entered the queue
+			parkAt(t, s, "old", "sales", now.Add(-5*time.Minute), now.Add(-5*time.Minute))
+			parkAt(t, s, "recent", "sales", now.Add(-4*time.Minute), now.Add(-10*time.Second))
+			parkAt(t, s, "moved", "sales", now.Add(-3*time.Minute), now.Add(-2*time.Minute))
+			parkAt(t, s, "fresh", "sales", now.Add(-5*time.Second), now.Add(-5*time.Second))
+
+			candidates, err := s.overflowCandidates(ctx, "sales", tt.rules, now)
+			if err != nil {
+				t.Fatalf("overflowCandidates() error = %v", err)
+			}
+			var got []string
+			for i, candidate := range candidates {
+				got = append(got, candidate.Member.(string))
+				if i > 0 && candidate.rank <= candidates[i-1].rank {
+					t.Errorf("candidates out of queue order: %v", candidates)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("candidates = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("candidates = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
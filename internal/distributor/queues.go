@@ -0,0 +1,670 @@
+package distributor
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// dispatchInterval is how often parked calls are retried against newly available agents
+	dispatchInterval = time.Second
+	// dispatchBatchSize caps how many waiting calls are examined per queue per pass
+	dispatchBatchSize = 100
+)
+
+// StartDispatcher periodically serves waiting calls in queue priority order and applies
+// overflow rules until ctx is canceled
+func (s *distributorService) StartDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			queues, err := s.queues()
+			if err != nil {
+				slog.Error("Failed to load queues", "error", err)
+				continue
+			}
+			for _, queue := range queues {
+				s.dispatchQueue(ctx, queue)
+				s.applyOverflow(ctx, queue.Name)
+			}
+			s.reportQueueDepth(ctx, queues)
+		}
+	}
+}
+
+// reportQueueDepth sets the queue_depth gauge for each queue, dropping queues that no longer exist
+func (s *distributorService) reportQueueDepth(ctx context.Context, queues []models.Queue) {
+	depths := make(map[string]int64, len(queues))
+	for _, queue := range queues {
+		waiting, err := s.redis.ZCard(ctx, "call_queue:"+queue.Name).Result()
+		if err != nil {
+			slog.Error("Failed to read queue depth", "queue", queue.Name, "error", err)
+			return
+		}
+		depths[queue.Name] = waiting
+	}
+
+	metrics.QueueDepth.Reset()
+	for name, waiting := range depths {
+		metrics.QueueDepth.WithLabelValues(name).Set(float64(waiting))
+	}
+}
+
+// queues returns every queue highest priority first. The default queue is always included,
+// with round-robin routing unless it has been configured explicitly.
+func (s *distributorService) queues() ([]models.Queue, error) {
+	defaultQueue := models.Queue{Name: models.DefaultQueue, Strategy: models.StrategyRoundRobin}
+	if s.routing == nil {
+		return []models.Queue{defaultQueue}, nil
+	}
+
+	queues, err := s.routing.ListQueues()
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		if queue.Name == models.DefaultQueue {
+			return queues, nil
+		}
+	}
+
+	// Slot the implicit default queue in by its zero priority
+	for i, queue := range queues {
+		if queue.Priority < 0 {
+			return append(queues[:i], append([]models.Queue{defaultQueue}, queues[i:]...)...), nil
+		}
+	}
+	return append(queues, defaultQueue), nil
+}
+
+// queueConfig looks up a single queue's routing settings
+func (s *distributorService) queueConfig(name string) models.Queue {
+	if s.routing != nil {
+		if queue, err := s.routing.GetQueue(name); err == nil {
+			return *queue
+		}
+	}
+	return models.Queue{Name: name, Strategy: models.StrategyRoundRobin}
+}
+
+// waitingCall is a parked call along with the queue history overflow rules need
+type waitingCall struct {
+	models.IncomingCall
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// QueuedAt is when the call entered its current queue; overflow wait limits count from it
+	QueuedAt      time.Time `json:"queued_at"`
+	OriginalQueue string    `json:"original_queue"`
+	Overflows     int       `json:"overflows,omitempty"`
+	Visited       []string  `json:"visited,omitempty"`
+	// Trace and CorrelationID carry the call's trace context and log correlation ID while it
+	// is parked in Redis
+	Trace         map[string]string `json:"trace,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+}
+
+func newWaitingCall(ctx context.Context, call models.IncomingCall) waitingCall {
+	now := time.Now()
+	waiting := waitingCall{
+		IncomingCall:  call,
+		EnqueuedAt:    now,
+		QueuedAt:      now,
+		OriginalQueue: call.Queue,
+		Trace:         map[string]string{},
+		CorrelationID: logger.CorrelationID(ctx),
+	}
+	tracing.Inject(ctx, waiting.Trace)
+	return waiting
+}
+
+// record builds the assigned_calls row shared by every outcome of the call
+func (c waitingCall) record() models.AssignedCall {
+	record := models.AssignedCall{
+		CallID:         c.CallID,
+		CustomerNumber: c.CustomerNumber,
+		RawNumber:      c.RawNumber,
+		Queue:          c.Queue,
+		ReceivedAt:     c.Timestamp,
+		OverflowCount:  c.Overflows,
+	}
+	if c.Overflows > 0 {
+		record.OriginalQueue = c.OriginalQueue
+	}
+	return record
+}
+
+// parkCall holds a call in its queue until an eligible agent is free, ordered by arrival time.
+// call_queue_entered tracks when each call entered the queue, for overflow wait limits. The
+// distributor is the only writer of the queue sets; ZAddNX keeps a redelivered call at its
+// original position.
+func (s *distributorService) parkCall(ctx context.Context, call waitingCall) error {
+	data, err := json.Marshal(call)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.redis.TxPipeline()
+	pipe.ZAddNX(ctx, "call_queue:"+call.Queue, redis.Z{
+		Score:  float64(call.Timestamp.UnixNano()),
+		Member: call.CallID,
+	})
+	pipe.ZAddNX(ctx, "call_queue_entered:"+call.Queue, redis.Z{
+		Score:  float64(call.QueuedAt.UnixNano()),
+		Member: call.CallID,
+	})
+	pipe.HSet(ctx, "call_queue_index", call.CallID, call.Queue)
+	pipe.HSet(ctx, "waiting_calls", call.CallID, data)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// leaveQueue removes every trace of a call from the waiting structures
+func (s *distributorService) leaveQueue(ctx context.Context, call models.IncomingCall) {
+	pipe := s.redis.TxPipeline()
+	pipe.ZRem(ctx, "call_queue:"+call.Queue, call.CallID)
+	pipe.ZRem(ctx, "call_queue_entered:"+call.Queue, call.CallID)
+	pipe.HDel(ctx, "call_queue_index", call.CallID)
+	pipe.HDel(ctx, "waiting_calls", call.CallID)
+	pipe.Del(ctx, "accepted_call:"+call.CallID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		slog.WarnContext(ctx, "Failed to remove call from queue", "call_id", call.CallID, "queue", call.Queue, "error", err)
+	}
+}
+
+// dropQueueEntry removes a call ID from a queue's sets when it has no call behind it
+func (s *distributorService) dropQueueEntry(ctx context.Context, queue, callID string) {
+	pipe := s.redis.TxPipeline()
+	pipe.ZRem(ctx, "call_queue:"+queue, callID)
+	pipe.ZRem(ctx, "call_queue_entered:"+queue, callID)
+	pipe.Exec(ctx)
+}
+
+// dispatchQueue assigns waiting calls in arrival order until the queue runs out of agents.
+// Each call is claimed by deleting it from waiting_calls, so concurrent dispatchers never
+// assign the same call twice; calls that cannot be placed are parked again.
+func (s *distributorService) dispatchQueue(ctx context.Context, queue models.Queue) {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	callIDs, err := s.redis.ZRange(ctx, "call_queue:"+queue.Name, 0, dispatchBatchSize-1).Result()
+	if err != nil || len(callIDs) == 0 {
+		return
+	}
+
+	members, restricted, err := s.queueMembers(queue.Name)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load queue members", "queue", queue.Name, "error", err)
+		return
+	}
+
+	for _, callID := range callIDs {
+		// Calls another dispatcher has claimed have no waiting_calls entry; skip them. Entries
+		// left with no index at all belong to no call and would block the head of the queue.
+		data, err := s.redis.HGet(ctx, "waiting_calls", callID).Result()
+		if err != nil {
+			if err == redis.Nil {
+				if indexed, _ := s.redis.HExists(ctx, "call_queue_index", callID).Result(); !indexed {
+					s.dropQueueEntry(ctx, queue.Name, callID)
+				}
+			}
+			continue
+		}
+		if claimed, err := s.redis.HDel(ctx, "waiting_calls", callID).Result(); err != nil || claimed == 0 {
+			continue
+		}
+
+		var call waitingCall
+		if err := json.Unmarshal([]byte(data), &call); err != nil {
+			slog.ErrorContext(ctx, "Failed to decode waiting call", "call_id", callID, "queue", queue.Name, "error", err)
+			s.dropQueueEntry(ctx, queue.Name, callID)
+			continue
+		}
+
+		if abandonedAt, ok := s.abandonedAt(call.CallID); ok {
+			s.leaveQueue(ctx, call.IncomingCall)
+			s.recordQueuedAbandon(ctx, call, abandonedAt)
+			continue
+		}
+
+		// A queue nobody staffs can only drain through its overflow rules
+		if restricted && len(members) == 0 {
+			if s.hasOverflowRules(queue.Name) {
+				s.redis.HSet(ctx, "waiting_calls", callID, data)
+				return
+			}
+			s.leaveQueue(ctx, call.IncomingCall)
+			s.recordUnassignedCall(ctx, call, models.CallStatusDropped, "no agents in queue")
+			continue
+		}
+
+		affinityAgentID := s.lastAgentFor(call.CustomerNumber)
+		agentID, routedBy := s.selectAgent(ctx, queue, members, restricted, call.CallID, call.PreferredAgentID, affinityAgentID)
+		if agentID == "" {
+			// Every later call would face the same agents, so stop here
+			s.redis.HSet(ctx, "waiting_calls", callID, data)
+			return
+		}
+
+		if err := s.assignCall(ctx, call, agentID, routedBy, affinityAgentID); err != nil {
+			slog.ErrorContext(ctx, "Failed to assign call", "call_id", call.CallID, "agent_id", agentID, "error", err)
+			s.releaseCapacity(ctx, call.CallID)
+			s.redis.HSet(ctx, "waiting_calls", callID, data)
+			return
+		}
+	}
+}
+
+// queueMembers returns agent weights for a queue. restricted is false when any available
+// agent may serve it, which is the case for the default queue until members are added.
+func (s *distributorService) queueMembers(queue string) (map[string]int, bool, error) {
+	if s.routing == nil {
+		return nil, false, nil
+	}
+
+	memberships, err := s.routing.ListMembers(queue)
+	if err != nil {
+		return nil, false, err
+	}
+	if queue == models.DefaultQueue && len(memberships) == 0 {
+		return nil, false, nil
+	}
+
+	members := make(map[string]int, len(memberships))
+	for _, membership := range memberships {
+		members[membership.AgentID] = membership.Weight
+	}
+	return members, true, nil
+}
+
+// selectAgent tries the preferred agent, then the affinity agent, then the queue's strategy.
+// Only available agents who belong to the queue, are not in wrap-up and have spare capacity
+// are considered.
+// It returns the chosen agent, who now holds the call's capacity slot, and how they were chosen.
+func (s *distributorService) selectAgent(ctx context.Context, queue models.Queue, members map[string]int, restricted bool, callID, preferredAgentID, affinityAgentID string) (string, string) {
+	available, err := s.redis.LRange(ctx, "available_agents", 0, -1).Result()
+	if err != nil {
+		return "", ""
+	}
+
+	candidates := make([]string, 0, len(available))
+	for _, agentID := range available {
+		if _, ok := members[agentID]; ok || !restricted {
+			candidates = append(candidates, agentID)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", ""
+	}
+
+	candidates = s.routable(ctx, queue, candidates)
+	if len(candidates) == 0 {
+		return "", ""
+	}
+
+	eligible := func(agentID string) bool {
+		for _, candidate := range candidates {
+			if candidate == agentID {
+				return true
+			}
+		}
+		return false
+	}
+
+	var agentID, routedBy string
+	switch {
+	case preferredAgentID != "" && eligible(preferredAgentID):
+		agentID, routedBy = preferredAgentID, models.RoutedByPreferred
+	case affinityAgentID != "" && eligible(affinityAgentID):
+		agentID, routedBy = affinityAgentID, models.RoutedByAffinity
+	default:
+		switch queue.Strategy {
+		case models.StrategyLongestIdle:
+			agentID, routedBy = s.longestIdle(ctx, candidates), models.RoutedByLongestIdle
+		case models.StrategyWeighted:
+			agentID, routedBy = weightedPick(candidates, members), models.RoutedByWeighted
+		default:
+			agentID, routedBy = s.nextInRotation(ctx, queue.Name, candidates), models.RoutedByRoundRobin
+		}
+	}
+
+	if !s.takeAgent(ctx, agentID) {
+		return "", ""
+	}
+
+	now := float64(time.Now().UnixNano())
+	pipe := s.redis.Pipeline()
+	pipe.ZAdd(ctx, "queue_rotation:"+queue.Name, redis.Z{Score: now, Member: agentID})
+	pipe.HSet(ctx, "agent_last_assigned", agentID, int64(now))
+	pipe.Exec(ctx)
+	s.holdCapacity(ctx, callID, agentID)
+
+	slog.DebugContext(ctx, "Selected agent", "agent_id", agentID, "queue", queue.Name, "routed_by", routedBy)
+	return agentID, routedBy
+}
+
+// nextInRotation picks the candidate this queue assigned least recently; agents it has never
+// used come first, in available_agents order
+func (s *distributorService) nextInRotation(ctx context.Context, queue string, candidates []string) string {
+	scores, err := s.redis.ZMScore(ctx, "queue_rotation:"+queue, candidates...).Result()
+	if err != nil {
+		return candidates[0]
+	}
+
+	best := 0
+	for i := range candidates {
+		if scores[i] < scores[best] {
+			best = i
+		}
+	}
+	return candidates[best]
+}
+
+// longestIdle picks the candidate whose last assignment in any queue is oldest
+func (s *distributorService) longestIdle(ctx context.Context, candidates []string) string {
+	values, err := s.redis.HMGet(ctx, "agent_last_assigned", candidates...).Result()
+	if err != nil {
+		return candidates[0]
+	}
+
+	best, bestAt := 0, int64(-1)
+	for i, value := range values {
+		var at int64
+		if str, ok := value.(string); ok {
+			fmt.Sscan(str, &at)
+		}
+		if bestAt < 0 || at < bestAt {
+			best, bestAt = i, at
+		}
+	}
+	return candidates[best]
+}
+
+// weightedPick chooses a candidate at random in proportion to their membership weight
+func weightedPick(candidates []string, members map[string]int) string {
+	total := 0
+	for _, agentID := range candidates {
+		total += weightOf(members, agentID)
+	}
+
+	n := rand.Intn(total)
+	for _, agentID := range candidates {
+		n -= weightOf(members, agentID)
+		if n < 0 {
+			return agentID
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func weightOf(members map[string]int, agentID string) int {
+	if weight, ok := members[agentID]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+func (s *distributorService) hasOverflowRules(queue string) bool {
+	if s.routing == nil {
+		return false
+	}
+	rules, err := s.routing.ListOverflowRules(queue)
+	return err == nil && len(rules) > 0
+}
+
+// applyOverflow moves waiting calls that breach one of the queue's overflow rules to the
+// rule's target queue. The first matching rule wins, and a call never overflows back into
+// a queue it has already visited.
+func (s *distributorService) applyOverflow(ctx context.Context, queue string) {
+	if s.routing == nil {
+		return
+	}
+	rules, err := s.routing.ListOverflowRules(queue)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load overflow rules", "queue", queue, "error", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+
+	now := time.Now()
+	candidates, err := s.overflowCandidates(ctx, queue, rules, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load overflow candidates", "queue", queue, "error", err)
+		return
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	callIDs := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		callIDs[i] = candidate.Member.(string)
+	}
+	waiting, err := s.redis.HMGet(ctx, "waiting_calls", callIDs...).Result()
+	if err != nil {
+		return
+	}
+
+	moved := 0
+	for i, candidate := range candidates {
+		data, ok := waiting[i].(string)
+		if !ok {
+			continue
+		}
+		var call waitingCall
+		if err := json.Unmarshal([]byte(data), &call); err != nil {
+			continue
+		}
+
+		// Wait limits count from when the call entered this queue, whatever its place in line
+		queuedAt := call.QueuedAt
+		if queuedAt.IsZero() {
+			queuedAt = call.EnqueuedAt
+		}
+		waited := now.Sub(queuedAt)
+		// Ranks shift forward as earlier calls leave the queue
+		position := candidate.rank - moved
+		for _, rule := range rules {
+			if call.hasVisited(rule.TargetQueue) {
+				continue
+			}
+
+			var reason string
+			switch {
+			case rule.MaxWaitSeconds > 0 && waited >= time.Duration(rule.MaxWaitSeconds)*time.Second:
+				reason = fmt.Sprintf("waited more than %ds", rule.MaxWaitSeconds)
+			case rule.MaxQueueSize > 0 && position >= rule.MaxQueueSize:
+				reason = fmt.Sprintf("queue longer than %d calls", rule.MaxQueueSize)
+			default:
+				continue
+			}
+
+			if s.overflowCall(ctx, call, rule, reason) {
+				moved++
+			}
+			break
+		}
+	}
+}
+
+// rankedCall is a queued call with its rank in the queue
+type rankedCall struct {
+	redis.Z
+	rank int
+}
+
+// overflowCandidates returns, in queue order, only the calls that could break a rule: those
+// that entered the queue longer ago than the shortest wait limit and those beyond the
+// smallest size limit
+func (s *distributorService) overflowCandidates(ctx context.Context, queue string, rules []models.OverflowRule, now time.Time) ([]rankedCall, error) {
+	minWait, minSize := 0, 0
+	for _, rule := range rules {
+		if rule.MaxWaitSeconds > 0 && (minWait == 0 || rule.MaxWaitSeconds < minWait) {
+			minWait = rule.MaxWaitSeconds
+		}
+		if rule.MaxQueueSize > 0 && (minSize == 0 || rule.MaxQueueSize < minSize) {
+			minSize = rule.MaxQueueSize
+		}
+	}
+
+	key := "call_queue:" + queue
+	seen := make(map[string]bool)
+	var candidates []rankedCall
+
+	// Calls that have waited long enough, found by entry time and ranked by queue order
+	if minWait > 0 {
+		cutoff := now.Add(-time.Duration(minWait) * time.Second).UnixNano()
+		waited, err := s.redis.ZRangeByScore(ctx, "call_queue_entered:"+queue, &redis.ZRangeBy{
+			Min: "-inf",
+			Max: strconv.FormatInt(cutoff, 10),
+		}).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(waited) > 0 {
+			pipe := s.redis.Pipeline()
+			ranks := make([]*redis.RankWithScoreCmd, len(waited))
+			for i, callID := range waited {
+				ranks[i] = pipe.ZRankWithScore(ctx, key, callID)
+			}
+			pipe.Exec(ctx)
+
+			for i, callID := range waited {
+				rank, err := ranks[i].Result()
+				if err != nil {
+					continue
+				}
+				seen[callID] = true
+				candidates = append(candidates, rankedCall{
+					Z:    redis.Z{Score: rank.Score, Member: callID},
+					rank: int(rank.Rank),
+				})
+			}
+		}
+	}
+
+	// Calls beyond the size limit, skipping any the wait limit already returned
+	if minSize > 0 {
+		tail, err := s.redis.ZRangeWithScores(ctx, key, int64(minSize), -1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, z := range tail {
+			if !seen[z.Member.(string)] {
+				candidates = append(candidates, rankedCall{Z: z, rank: minSize + i})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].rank < candidates[j].rank })
+	return candidates, nil
+}
+
+func (c waitingCall) hasVisited(queue string) bool {
+	if queue == c.OriginalQueue {
+		return true
+	}
+	for _, visited := range c.Visited {
+		if visited == queue {
+			return true
+		}
+	}
+	return false
+}
+
+// overflowScript claims a waiting call and moves it to another queue in one step, so a
+// failure part way can never leave the call queued without its waiting_calls entry. The call
+// keeps its place in line when ARGV[4] is "1" and goes to the back otherwise; either way its
+// entry time in the target queue is now.
+var overflowScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local score = redis.call("ZSCORE", KEYS[2], ARGV[1])
+if not score then
+	return 0
+end
+if ARGV[4] ~= "1" then
+	score = ARGV[3]
+end
+redis.call("ZREM", KEYS[2], ARGV[1])
+redis.call("ZREM", KEYS[5], ARGV[1])
+redis.call("ZADD", KEYS[3], score, ARGV[1])
+redis.call("ZADD", KEYS[6], ARGV[3], ARGV[1])
+redis.call("HSET", KEYS[4], ARGV[1], ARGV[5])
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// overflowCall claims a waiting call and parks it in the rule's target queue, either at its
+// original arrival position or at the back
+func (s *distributorService) overflowCall(ctx context.Context, call waitingCall, rule models.OverflowRule, reason string) bool {
+	from := call.Queue
+	now := time.Now()
+
+	call.Visited = append(call.Visited, from)
+	call.Queue = rule.TargetQueue
+	call.QueuedAt = now
+	call.Overflows++
+
+	data, err := json.Marshal(call)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to encode overflowed call", "call_id", call.CallID, "error", err)
+		return false
+	}
+
+	keep := "0"
+	if rule.KeepPosition {
+		keep = "1"
+	}
+	moved, err := overflowScript.Run(ctx, s.redis,
+		[]string{"waiting_calls", "call_queue:" + from, "call_queue:" + call.Queue, "call_queue_index",
+			"call_queue_entered:" + from, "call_queue_entered:" + call.Queue},
+		call.CallID, data, strconv.FormatInt(now.UnixNano(), 10), keep, call.Queue).Int()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to overflow call", "call_id", call.CallID, "queue", call.Queue, "error", err)
+		return false
+	}
+	if moved == 0 {
+		// Another dispatcher claimed the call first
+		return false
+	}
+
+	s.recordEvent(models.CallEvent{
+		CallID:    call.CallID,
+		Type:      models.CallEventOverflowed,
+		Queue:     call.Queue,
+		FromQueue: from,
+		Detail:    reason,
+	})
+	slog.InfoContext(ctx, "Call overflowed", "call_id", call.CallID, "from_queue", from, "queue", call.Queue, "reason", reason)
+	return true
+}
+
+// recordEvent appends an entry to a call's timeline; failures are logged, not returned
+func (s *distributorService) recordEvent(event models.CallEvent) {
+	if err := s.db.Create(&event).Error; err != nil {
+		slog.Error("Failed to record call event", "call_id", event.CallID, "type", event.Type, "error", err)
+	}
+}
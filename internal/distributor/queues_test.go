@@ -0,0 +1,144 @@
+package distributor
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestWeightedPick(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		members    map[string]int
+		want       map[string]float64 // expected share of picks per agent
+	}{
+		{
+			name:       "single candidate",
+			candidates: []string{"a"},
+			members:    map[string]int{"a": 5},
+			want:       map[string]float64{"a": 1},
+		},
+		{
+			name:       "proportional to weight",
+			candidates: []string{"a", "b"},
+			members:    map[string]int{"a": 3, "b": 1},
+			want:       map[string]float64{"a": 0.75, "b": 0.25},
+		},
+		{
+			name:       "missing and zero weights count as one",
+			candidates: []string{"a", "b", "c"},
+			members:    map[string]int{"a": 2, "b": 0},
+			want:       map[string]float64{"a": 0.5, "b": 0.25, "c": 0.25},
+		},
+		{
+			name:       "non-candidate members are ignored",
+			candidates: []string{"a", "b"},
+			members:    map[string]int{"a": 1, "b": 1, "z": 100},
+			want:       map[string]float64{"a": 0.5, "b": 0.5},
+		},
+	}
+
+	const draws = 20000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := make(map[string]int)
+			for i := 0; i < draws; i++ {
+				counts[weightedPick(tt.candidates, tt.members)]++
+			}
+
+			for agentID := range counts {
+				if _, ok := tt.want[agentID]; !ok {
+					t.Fatalf("picked %q, which is not a candidate", agentID)
+				}
+			}
+			for agentID, share := range tt.want {
+				got := float64(counts[agentID]) / draws
+				if math.Abs(got-share) > 0.02 {
+					t.Errorf("%s picked %.3f of the time, want %.3f", agentID, got, share)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectAgentAffinity(t *testing.T) {
+	ctx := context.Background()
+	queue := models.Queue{Name: "sales", Strategy: models.StrategyRoundRobin}
+
+	tests := []struct {
+		name      string
+		members   map[string]int
+		openCalls map[string]string // agent ID -> open call count
+		preferred string
+		affinity  string
+		wantAgent string
+		wantBy    string
+	}{
+		{
+			name:      "last agent is free",
+			affinity:  "a2",
+			wantAgent: "a2", wantBy: models.RoutedByAffinity,
+		},
+		{
+			name:      "preferred agent wins over the last agent",
+			preferred: "a3", affinity: "a2",
+			wantAgent: "a3", wantBy: models.RoutedByPreferred,
+		},
+		{
+			name:      "last agent at capacity",
+			openCalls: map[string]string{"a2": "1"},
+			affinity:  "a2",
+			wantAgent: "a1", wantBy: models.RoutedByRoundRobin,
+		},
+		{
+			name:      "last agent is not available",
+			affinity:  "a9",
+			wantAgent: "a1", wantBy: models.RoutedByRoundRobin,
+		},
+		{
+			name:      "last agent left the queue",
+			members:   map[string]int{"a1": 1, "a3": 1},
+			affinity:  "a2",
+			wantAgent: "a1", wantBy: models.RoutedByRoundRobin,
+		},
+		{
+			name:      "no history",
+			wantAgent: "a1", wantBy: models.RoutedByRoundRobin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			client, _ := testutil.Redis(t)
+			s := NewDistributorService(nil, nil, client, db).(*distributorService)
+			s.SetDefaultCapacity(1)
+
+			client.RPush(ctx, "available_agents", "a1", "a2", "a3")
+			for agentID, count := range tt.openCalls {
+				client.HSet(ctx, "agent_open_calls", agentID, count)
+			}
+
+			rows := sqlmock.NewRows([]string{"id", "max_concurrent_calls", "state"})
+			for _, agentID := range []string{"a1", "a2", "a3"} {
+				if _, ok := tt.members[agentID]; ok || tt.members == nil {
+					rows.AddRow(agentID, 0, models.AgentStateAvailable)
+				}
+			}
+			mock.ExpectQuery(`SELECT "id","max_concurrent_calls","state" FROM "agents"`).WillReturnRows(rows)
+
+			agentID, routedBy := s.selectAgent(ctx, queue, tt.members, tt.members != nil, "c1", tt.preferred, tt.affinity)
+			if agentID != tt.wantAgent || routedBy != tt.wantBy {
+				t.Errorf("selectAgent() = %s by %s, want %s by %s", agentID, routedBy, tt.wantAgent, tt.wantBy)
+			}
+			if slot, _ := client.HGet(ctx, "call_slots", "c1").Result(); slot != tt.wantAgent {
+				t.Errorf("call holds %q's slot, want %q", slot, tt.wantAgent)
+			}
+		})
+	}
+}
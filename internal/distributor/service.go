@@ -1,31 +1,56 @@
 package distributor
 
 import (
+	"call-center-api/internal/routing"
 	"call-center-api/models"
 	"call-center-api/pkg/database"
+	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/tracing"
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+var tracer = tracing.Tracer("call-center-api/distributor")
+
 type DistributorService interface {
 	Start(ctx context.Context) error
 	SetAgentChangeConsumer(consumer *database.KafkaConsumer)
+	SetAffinityWindow(window time.Duration)
+	SetDefaultCapacity(capacity int)
+	SetCallEventConsumer(consumer *database.KafkaConsumer)
+	SetRoutingService(routing routing.RoutingService)
+	QueueStates() ([]models.QueueOpenState, error)
 	StartAgentChangeConsumer(ctx context.Context) error
+	StartDispatcher(ctx context.Context)
+	StartCapacityConsumer(ctx context.Context) error
+	StartReconciler(ctx context.Context, interval time.Duration)
 }
 
 type distributorService struct {
 	kafkaConsumer            *database.KafkaConsumer
 	agentChangeKafkaConsumer *database.KafkaConsumer
+	callEventConsumer        *database.KafkaConsumer
 	kafkaProducer            *database.KafkaProducer
 	redis                    *redis.Client
 	db                       *gorm.DB
+	affinityWindow           time.Duration
+	defaultCapacity          int
+	routing                  routing.RoutingService
+
+	// dispatchMu serializes queue dispatching between the consumer and the dispatcher loop
+	dispatchMu sync.Mutex
 }
 
 func NewDistributorService(
@@ -46,6 +71,37 @@ func (s *distributorService) SetAgentChangeConsumer(consumer *database.KafkaCons
 	s.agentChangeKafkaConsumer = consumer
 }
 
+// SetAffinityWindow enables sticky routing to the caller's last agent within window; zero disables it
+func (s *distributorService) SetAffinityWindow(window time.Duration) {
+	s.affinityWindow = window
+}
+
+// SetRoutingService enables business hours; without it queues are open around the clock
+func (s *distributorService) SetRoutingService(routing routing.RoutingService) {
+	s.routing = routing
+}
+
+// QueueStates reports the open/closed state and number of waiting calls of every queue
+func (s *distributorService) QueueStates() ([]models.QueueOpenState, error) {
+	queues, err := s.queues()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]models.QueueOpenState, 0, len(queues))
+	for _, queue := range queues {
+		state := models.QueueOpenState{Queue: queue.Name, Open: true}
+		if s.routing != nil {
+			if state, _, err = s.routing.QueueOpenState(queue.Name, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		state.Waiting, _ = s.redis.ZCard(context.Background(), "call_queue:"+queue.Name).Result()
+		states = append(states, state)
+	}
+	return states, nil
+}
+
 func (s *distributorService) Start(ctx context.Context) error {
 	return s.kafkaConsumer.ConsumeMessages(ctx, s.processIncomingCall)
 }
@@ -57,69 +113,241 @@ func (s *distributorService) StartAgentChangeConsumer(ctx context.Context) error
 	return s.consumeAgentChanges(ctx)
 }
 
-func (s *distributorService) processIncomingCall(call models.IncomingCall) error {
-	fmt.Printf("Processing incoming call: %s\n", call.CallID)
+func (s *distributorService) processIncomingCall(ctx context.Context, call models.IncomingCall) error {
+	slog.DebugContext(ctx, "Processing incoming call", "queue", call.Queue)
 
-	// Assign agent using round-robin
-	agentID := s.assignAgent()
-	if agentID == "" {
-		fmt.Printf("No available agent for call: %s\n", call.CallID)
-		return nil // Skip if no agents available
+	if call.Queue == "" {
+		call.Queue = models.DefaultQueue
+	}
+	waiting := newWaitingCall(ctx, call)
+
+	// Drop calls whose caller hung up while they were queued
+	if abandonedAt, ok := s.abandonedAt(call.CallID); ok {
+		s.leaveQueue(ctx, call)
+		return s.recordQueuedAbandon(ctx, waiting, abandonedAt)
 	}
 
-	// Create assigned call
-	assignedCall := models.AssignedCall{
-		CallID:          call.CallID,
-		CustomerNumber:  call.CustomerNumber,
-		Timestamp:       time.Now(),
-		AssignedAgentID: agentID,
-		Status:          "assigned",
+	// Apply the after-hours behaviour instead of assigning an agent while closed
+	if handled, err := s.handleAfterHours(ctx, waiting); handled {
+		s.leaveQueue(ctx, call)
+		return err
 	}
 
+	// Wait in the queue, then serve it right away in case an agent is free
+	if err := s.parkCall(ctx, waiting); err != nil {
+		return fmt.Errorf("failed to queue call %s: %w", call.CallID, err)
+	}
+	s.recordEvent(models.CallEvent{CallID: call.CallID, Type: models.CallEventQueued, Queue: call.Queue})
+
+	s.dispatchQueue(ctx, s.queueConfig(call.Queue))
+	s.applyOverflow(ctx, call.Queue)
+	return nil
+}
+
+// assignCall records and announces an assignment, then takes the call out of its queue. The
+// assignment joins the trace the call arrived with, however long it waited.
+func (s *distributorService) assignCall(ctx context.Context, call waitingCall, agentID, routedBy, affinityAgentID string) (err error) {
+	ctx, span := tracer.Start(tracing.Extract(ctx, call.Trace), "assign call", trace.WithAttributes(
+		attribute.String("call.id", call.CallID),
+		attribute.String("call.queue", call.Queue),
+		attribute.String("agent.id", agentID),
+		attribute.String("routed_by", routedBy),
+	))
+	defer func() { tracing.End(span, err) }()
+	ctx = logger.WithAgentID(logger.WithCallID(logger.WithCorrelationID(ctx, call.CorrelationID), call.CallID), agentID)
+
+	assignedCall := call.record()
+	assignedCall.Timestamp = time.Now()
+	assignedCall.AssignedAgentID = agentID
+	assignedCall.RoutedBy = routedBy
+	assignedCall.AffinityAgentID = affinityAgentID
+	assignedCall.Status = models.CallStatusAssigned
+
 	// Publish to assigned_calls topic
-	if err := s.kafkaProducer.PublishAssignedCall(context.Background(), assignedCall); err != nil {
+	if err := s.kafkaProducer.PublishAssignedCall(ctx, assignedCall); err != nil {
 		return err
 	}
+	metrics.AssignmentLatency.WithLabelValues(call.Queue).Observe(assignedCall.Timestamp.Sub(call.Timestamp).Seconds())
 
 	// Save to database
 	if err := s.db.Create(&assignedCall).Error; err != nil {
-		fmt.Printf("Error saving to database: %v\n", err)
+		slog.ErrorContext(ctx, "Failed to save assigned call", "error", err)
+	}
+	s.leaveQueue(ctx, call.IncomingCall)
+	s.recordEvent(models.CallEvent{CallID: call.CallID, Type: models.CallEventAssigned, Queue: call.Queue, AgentID: agentID, Detail: routedBy})
+
+	// The caller may have hung up while we were assigning; retract if so
+	if abandonedAt, ok := s.abandonedAt(call.CallID); ok {
+		return s.retractAbandonedCall(ctx, assignedCall, abandonedAt)
 	}
 
-	fmt.Printf("Call %s assigned to agent %s\n", call.CallID, agentID)
+	slog.InfoContext(ctx, "Call assigned", "queue", call.Queue, "routed_by", routedBy)
 	return nil
 }
 
-func (s *distributorService) assignAgent() string {
-	ctx := context.Background()
+// recordUnassignedCall stores a call the distributor did not assign so its status can be looked up
+func (s *distributorService) recordUnassignedCall(ctx context.Context, call waitingCall, status, reason string) error {
+	unassignedCall := call.record()
+	unassignedCall.Timestamp = time.Now()
+	unassignedCall.Status = status
+	unassignedCall.StatusReason = reason
 
-	// Get available agents
-	agents, err := s.redis.LRange(ctx, "available_agents", 0, -1).Result()
-	if err != nil || len(agents) == 0 {
-		return ""
+	if err := s.db.Create(&unassignedCall).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to save unassigned call", "call_id", call.CallID, "status", status, "error", err)
+	}
+	slog.InfoContext(ctx, "Call not assigned", "call_id", call.CallID, "queue", call.Queue, "status", status, "reason", reason)
+	s.recordEvent(models.CallEvent{CallID: call.CallID, Type: status, Queue: call.Queue, Detail: reason})
+	return nil
+}
+
+// handleAfterHours applies the queue's after-hours action when it is closed. Outbound calls
+// (callbacks) are not subject to business hours. Schedule lookup failures leave the queue open.
+func (s *distributorService) handleAfterHours(ctx context.Context, call waitingCall) (bool, error) {
+	if s.routing == nil || call.Direction == "outbound" {
+		return false, nil
 	}
-	fmt.Printf("Available agents: %v\n", agents)
 
-	// Round-robin: pop first agent from left, push to right (end)
-	agent, err := s.redis.LPop(ctx, "available_agents").Result()
+	state, hours, err := s.routing.QueueOpenState(call.Queue, time.Now())
 	if err != nil {
+		slog.ErrorContext(ctx, "Failed to evaluate business hours, treating queue as open", "queue", call.Queue, "error", err)
+		return false, nil
+	}
+	if state.Open {
+		return false, nil
+	}
+
+	reason := hours.AfterHoursReason
+	if reason == "" {
+		reason = "after hours"
+		if state.Holiday != "" {
+			reason = "closed for " + state.Holiday
+		}
+	}
+	slog.InfoContext(ctx, "Queue closed, applying after-hours action", "call_id", call.CallID, "queue", state.Queue, "action", hours.AfterHoursAction)
+
+	switch hours.AfterHoursAction {
+	case models.AfterHoursCallback:
+		windowStart := time.Now()
+		if state.NextOpen != nil {
+			windowStart = *state.NextOpen
+		}
+		callback := models.Callback{
+			CustomerNumber: call.CustomerNumber,
+			RawNumber:      call.RawNumber,
+			Queue:          call.Queue,
+			Reason:         reason,
+			WindowStart:    windowStart,
+			OriginCallID:   call.CallID,
+			Status:         models.CallbackScheduled,
+			NextAttemptAt:  &windowStart,
+		}
+		if err := s.db.Create(&callback).Error; err != nil {
+			return true, err
+		}
+		return true, s.recordUnassignedCall(ctx, call, models.CallStatusCallbackScheduled, reason)
+	case models.AfterHoursVoicemail:
+		return true, s.recordUnassignedCall(ctx, call, models.CallStatusVoicemail, reason)
+	default:
+		return true, s.recordUnassignedCall(ctx, call, models.CallStatusRejected, reason)
+	}
+}
+
+// abandonedAt returns when the caller hung up, if callcenter-api recorded an abandonment
+func (s *distributorService) abandonedAt(callID string) (time.Time, bool) {
+	value, err := s.redis.Get(context.Background(), "abandoned_call:"+callID).Result()
+	if err != nil {
+		return time.Time{}, false
+	}
+	abandonedAt, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return abandonedAt, true
+}
+
+// recordQueuedAbandon stores a call that was abandoned before any agent was assigned
+func (s *distributorService) recordQueuedAbandon(ctx context.Context, call waitingCall, abandonedAt time.Time) error {
+	abandonedCall := call.record()
+	abandonedCall.Timestamp = abandonedAt
+	abandonedCall.Status = models.CallStatusAbandoned
+	abandonedCall.AbandonedAt = &abandonedAt
+	abandonedCall.TimeToAbandon = int64(abandonedAt.Sub(call.Timestamp).Seconds())
+
+	if err := s.db.Create(&abandonedCall).Error; err != nil {
+		slog.ErrorContext(ctx, "Failed to save abandoned call", "call_id", call.CallID, "error", err)
+	}
+	s.recordEvent(models.CallEvent{CallID: call.CallID, Type: models.CallStatusAbandoned, Queue: call.Queue})
+
+	slog.InfoContext(ctx, "Call abandoned while queued, skipping assignment", "call_id", call.CallID, "queue", call.Queue)
+	return nil
+}
+
+// retractAbandonedCall marks an assigned call abandoned and tells the agent's socket to drop it
+func (s *distributorService) retractAbandonedCall(ctx context.Context, call models.AssignedCall, abandonedAt time.Time) error {
+	result := s.db.Model(&models.AssignedCall{}).
+		Where("call_id = ? AND status = ?", call.CallID, models.CallStatusAssigned).
+		Updates(map[string]interface{}{
+			"status":          models.CallStatusAbandoned,
+			"abandoned_at":    abandonedAt,
+			"time_to_abandon": int64(abandonedAt.Sub(call.ReceivedAt).Seconds()),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// callcenter-api already retracted it if it won the transition
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	s.recordEvent(models.CallEvent{CallID: call.CallID, Type: models.CallStatusAbandoned, Queue: call.Queue, AgentID: call.AssignedAgentID})
+
+	call.Status = models.CallStatusAbandoned
+	call.AbandonedAt = &abandonedAt
+	call.TimeToAbandon = int64(abandonedAt.Sub(call.ReceivedAt).Seconds())
+
+	slog.InfoContext(ctx, "Call abandoned during assignment, retracting from agent")
+	return s.kafkaProducer.PublishAssignedCall(ctx, call)
+}
+
+// takeAgent moves a specific available agent to the back of the rotation, as round-robin would
+func (s *distributorService) takeAgent(ctx context.Context, agentID string) bool {
+	if agentID == "" {
+		return false
+	}
+	removed, err := s.redis.LRem(ctx, "available_agents", 1, agentID).Result()
+	if err != nil || removed == 0 {
+		return false
+	}
+	s.redis.RPush(ctx, "available_agents", agentID)
+	return true
+}
+
+// lastAgentFor returns the agent who most recently served this caller within the affinity window
+func (s *distributorService) lastAgentFor(customerNumber string) string {
+	if s.affinityWindow <= 0 || customerNumber == "" {
 		return ""
 	}
-	s.redis.RPush(ctx, "available_agents", agent)
 
-	fmt.Printf("Assigned to agent: %s\n", agent)
-	return agent
+	var last models.AssignedCall
+	err := s.db.
+		Where("customer_number = ? AND assigned_agent_id <> '' AND timestamp >= ?", customerNumber, time.Now().Add(-s.affinityWindow)).
+		Order("timestamp DESC").
+		Limit(1).
+		Find(&last).Error
+	if err != nil {
+		return ""
+	}
+	return last.AssignedAgentID
 }
 
 // consumeAgentChanges listens to agent_changes topic and syncs Redis
 func (s *distributorService) consumeAgentChanges(ctx context.Context) error {
-	fmt.Println("Starting agent changes consumer...")
+	slog.Info("Starting agent changes consumer")
 
 	handler := &agentChangeHandler{
-		handler: s.agentChangeKafkaConsumer,
-		processAgentChange: func(key string, value []byte) error {
-			return s.handleAgentChange(ctx, key, value)
-		},
+		handler:            s.agentChangeKafkaConsumer,
+		processAgentChange: s.handleAgentChange,
 	}
 
 	// Keep consuming until context is canceled
@@ -127,10 +355,10 @@ func (s *distributorService) consumeAgentChanges(ctx context.Context) error {
 		topics := []string{"agent_changes"}
 		if err := s.agentChangeKafkaConsumer.ConsumeRawMessages(ctx, topics, handler); err != nil {
 			if ctx.Err() != nil {
-				fmt.Printf("Agent change consumer context canceled\n")
+				slog.Info("Agent change consumer context canceled")
 				return ctx.Err()
 			}
-			fmt.Printf("Agent change consumer error: %v\n", err)
+			slog.Error("Agent change consumer error", "error", err)
 			return err
 		}
 
@@ -142,12 +370,12 @@ func (s *distributorService) consumeAgentChanges(ctx context.Context) error {
 
 // handleAgentChange processes agent creation/deletion events
 func (s *distributorService) handleAgentChange(ctx context.Context, key string, value []byte) error {
-	fmt.Printf("Received agent change event - Key: %s\n", key)
+	slog.DebugContext(ctx, "Received agent change event", "key", key)
 
 	// Parse the key to determine action type
 	parts := strings.SplitN(key, ":", 2)
 	if len(parts) != 2 {
-		fmt.Printf("Invalid key format: %s\n", key)
+		slog.WarnContext(ctx, "Invalid agent change key", "key", key)
 		return nil
 	}
 
@@ -157,29 +385,41 @@ func (s *distributorService) handleAgentChange(ctx context.Context, key string,
 	// Unmarshal agent data
 	var agent models.Agent
 	if err := json.Unmarshal(value, &agent); err != nil {
-		fmt.Printf("Error unmarshaling agent data: %v\n", err)
+		slog.ErrorContext(ctx, "Failed to decode agent change", "key", key, "error", err)
 		return nil
 	}
+	ctx = logger.WithAgentID(ctx, agent.ID)
 
 	switch action {
-	case "create_agent":
-		return s.handleAgentCreation(ctx, agent)
+	case "create_agent", "session_change":
+		return s.syncAgentRouting(ctx, agent)
 	case "delete_agent":
 		return s.handleAgentDeletion(ctx, agent)
+	case "state_change":
+		// routable reads the agent's state from Postgres on every dispatch
 	default:
-		fmt.Printf("Unknown action: %s\n", action)
+		slog.WarnContext(ctx, "Unknown agent change action", "action", action)
 	}
 
 	return nil
 }
 
-// handleAgentCreation adds the new agent to Redis
-func (s *distributorService) handleAgentCreation(ctx context.Context, agent models.Agent) error {
-	fmt.Printf("Adding agent %s (%s) to Redis\n", agent.ID, agent.Name)
+// syncAgentRouting puts an agent in the rotation while they are active and signed in,
+// and takes them out otherwise. New agents join once they first log in.
+func (s *distributorService) syncAgentRouting(ctx context.Context, agent models.Agent) error {
+	var routable int64
+	if err := s.db.Model(&models.Agent{}).
+		Where("id = ? AND is_active = ?", agent.ID, true).
+		Scopes(models.LiveSession).
+		Count(&routable).Error; err != nil {
+		return fmt.Errorf("failed to check agent session: %w", err)
+	}
 
-	// Check if agent is active
-	if !agent.IsActive {
-		fmt.Printf("Agent %s is not active, skipping\n", agent.ID)
+	if routable == 0 {
+		if err := s.redis.LRem(ctx, "available_agents", 0, agent.ID).Err(); err != nil {
+			return fmt.Errorf("failed to remove agent from Redis: %w", err)
+		}
+		slog.InfoContext(ctx, "Agent has no live session, removed from rotation")
 		return nil
 	}
 
@@ -191,7 +431,7 @@ func (s *distributorService) handleAgentCreation(ctx context.Context, agent mode
 
 	for _, existingAgent := range agents {
 		if existingAgent == agent.ID {
-			fmt.Printf("Agent %s already exists in Redis\n", agent.ID)
+			slog.DebugContext(ctx, "Agent already in rotation")
 			return nil
 		}
 	}
@@ -201,18 +441,13 @@ func (s *distributorService) handleAgentCreation(ctx context.Context, agent mode
 		return fmt.Errorf("failed to add agent to Redis: %w", err)
 	}
 
-	fmt.Printf("✓ Successfully added agent %s to Redis\n", agent.ID)
-
-	// Log current state
-	updatedAgents, _ := s.redis.LRange(ctx, "available_agents", 0, -1).Result()
-	fmt.Printf("Current available agents in Redis: %v\n", updatedAgents)
-
+	slog.InfoContext(ctx, "Agent added to rotation")
 	return nil
 }
 
 // handleAgentDeletion removes the agent from Redis
 func (s *distributorService) handleAgentDeletion(ctx context.Context, agent models.Agent) error {
-	fmt.Printf("Removing agent %s (%s) from Redis\n", agent.ID, agent.Name)
+	slog.InfoContext(ctx, "Removing agent from rotation", "agent_name", agent.Name)
 
 	// Remove agent from Redis list
 	removed, err := s.redis.LRem(ctx, "available_agents", 0, agent.ID).Result()
@@ -221,14 +456,14 @@ func (s *distributorService) handleAgentDeletion(ctx context.Context, agent mode
 	}
 
 	if removed > 0 {
-		fmt.Printf("✓ Successfully removed agent %s from Redis (removed %d occurrences)\n", agent.ID, removed)
+		slog.InfoContext(ctx, "Agent removed from rotation", "occurrences", removed)
 	} else {
-		fmt.Printf("Agent %s was not found in Redis\n", agent.ID)
+		slog.InfoContext(ctx, "Agent was not in rotation")
 	}
 
 	// Log current state
 	updatedAgents, _ := s.redis.LRange(ctx, "available_agents", 0, -1).Result()
-	fmt.Printf("Current available agents in Redis: %v\n", updatedAgents)
+	slog.DebugContext(ctx, "Current available agents", "agent_ids", updatedAgents)
 
 	return nil
 }
@@ -236,34 +471,32 @@ func (s *distributorService) handleAgentDeletion(ctx context.Context, agent mode
 // agentChangeHandler implements sarama.ConsumerGroupHandler for raw agent change messages
 type agentChangeHandler struct {
 	handler            *database.KafkaConsumer
-	processAgentChange func(key string, value []byte) error
+	processAgentChange func(ctx context.Context, key string, value []byte) error
 }
 
 func (h *agentChangeHandler) Setup(session sarama.ConsumerGroupSession) error {
-	fmt.Printf("Agent change consumer group handler setup - MemberID: %s, GenerationID: %d\n", session.MemberID(), session.GenerationID())
+	slog.Info("Agent change consumer group handler setup", "member_id", session.MemberID(), "generation_id", session.GenerationID())
 	return nil
 }
 
 func (h *agentChangeHandler) Cleanup(sarama.ConsumerGroupSession) error {
-	fmt.Printf("Agent change consumer group handler cleanup\n")
+	slog.Info("Agent change consumer group handler cleanup")
 	return nil
 }
 
 func (h *agentChangeHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	fmt.Printf("Starting ConsumeClaim for agent changes topic %s partition %d at offset %d\n", claim.Topic(), claim.Partition(), claim.InitialOffset())
+	slog.Debug("Starting ConsumeClaim", "topic", claim.Topic(), "partition", claim.Partition(), "offset", claim.InitialOffset())
 
 	for message := range claim.Messages() {
-		fmt.Printf("Received agent change message from topic %s partition %d offset %d, key: %s\n",
-			message.Topic, message.Partition, message.Offset, string(message.Key))
-
+		ctx := database.MessageContext(message)
 		if h.processAgentChange != nil {
-			if err := h.processAgentChange(string(message.Key), message.Value); err != nil {
-				fmt.Printf("Error processing agent change: %v\n", err)
+			if err := h.processAgentChange(ctx, string(message.Key), message.Value); err != nil {
+				slog.ErrorContext(ctx, "Failed to process agent change", "key", string(message.Key), "error", err)
 			}
 		}
 		session.MarkMessage(message, "")
 	}
 
-	fmt.Printf("ConsumeClaim loop exited for topic %s partition %d\n", claim.Topic(), claim.Partition())
+	slog.Debug("ConsumeClaim loop exited", "topic", claim.Topic(), "partition", claim.Partition())
 	return nil
 }
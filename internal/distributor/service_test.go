@@ -0,0 +1,78 @@
+package distributor
+
+import (
+	"call-center-api/pkg/testutil"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLastAgentFor(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		db, _ := testutil.MockDB(t)
+		s := NewDistributorService(nil, nil, nil, db).(*distributorService)
+		s.SetAffinityWindow(0)
+		// No query is expected; MockDB fails the test on unexpected ones
+		if got := s.lastAgentFor("+16502530000"); got != "" {
+			t.Errorf("lastAgentFor() = %q with affinity disabled", got)
+		}
+	})
+
+	t.Run("most recent agent in the window", func(t *testing.T) {
+		db, mock := testutil.MockDB(t)
+		s := NewDistributorService(nil, nil, nil, db).(*distributorService)
+		s.SetAffinityWindow(24 * time.Hour)
+
+		mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE \(customer_number = \$1 AND assigned_agent_id <> '' AND timestamp >= \$2\) .*ORDER BY timestamp DESC LIMIT 1`).
+			WithArgs("+16502530000", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"call_id", "assigned_agent_id"}).AddRow("old", "a2"))
+		if got := s.lastAgentFor("+16502530000"); got != "a2" {
+			t.Errorf("lastAgentFor() = %q, want a2", got)
+		}
+	})
+}
+
+func TestSyncAgentRouting(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		available []string
+		live      bool
+		want      []string
+	}{
+		{name: "signs in", available: []string{"a2"}, live: true, want: []string{"a2", "a1"}},
+		{name: "already in the rotation", available: []string{"a1", "a2"}, live: true, want: []string{"a1", "a2"}},
+		{name: "last session ends", available: []string{"a1", "a2"}, live: false, want: []string{"a2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			client, _ := testutil.Redis(t)
+			s := NewDistributorService(nil, nil, client, db).(*distributorService)
+			if len(tt.available) > 0 {
+				client.RPush(ctx, "available_agents", tt.available)
+			}
+
+			live := 0
+			if tt.live {
+				live = 1
+			}
+			mock.ExpectQuery(`SELECT count\(\*\) FROM "agents" WHERE \(id = \$1 AND is_active = \$2\) AND EXISTS \(SELECT 1 FROM agent_sessions`).
+				WithArgs("a1", true, sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(live))
+
+			if err := s.handleAgentChange(ctx, "session_change:a1", []byte(`{"id":"a1"}`)); err != nil {
+				t.Fatalf("handleAgentChange() error = %v", err)
+			}
+			got, _ := client.LRange(ctx, "available_agents", 0, -1).Result()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("available_agents = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package reporting
+
+import "math"
+
+// maxAgents bounds the Erlang C search so absurd inputs cannot spin forever
+const maxAgents = 10000
+
+// erlangC is the probability that a call has to wait with agents agents and traffic erlangs
+// of offered load. It uses the Erlang B recursion, which stays stable for large agent counts.
+func erlangC(agents int, traffic float64) float64 {
+	if float64(agents) <= traffic {
+		return 1
+	}
+	b := 1.0
+	for n := 1; n <= agents; n++ {
+		b = traffic * b / (float64(n) + traffic*b)
+	}
+	return float64(agents) * b / (float64(agents) - traffic*(1-b))
+}
+
+// serviceLevel is the share of calls answered within target seconds
+func serviceLevel(agents int, traffic, aht, target float64) float64 {
+	if float64(agents) <= traffic {
+		return 0
+	}
+	return 1 - erlangC(agents, traffic)*math.Exp(-(float64(agents)-traffic)*target/aht)
+}
+
+// averageSpeedOfAnswer is the expected wait across all calls, in seconds
+func averageSpeedOfAnswer(agents int, traffic, aht float64) float64 {
+	if float64(agents) <= traffic {
+		return math.Inf(1)
+	}
+	return erlangC(agents, traffic) * aht / (float64(agents) - traffic)
+}
+
+// requiredAgents finds the fewest agents meeting the service level and, when set, the ASA
+// target. It returns the staffing with its expected service level and ASA.
+func requiredAgents(traffic, aht, targetLevel, targetSeconds, targetASA float64) (int, float64, float64) {
+	if traffic <= 0 || aht <= 0 {
+		return 0, 1, 0
+	}
+
+	for agents := int(math.Floor(traffic)) + 1; agents <= maxAgents; agents++ {
+		level := serviceLevel(agents, traffic, aht, targetSeconds)
+		asa := averageSpeedOfAnswer(agents, traffic, aht)
+		if level >= targetLevel && (targetASA <= 0 || asa <= targetASA) {
+			return agents, level, asa
+		}
+	}
+	return maxAgents, serviceLevel(maxAgents, traffic, aht, targetSeconds), averageSpeedOfAnswer(maxAgents, traffic, aht)
+}
@@ -0,0 +1,83 @@
+package reporting
+
+import (
+	"math"
+	"testing"
+)
+
+func TestErlangC(t *testing.T) {
+	tests := []struct {
+		agents  int
+		traffic float64
+		want    float64
+	}{
+		{agents: 1, traffic: 0.5, want: 0.5},
+		{agents: 2, traffic: 1, want: 1.0 / 3},
+		{agents: 11, traffic: 10, want: 0.682118},
+		{agents: 12, traffic: 10, want: 0.449388},
+		{agents: 14, traffic: 10, want: 0.174132},
+		{agents: 10, traffic: 10, want: 1},
+		{agents: 5, traffic: 10, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := erlangC(tt.agents, tt.traffic); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("erlangC(%d, %v) = %v, want %v", tt.agents, tt.traffic, got, tt.want)
+		}
+	}
+}
+
+func TestRequiredAgents(t *testing.T) {
+	tests := []struct {
+		name       string
+		traffic    float64
+		aht        float64
+		level      float64
+		seconds    float64
+		asa        float64
+		wantAgents int
+		wantLevel  float64
+		wantASA    float64
+		wantInfASA bool
+	}{
+		{
+			name:    "no traffic",
+			traffic: 0, aht: 180, level: 0.8, seconds: 20,
+			wantAgents: 0, wantLevel: 1, wantASA: 0,
+		},
+		{
+			name:    "service level target",
+			traffic: 10, aht: 180, level: 0.8, seconds: 20,
+			wantAgents: 14, wantLevel: 0.888350, wantASA: 7.835937,
+		},
+		{
+			name:    "asa target needs more agents",
+			traffic: 10, aht: 180, level: 0.8, seconds: 20, asa: 5,
+			wantAgents: 15, wantLevel: 0.941453, wantASA: 3.673525,
+		},
+		{
+			name:    "load beyond the search",
+			traffic: maxAgents + 1, aht: 180, level: 0.8, seconds: 20,
+			wantAgents: maxAgents, wantLevel: 0, wantInfASA: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agents, level, asa := requiredAgents(tt.traffic, tt.aht, tt.level, tt.seconds, tt.asa)
+			if agents != tt.wantAgents {
+				t.Errorf("agents = %d, want %d", agents, tt.wantAgents)
+			}
+			if math.Abs(level-tt.wantLevel) > 1e-6 {
+				t.Errorf("service level = %v, want %v", level, tt.wantLevel)
+			}
+			if tt.wantInfASA {
+				if !math.IsInf(asa, 1) {
+					t.Errorf("asa = %v, want +Inf", asa)
+				}
+			} else if math.Abs(asa-tt.wantASA) > 1e-6 {
+				t.Errorf("asa = %v, want %v", asa, tt.wantASA)
+			}
+		})
+	}
+}
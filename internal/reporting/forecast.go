@@ -0,0 +1,193 @@
+package reporting
+
+import (
+	"call-center-api/models"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// week is the seasonal period forecasts repeat over
+	week = 7 * 24 * time.Hour
+	// maxForecastWeeks bounds how much history a moving average reads
+	maxForecastWeeks = 52
+)
+
+// Forecast predicts each 15-minute interval of params.Date from the interval rollups of past
+// weeks and sizes staffing for it with Erlang C. Queue targets fill in unset service level
+// and target seconds.
+func (s *reportingService) Forecast(params models.ForecastParams) (*models.Forecast, error) {
+	if err := s.forecastDefaults(&params); err != nil {
+		return nil, err
+	}
+
+	day := time.Date(params.Date.Year(), params.Date.Month(), params.Date.Day(), 0, 0, 0, 0, time.UTC)
+	historyFrom := day.Add(-time.Duration(params.Weeks) * week)
+
+	query := s.db.Model(&models.IntervalStat{}).
+		Select("interval_start, SUM(offered) AS offered, SUM(handled) AS handled, SUM(handle_seconds) AS handle_seconds").
+		Where("interval_start >= ? AND interval_start < ?", historyFrom, day).
+		Group("interval_start")
+	if params.Queue != "" {
+		query = query.Where("queue = ?", params.Queue)
+	}
+
+	var history []struct {
+		IntervalStart time.Time
+		Offered       float64
+		Handled       float64
+		HandleSeconds float64
+	}
+	if err := query.Scan(&history).Error; err != nil {
+		return nil, err
+	}
+
+	slots := make(map[int64]forecastSlot, len(history))
+	var handled, handleSeconds float64
+	for _, h := range history {
+		slots[h.IntervalStart.Unix()] = forecastSlot{h.Offered, h.Handled, h.HandleSeconds}
+		handled += h.Handled
+		handleSeconds += h.HandleSeconds
+	}
+	// Intervals without completed calls in their own history fall back to the overall AHT
+	overallAHT := 0.0
+	if handled > 0 {
+		overallAHT = handleSeconds / handled
+	}
+
+	weeks := params.Weeks
+	if params.Method == models.ForecastSeasonalNaive {
+		weeks = 1
+	}
+	used := coveredWeeks(day, slots, weeks)
+
+	forecast := &models.Forecast{
+		Queue:              params.Queue,
+		Date:               day.Format("2006-01-02"),
+		Method:             params.Method,
+		Weeks:              len(used),
+		TargetServiceLevel: params.ServiceLevel,
+		TargetSeconds:      params.TargetSeconds,
+		TargetASASeconds:   params.TargetASASeconds,
+		Shrinkage:          params.Shrinkage,
+	}
+
+	for start := day; start.Before(day.Add(24 * time.Hour)); start = start.Add(intervalLength) {
+		past := averageSlot(slots, start, used)
+
+		interval := models.ForecastInterval{
+			IntervalStart: start,
+			ForecastCalls: past.offered,
+			AHTSeconds:    overallAHT,
+		}
+		if past.handled > 0 {
+			interval.AHTSeconds = past.handleSeconds / past.handled
+		}
+		interval.TrafficErlangs = interval.ForecastCalls * interval.AHTSeconds / intervalLength.Seconds()
+
+		agents, level, asa := requiredAgents(interval.TrafficErlangs, interval.AHTSeconds,
+			params.ServiceLevel, float64(params.TargetSeconds), params.TargetASASeconds)
+		interval.RequiredAgents = agents
+		interval.ScheduledAgents = int(math.Ceil(float64(agents) / (1 - params.Shrinkage)))
+		interval.ServiceLevel = level
+		// Load that even maxAgents cannot carry has no finite ASA, which JSON cannot encode
+		if !math.IsInf(asa, 1) {
+			interval.ASASeconds = &asa
+		}
+
+		forecast.TotalCalls += interval.ForecastCalls
+		if interval.ScheduledAgents > forecast.PeakAgents {
+			forecast.PeakAgents = interval.ScheduledAgents
+		}
+		forecast.Intervals = append(forecast.Intervals, interval)
+	}
+
+	return forecast, nil
+}
+
+// forecastSlot is the rolled up load of one interval
+type forecastSlot struct{ offered, handled, handleSeconds float64 }
+
+// coveredWeeks lists the past weeks up to weeks, 1 being the week before day, that have any
+// rollups. Quiet intervals in a covered week count as zero calls, while weeks before the
+// history starts do not dilute the average.
+func coveredWeeks(day time.Time, slots map[int64]forecastSlot, weeks int) []int {
+	covered := make(map[int]bool, weeks)
+	for unix := range slots {
+		covered[int((day.Sub(time.Unix(unix, 0))-1)/week)+1] = true
+	}
+
+	var used []int
+	for w := 1; w <= weeks; w++ {
+		if covered[w] {
+			used = append(used, w)
+		}
+	}
+	return used
+}
+
+// averageSlot averages the interval starting at start over the used past weeks
+func averageSlot(slots map[int64]forecastSlot, start time.Time, used []int) forecastSlot {
+	var sum forecastSlot
+	if len(used) == 0 {
+		return sum
+	}
+	for _, w := range used {
+		past := slots[start.Add(-time.Duration(w)*week).Unix()]
+		sum.offered += past.offered
+		sum.handled += past.handled
+		sum.handleSeconds += past.handleSeconds
+	}
+	n := float64(len(used))
+	return forecastSlot{sum.offered / n, sum.handled / n, sum.handleSeconds / n}
+}
+
+// forecastDefaults validates params and fills gaps from the queue's SLA settings
+func (s *reportingService) forecastDefaults(params *models.ForecastParams) error {
+	switch params.Method {
+	case "":
+		params.Method = models.ForecastMovingAverage
+	case models.ForecastSeasonalNaive, models.ForecastMovingAverage:
+	default:
+		return fmt.Errorf("method must be %s or %s", models.ForecastSeasonalNaive, models.ForecastMovingAverage)
+	}
+	if params.Weeks == 0 {
+		params.Weeks = 4
+	}
+	if params.Weeks < 1 || params.Weeks > maxForecastWeeks {
+		return fmt.Errorf("weeks must be between 1 and %d", maxForecastWeeks)
+	}
+	if params.Shrinkage < 0 || params.Shrinkage >= 1 {
+		return errors.New("shrinkage must be at least 0 and below 1")
+	}
+	if params.TargetASASeconds < 0 {
+		return errors.New("target_asa_seconds must not be negative")
+	}
+
+	if params.Queue != "" && (params.ServiceLevel == 0 || params.TargetSeconds == 0) {
+		var queue models.Queue
+		if err := s.db.Where("name = ?", params.Queue).First(&queue).Error; err == nil {
+			if params.ServiceLevel == 0 {
+				params.ServiceLevel = queue.SLATargetPercent / 100
+			}
+			if params.TargetSeconds == 0 {
+				params.TargetSeconds = queue.SLATargetSeconds
+			}
+		}
+	}
+	if params.ServiceLevel == 0 {
+		params.ServiceLevel = 0.8
+	}
+	if params.TargetSeconds == 0 {
+		params.TargetSeconds = defaultSLATarget
+	}
+	if params.ServiceLevel <= 0 || params.ServiceLevel >= 1 {
+		return errors.New("service_level must be between 0 and 1")
+	}
+	if params.TargetSeconds < 0 {
+		return errors.New("target_seconds must not be negative")
+	}
+	return nil
+}
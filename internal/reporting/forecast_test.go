@@ -0,0 +1,100 @@
+package reporting
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestForecastAverage(t *testing.T) {
+	day := time.Date(2030, 1, 14, 0, 0, 0, 0, time.UTC)
+	nine := day.Add(9 * time.Hour)
+	pastWeek := func(w int, at time.Time) int64 {
+		return at.Add(-time.Duration(w) * week).Unix()
+	}
+
+	tests := []struct {
+		name  string
+		slots map[int64]forecastSlot
+		weeks int
+		used  []int
+		want  forecastSlot
+	}{
+		{
+			name:  "no history",
+			slots: map[int64]forecastSlot{},
+			weeks: 4,
+			used:  nil,
+			want:  forecastSlot{},
+		},
+		{
+			name: "full history",
+			slots: map[int64]forecastSlot{
+				pastWeek(1, nine): {offered: 10, handled: 8, handleSeconds: 800},
+				pastWeek(2, nine): {offered: 20, handled: 16, handleSeconds: 1600},
+				pastWeek(3, nine): {offered: 30, handled: 24, handleSeconds: 2400},
+				pastWeek(4, nine): {offered: 40, handled: 32, handleSeconds: 3200},
+			},
+			weeks: 4,
+			used:  []int{1, 2, 3, 4},
+			want:  forecastSlot{offered: 25, handled: 20, handleSeconds: 2000},
+		},
+		{
+			name: "weeks before history starts are ignored",
+			slots: map[int64]forecastSlot{
+				pastWeek(1, nine): {offered: 10},
+				pastWeek(2, nine): {offered: 20},
+			},
+			weeks: 4,
+			used:  []int{1, 2},
+			want:  forecastSlot{offered: 15},
+		},
+		{
+			name: "quiet interval in a covered week counts as zero",
+			slots: map[int64]forecastSlot{
+				pastWeek(1, nine):                  {offered: 12},
+				pastWeek(2, day.Add(15*time.Hour)): {offered: 3},
+			},
+			weeks: 4,
+			used:  []int{1, 2},
+			want:  forecastSlot{offered: 6},
+		},
+		{
+			name: "interval at the start of a week belongs to that week",
+			slots: map[int64]forecastSlot{
+				pastWeek(1, day):  {offered: 4},
+				pastWeek(3, nine): {offered: 8},
+			},
+			weeks: 4,
+			used:  []int{1, 3},
+			want:  forecastSlot{offered: 4},
+		},
+		{
+			name: "seasonal naive reads one week",
+			slots: map[int64]forecastSlot{
+				pastWeek(1, nine): {offered: 10},
+				pastWeek(2, nine): {offered: 30},
+			},
+			weeks: 1,
+			used:  []int{1},
+			want:  forecastSlot{offered: 10},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := coveredWeeks(day, tt.slots, tt.weeks)
+			if !reflect.DeepEqual(used, tt.used) {
+				t.Fatalf("coveredWeeks() = %v, want %v", used, tt.used)
+			}
+
+			got := averageSlot(tt.slots, nine, used)
+			if math.Abs(got.offered-tt.want.offered) > 1e-9 ||
+				math.Abs(got.handled-tt.want.handled) > 1e-9 ||
+				math.Abs(got.handleSeconds-tt.want.handleSeconds) > 1e-9 {
+				t.Errorf("averageSlot() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
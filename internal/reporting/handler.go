@@ -0,0 +1,192 @@
+package reporting
+
+import (
+	"call-center-api/models"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReportingHandler struct {
+	service ReportingService
+}
+
+func NewReportingHandler(service ReportingService) *ReportingHandler {
+	return &ReportingHandler{service: service}
+}
+
+// GetIntervalReport serves rollups as JSON, or as a CSV download with format=csv; defaults to the last 24 hours
+func (h *ReportingHandler) GetIntervalReport(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "csv" {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "format must be json or csv",
+		})
+	}
+
+	rows, err := h.service.GetIntervalReport(models.IntervalReportFilter{
+		From:        from,
+		To:          to,
+		Granularity: c.Query("granularity"),
+		Queue:       c.Query("queue"),
+		AgentID:     c.Query("agent_id"),
+		ByAgent:     c.Query("group_by") == "agent",
+	})
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to build interval report",
+			Error:   err.Error(),
+		})
+	}
+
+	if format == "csv" {
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="interval-report-%s.csv"`, from.UTC().Format("20060102")))
+		return writeCSV(c, rows)
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    rows,
+	})
+}
+
+// Backfill rebuilds rollups for a past period
+func (h *ReportingHandler) Backfill(c *fiber.Ctx) error {
+	var req models.BackfillRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	result, err := h.service.Backfill(req.From, req.To)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to backfill interval stats",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Interval stats backfilled successfully",
+		Data:    result,
+	})
+}
+
+// GetForecast forecasts a day's intervals and staffing; defaults to tomorrow (UTC)
+func (h *ReportingHandler) GetForecast(c *fiber.Ctx) error {
+	params := models.ForecastParams{
+		Queue:  c.Query("queue"),
+		Date:   time.Now().UTC().AddDate(0, 0, 1),
+		Method: c.Query("method"),
+	}
+
+	if value := c.Query("date"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid date, expected YYYY-MM-DD",
+			})
+		}
+		params.Date = parsed
+	}
+
+	var err error
+	if params.Weeks, err = strconv.Atoi(c.Query("weeks", "0")); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "weeks must be a number",
+		})
+	}
+	if params.TargetSeconds, err = strconv.Atoi(c.Query("target_seconds", "0")); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "target_seconds must be a number",
+		})
+	}
+	for name, target := range map[string]*float64{
+		"service_level":      &params.ServiceLevel,
+		"target_asa_seconds": &params.TargetASASeconds,
+		"shrinkage":          &params.Shrinkage,
+	} {
+		if *target, err = strconv.ParseFloat(c.Query(name, "0"), 64); err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: name + " must be a number",
+			})
+		}
+	}
+
+	forecast, err := h.service.Forecast(params)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to build forecast",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    forecast,
+	})
+}
+
+func writeCSV(c *fiber.Ctx, rows []models.IntervalReportRow) error {
+	w := csv.NewWriter(c)
+	w.Write([]string{"period_start", "queue", "agent_id", "offered", "answered", "abandoned",
+		"answered_in_target", "aht_seconds", "asa_seconds", "service_level"})
+
+	for _, row := range rows {
+		w.Write([]string{
+			row.PeriodStart.UTC().Format(time.RFC3339),
+			row.Queue,
+			row.AgentID,
+			strconv.FormatInt(row.Offered, 10),
+			strconv.FormatInt(row.Answered, 10),
+			strconv.FormatInt(row.Abandoned, 10),
+			strconv.FormatInt(row.AnsweredInTarget, 10),
+			strconv.FormatFloat(row.AHTSeconds, 'f', 1, 64),
+			strconv.FormatFloat(row.ASASeconds, 'f', 1, 64),
+			strconv.FormatFloat(row.ServiceLevel, 'f', 4, 64),
+		})
+	}
+
+	w.Flush()
+	return w.Error()
+}
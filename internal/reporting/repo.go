@@ -0,0 +1,6 @@
+package reporting
+
+// Repository interface for reporting
+type Repository interface {
+	// Add any database operations here if needed in future
+}
@@ -0,0 +1,298 @@
+package reporting
+
+import (
+	"call-center-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// intervalLength is the rollup granularity; longer periods are summed from it
+	intervalLength = 15 * time.Minute
+	// backfillChunk bounds how much history one rollup transaction rebuilds
+	backfillChunk = 24 * time.Hour
+	// maxBackfillRange bounds one backfill request, which runs synchronously
+	maxBackfillRange = 31 * 24 * time.Hour
+	// rollupLockKey is the Postgres advisory lock that keeps replicas from rolling up at once
+	rollupLockKey = 7208150
+	// defaultSLATarget matches the queues table default for calls in queues without a row
+	defaultSLATarget = 20
+)
+
+type ReportingService interface {
+	RollUp(from, to time.Time) (int64, error)
+	Backfill(from, to time.Time) (*models.BackfillResult, error)
+	GetIntervalReport(filter models.IntervalReportFilter) ([]models.IntervalReportRow, error)
+	Forecast(params models.ForecastParams) (*models.Forecast, error)
+	StartRollup(ctx context.Context, interval, lookback time.Duration)
+}
+
+type reportingService struct {
+	db *gorm.DB
+}
+
+func NewReportingService(db *gorm.DB) ReportingService {
+	return &reportingService{db: db}
+}
+
+// rollupQuery aggregates assigned_calls into 15-minute intervals by arrival time. Answered
+// calls reached an agent and were not abandoned or missed; handle time only counts calls
+// the agent finished.
+const rollupQuery = `
+INSERT INTO interval_stats (interval_start, queue, agent_id, offered, answered, abandoned,
+	answered_in_target, handled, handle_seconds, answer_wait_seconds, updated_at)
+SELECT to_timestamp(floor(EXTRACT(EPOCH FROM ac.received_at) / @interval) * @interval) AS interval_start,
+	COALESCE(NULLIF(ac.queue, ''), @default_queue) AS queue,
+	ac.assigned_agent_id AS agent_id,
+	COUNT(*) FILTER (WHERE ac.status NOT IN @not_offered),
+	COUNT(*) FILTER (WHERE ac.assigned_agent_id <> '' AND ac.status NOT IN @unanswered),
+	COUNT(*) FILTER (WHERE ac.status = @abandoned),
+	COUNT(*) FILTER (WHERE ac.assigned_agent_id <> '' AND ac.status NOT IN @unanswered
+		AND EXTRACT(EPOCH FROM ac.created_at - ac.received_at) <= COALESCE(q.sla_target_seconds, @default_target)),
+	COUNT(*) FILTER (WHERE ac.assigned_agent_id <> '' AND ac.status NOT IN @unhandled),
+	COALESCE(SUM(EXTRACT(EPOCH FROM ac.timestamp - ac.created_at))
+		FILTER (WHERE ac.assigned_agent_id <> '' AND ac.status NOT IN @unhandled), 0),
+	COALESCE(SUM(EXTRACT(EPOCH FROM ac.created_at - ac.received_at))
+		FILTER (WHERE ac.assigned_agent_id <> '' AND ac.status NOT IN @unanswered), 0),
+	NOW()
+FROM assigned_calls AS ac
+LEFT JOIN queues AS q ON q.name = ac.queue
+WHERE ac.deleted_at IS NULL AND ac.received_at >= @from AND ac.received_at < @to
+GROUP BY 1, 2, 3
+ON CONFLICT (interval_start, queue, agent_id) DO UPDATE SET
+	offered = EXCLUDED.offered,
+	answered = EXCLUDED.answered,
+	abandoned = EXCLUDED.abandoned,
+	answered_in_target = EXCLUDED.answered_in_target,
+	handled = EXCLUDED.handled,
+	handle_seconds = EXCLUDED.handle_seconds,
+	answer_wait_seconds = EXCLUDED.answer_wait_seconds,
+	updated_at = EXCLUDED.updated_at`
+
+// RollUp rebuilds the intervals overlapping [from, to), widened to interval boundaries, and
+// returns the number of rollup rows written. Rebuilding is idempotent, so calls that finish
+// after their interval was first rolled up are picked up on the next run.
+func (s *reportingService) RollUp(from, to time.Time) (int64, error) {
+	from, to = alignDown(from), alignUp(to)
+
+	var rows int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rows, err = rollUp(tx, from, to)
+		return err
+	})
+
+	return rows, err
+}
+
+// rollUp rebuilds the aligned intervals in [from, to) inside tx
+func rollUp(tx *gorm.DB, from, to time.Time) (int64, error) {
+	// Clear first so groups that no longer have calls (e.g. reassigned) disappear
+	if err := tx.Where("interval_start >= ? AND interval_start < ?", from, to).
+		Delete(&models.IntervalStat{}).Error; err != nil {
+		return 0, err
+	}
+
+	result := tx.Exec(rollupQuery, map[string]interface{}{
+		"from":           from,
+		"to":             to,
+		"interval":       intervalLength.Seconds(),
+		"default_queue":  models.DefaultQueue,
+		"default_target": defaultSLATarget,
+		"abandoned":      models.CallStatusAbandoned,
+		"not_offered": []string{models.CallStatusBlocked, models.CallStatusRejected,
+			models.CallStatusVoicemail, models.CallStatusCallbackScheduled},
+		"unanswered": []string{models.CallStatusAbandoned, models.CallStatusMissed},
+		"unhandled":  []string{models.CallStatusAssigned, models.CallStatusAbandoned, models.CallStatusMissed},
+	})
+	return result.RowsAffected, result.Error
+}
+
+// Backfill rolls up a past period of at most maxBackfillRange one day at a time
+func (s *reportingService) Backfill(from, to time.Time) (*models.BackfillResult, error) {
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if to.Sub(from) > maxBackfillRange {
+		return nil, fmt.Errorf("backfill range must not exceed %d days", int(maxBackfillRange/(24*time.Hour)))
+	}
+
+	result := &models.BackfillResult{From: alignDown(from), To: alignUp(to)}
+	result.Intervals = int(result.To.Sub(result.From) / intervalLength)
+
+	for start := result.From; start.Before(result.To); start = start.Add(backfillChunk) {
+		end := start.Add(backfillChunk)
+		if end.After(result.To) {
+			end = result.To
+		}
+		rows, err := s.RollUp(start, end)
+		if err != nil {
+			return nil, fmt.Errorf("failed to roll up %s: %w", start.Format(time.RFC3339), err)
+		}
+		result.Rows += rows
+	}
+
+	return result, nil
+}
+
+// GetIntervalReport sums rollups into 15-minute or daily (UTC) periods
+func (s *reportingService) GetIntervalReport(filter models.IntervalReportFilter) ([]models.IntervalReportRow, error) {
+	period := "interval_start"
+	switch filter.Granularity {
+	case "", models.GranularityInterval:
+	case models.GranularityDay:
+		period = "date_trunc('day', interval_start, 'UTC')"
+	default:
+		return nil, fmt.Errorf("granularity must be %s or %s", models.GranularityInterval, models.GranularityDay)
+	}
+
+	groups := "period_start, queue"
+	if filter.ByAgent || filter.AgentID != "" {
+		groups += ", agent_id"
+	}
+
+	query := s.db.Model(&models.IntervalStat{}).
+		Select(period+" AS period_start, queue, "+agentColumn(filter)+` AS agent_id,
+			SUM(offered) AS offered, SUM(answered) AS answered, SUM(abandoned) AS abandoned,
+			SUM(answered_in_target) AS answered_in_target, SUM(handled) AS handled,
+			SUM(handle_seconds) AS handle_seconds, SUM(answer_wait_seconds) AS answer_wait_seconds`).
+		Where("interval_start >= ? AND interval_start < ?", filter.From, filter.To).
+		Group(groups).
+		Order(groups)
+	if filter.Queue != "" {
+		query = query.Where("queue = ?", filter.Queue)
+	}
+	if filter.AgentID != "" {
+		query = query.Where("agent_id = ?", filter.AgentID)
+	}
+
+	var sums []struct {
+		PeriodStart       time.Time
+		Queue             string
+		AgentID           string
+		Offered           int64
+		Answered          int64
+		Abandoned         int64
+		AnsweredInTarget  int64
+		Handled           int64
+		HandleSeconds     float64
+		AnswerWaitSeconds float64
+	}
+	if err := query.Scan(&sums).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]models.IntervalReportRow, 0, len(sums))
+	for _, sum := range sums {
+		row := models.IntervalReportRow{
+			PeriodStart:      sum.PeriodStart,
+			Queue:            sum.Queue,
+			AgentID:          sum.AgentID,
+			Offered:          sum.Offered,
+			Answered:         sum.Answered,
+			Abandoned:        sum.Abandoned,
+			AnsweredInTarget: sum.AnsweredInTarget,
+			ServiceLevel:     1,
+		}
+		if sum.Handled > 0 {
+			row.AHTSeconds = sum.HandleSeconds / float64(sum.Handled)
+		}
+		if sum.Answered > 0 {
+			row.ASASeconds = sum.AnswerWaitSeconds / float64(sum.Answered)
+		}
+		if total := sum.Answered + sum.Abandoned; total > 0 {
+			row.ServiceLevel = float64(sum.AnsweredInTarget) / float64(total)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// StartRollup rolls up the last lookback of calls now and every interval until ctx is canceled.
+// Every replica runs the loop, but only one rolls up per interval.
+func (s *reportingService) StartRollup(ctx context.Context, interval, lookback time.Duration) {
+	run := func() {
+		now := time.Now()
+		if _, err := s.scheduledRollUp(now.Add(-lookback), now, interval); err != nil {
+			slog.ErrorContext(ctx, "Failed to roll up interval stats", "error", err)
+		}
+	}
+	run()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+// scheduledRollUp rolls up [from, to) under an advisory lock, skipping the run when another
+// replica holds the lock or rolled up [from, to) within the last interval. Only rows in the
+// window count as a recent run, so a backfill of older intervals never skips it. It reports
+// whether it ran.
+func (s *reportingService) scheduledRollUp(from, to time.Time, interval time.Duration) (bool, error) {
+	from, to = alignDown(from), alignUp(to)
+
+	ran := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", rollupLockKey).Scan(&locked).Error; err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		// MAX is NULL while the window has no rows
+		var last sql.NullTime
+		if err := tx.Model(&models.IntervalStat{}).
+			Where("interval_start >= ? AND interval_start < ?", from, to).
+			Select("MAX(updated_at)").Scan(&last).Error; err != nil {
+			return err
+		}
+		if last.Valid && time.Since(last.Time) < interval/2 {
+			return nil
+		}
+
+		ran = true
+		_, err := rollUp(tx, from, to)
+		return err
+	})
+
+	return ran, err
+}
+
+// agentColumn keeps agents apart only when the report is broken down by agent
+func agentColumn(filter models.IntervalReportFilter) string {
+	if filter.ByAgent || filter.AgentID != "" {
+		return "agent_id"
+	}
+	return "''"
+}
+
+func alignDown(t time.Time) time.Time {
+	return t.UTC().Truncate(intervalLength)
+}
+
+func alignUp(t time.Time) time.Time {
+	aligned := alignDown(t)
+	if aligned.Before(t) {
+		aligned = aligned.Add(intervalLength)
+	}
+	return aligned
+}
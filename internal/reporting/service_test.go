@@ -0,0 +1,58 @@
+package reporting
+
+import (
+	"call-center-api/pkg/testutil"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestScheduledRollUp(t *testing.T) {
+	now := time.Now()
+	from, to := now.Add(-24*time.Hour), now
+
+	tests := []struct {
+		name    string
+		locked  bool
+		last    interface{} // newest updated_at in the window, nil when it has no rows
+		wantRan bool
+	}{
+		{name: "another replica holds the lock", locked: false},
+		{name: "window rolled up recently", locked: true, last: now.Add(-time.Minute)},
+		{name: "window rolled up an interval ago", locked: true, last: now.Add(-10 * time.Minute), wantRan: true},
+		{name: "window never rolled up", locked: true, last: nil, wantRan: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := testutil.MockDB(t)
+			s := NewReportingService(db).(*reportingService)
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`SELECT pg_try_advisory_xact_lock`).
+				WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(tt.locked))
+			if tt.locked {
+				// Freshness is judged only by rows in the window being rolled up
+				mock.ExpectQuery(`SELECT MAX\(updated_at\) FROM "interval_stats" WHERE interval_start >= \$1 AND interval_start < \$2`).
+					WithArgs(alignDown(from), alignUp(to)).
+					WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(tt.last))
+			}
+			if tt.wantRan {
+				mock.ExpectExec(`DELETE FROM "interval_stats" WHERE interval_start >= \$1 AND interval_start < \$2`).
+					WithArgs(alignDown(from), alignUp(to)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(`INSERT INTO interval_stats`).WillReturnResult(sqlmock.NewResult(0, 3))
+			}
+			mock.ExpectCommit()
+
+			ran, err := s.scheduledRollUp(from, to, 5*time.Minute)
+			if err != nil {
+				t.Fatalf("scheduledRollUp() error = %v", err)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("scheduledRollUp() ran = %v, want %v", ran, tt.wantRan)
+			}
+		})
+	}
+}
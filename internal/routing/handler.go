@@ -0,0 +1,440 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoutingHandler struct {
+	service RoutingService
+}
+
+func NewRoutingHandler(service RoutingService) *RoutingHandler {
+	return &RoutingHandler{service: service}
+}
+
+func (h *RoutingHandler) SaveBusinessHours(c *fiber.Ctx) error {
+	var req models.BusinessHours
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	req.ID = 0
+	req.Queue = c.Params("queue")
+
+	hours, err := h.service.SaveBusinessHours(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to save business hours",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Business hours saved successfully",
+		Data:    hours,
+	})
+}
+
+func (h *RoutingHandler) ListBusinessHours(c *fiber.Ctx) error {
+	hours, err := h.service.ListBusinessHours()
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch business hours",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    hours,
+	})
+}
+
+func (h *RoutingHandler) DeleteBusinessHours(c *fiber.Ctx) error {
+	if err := h.service.DeleteBusinessHours(c.Params("queue")); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Business hours not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Business hours deleted successfully",
+	})
+}
+
+func (h *RoutingHandler) CreateHoliday(c *fiber.Ctx) error {
+	var req models.Holiday
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	req.ID = 0
+
+	holiday, err := h.service.CreateHoliday(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create holiday",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(models.Response{
+		Success: true,
+		Message: "Holiday created successfully",
+		Data:    holiday,
+	})
+}
+
+func (h *RoutingHandler) ListHolidays(c *fiber.Ctx) error {
+	holidays, err := h.service.ListHolidays(c.Query("queue"))
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch holidays",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    holidays,
+	})
+}
+
+func (h *RoutingHandler) DeleteHoliday(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid holiday ID",
+		})
+	}
+
+	if err := h.service.DeleteHoliday(uint(id)); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Holiday not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Holiday deleted successfully",
+	})
+}
+
+func (h *RoutingHandler) CreateQueue(c *fiber.Ctx) error {
+	var req models.Queue
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	queue, err := h.service.CreateQueue(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create queue",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(models.Response{
+		Success: true,
+		Message: "Queue created successfully",
+		Data:    queue,
+	})
+}
+
+func (h *RoutingHandler) UpdateQueue(c *fiber.Ctx) error {
+	var req models.UpdateQueueRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	queue, err := h.service.UpdateQueue(c.Params("name"), req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to update queue",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Queue updated successfully",
+		Data:    queue,
+	})
+}
+
+func (h *RoutingHandler) GetQueue(c *fiber.Ctx) error {
+	queue, err := h.service.GetQueue(c.Params("name"))
+	if err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Queue not found",
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    queue,
+	})
+}
+
+func (h *RoutingHandler) ListQueues(c *fiber.Ctx) error {
+	queues, err := h.service.ListQueues()
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch queues",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    queues,
+	})
+}
+
+func (h *RoutingHandler) DeleteQueue(c *fiber.Ctx) error {
+	if err := h.service.DeleteQueue(c.Params("name")); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to delete queue",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Queue deleted successfully",
+	})
+}
+
+func (h *RoutingHandler) ListMembers(c *fiber.Ctx) error {
+	members, err := h.service.ListMembers(c.Params("name"))
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch queue members",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    members,
+	})
+}
+
+func (h *RoutingHandler) SetMembership(c *fiber.Ctx) error {
+	var req models.QueueMembershipRequest
+
+	// The body is optional; weight defaults to 1
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid request body",
+			})
+		}
+	}
+
+	membership, err := h.service.SetMembership(c.Params("name"), c.Params("agent_id"), req.Weight)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to update queue membership",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Queue membership saved successfully",
+		Data:    membership,
+	})
+}
+
+func (h *RoutingHandler) RemoveMembership(c *fiber.Ctx) error {
+	if err := h.service.RemoveMembership(c.Params("name"), c.Params("agent_id")); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Queue membership not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Queue membership removed successfully",
+	})
+}
+
+func (h *RoutingHandler) ListAgentQueues(c *fiber.Ctx) error {
+	memberships, err := h.service.ListAgentQueues(c.Params("id"))
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch agent queues",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    memberships,
+	})
+}
+
+// GetOpenState reports whether a queue is currently open
+func (h *RoutingHandler) GetOpenState(c *fiber.Ctx) error {
+	state, _, err := h.service.QueueOpenState(c.Params("queue"), time.Now())
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to evaluate business hours",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    state,
+	})
+}
+
+func (h *RoutingHandler) ListOverflowRules(c *fiber.Ctx) error {
+	rules, err := h.service.ListOverflowRules(c.Params("name"))
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch overflow rules",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    rules,
+	})
+}
+
+func (h *RoutingHandler) CreateOverflowRule(c *fiber.Ctx) error {
+	var req models.OverflowRule
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	req.Queue = c.Params("name")
+
+	rule, err := h.service.CreateOverflowRule(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create overflow rule",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(models.Response{
+		Success: true,
+		Message: "Overflow rule created successfully",
+		Data:    rule,
+	})
+}
+
+func (h *RoutingHandler) DeleteOverflowRule(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid overflow rule ID",
+		})
+	}
+
+	if err := h.service.DeleteOverflowRule(uint(id)); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Overflow rule not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Overflow rule deleted successfully",
+	})
+}
+
+// GetOverflowStats reports overflows per source and target queue; defaults to the last 24 hours
+func (h *RoutingHandler) GetOverflowStats(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid from date, expected RFC3339",
+			})
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(400).JSON(models.ErrorResponse{
+				Success: false,
+				Message: "Invalid to date, expected RFC3339",
+			})
+		}
+		to = parsed
+	}
+
+	stats, err := h.service.GetOverflowStats(from, to)
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch overflow stats",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    stats,
+	})
+}
@@ -0,0 +1,135 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"fmt"
+	"time"
+)
+
+// lookahead bounds how far NextOpen searches for an opening
+const lookahead = 14
+
+// ValidateBusinessHours checks the timezone, action and every interval
+func ValidateBusinessHours(hours models.BusinessHours) error {
+	if _, err := time.LoadLocation(hours.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", hours.Timezone)
+	}
+
+	switch hours.AfterHoursAction {
+	case models.AfterHoursCallback, models.AfterHoursVoicemail, models.AfterHoursReject:
+	default:
+		return fmt.Errorf("after_hours_action must be %s, %s or %s",
+			models.AfterHoursCallback, models.AfterHoursVoicemail, models.AfterHoursReject)
+	}
+
+	for _, day := range hours.Days {
+		if day.Weekday < 0 || day.Weekday > 6 {
+			return fmt.Errorf("weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		// A close time at or before the open time wraps past midnight, so "22:00"-"06:00"
+		// is an overnight shift and equal times are open for 24 hours
+		if _, err := time.Parse("15:04", day.Open); err != nil {
+			return fmt.Errorf("invalid open time %q", day.Open)
+		}
+		if _, err := time.Parse("15:04", day.Close); err != nil {
+			return fmt.Errorf("invalid close time %q", day.Close)
+		}
+	}
+
+	return nil
+}
+
+// OpenState evaluates hours and holidays at now. Holidays are matched on the local date.
+func OpenState(hours models.BusinessHours, holidays []models.Holiday, now time.Time) models.QueueOpenState {
+	state := models.QueueOpenState{Queue: hours.Queue, Timezone: hours.Timezone}
+
+	loc, err := time.LoadLocation(hours.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+
+	if holiday, ok := holidayOn(holidays, local); ok {
+		state.Holiday = holiday.Name
+	} else if openAt(hours.Days, local) {
+		state.Open = true
+		return state
+	}
+
+	if next, ok := nextOpen(hours.Days, holidays, local); ok {
+		state.NextOpen = &next
+	}
+	return state
+}
+
+// openAt reports whether local falls inside one of the intervals for its weekday, or in the
+// part of the previous weekday's interval that wraps past midnight
+func openAt(days []models.DayHours, local time.Time) bool {
+	minute := local.Hour()*60 + local.Minute()
+	yesterday := (local.Weekday() + 6) % 7
+	for _, day := range days {
+		open, closing := minutes(day.Open), minutes(day.Close)
+		switch time.Weekday(day.Weekday) {
+		case local.Weekday():
+			if minute >= open && (closing <= open || minute < closing) {
+				return true
+			}
+		case yesterday:
+			if closing <= open && minute < closing {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextOpen finds the earliest opening after local, skipping holidays
+func nextOpen(days []models.DayHours, holidays []models.Holiday, local time.Time) (time.Time, bool) {
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+
+	for offset := 0; offset <= lookahead; offset++ {
+		date := midnight.AddDate(0, 0, offset)
+		if _, ok := holidayOn(holidays, date); ok {
+			continue
+		}
+
+		var earliest *time.Time
+		for _, day := range days {
+			if time.Weekday(day.Weekday) != date.Weekday() {
+				continue
+			}
+			openMinute := minutes(day.Open)
+			open := time.Date(date.Year(), date.Month(), date.Day(), openMinute/60, openMinute%60, 0, 0, date.Location())
+			if !open.After(local) {
+				continue
+			}
+			if earliest == nil || open.Before(*earliest) {
+				earliest = &open
+			}
+		}
+		if earliest != nil {
+			return *earliest, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func holidayOn(holidays []models.Holiday, local time.Time) (models.Holiday, bool) {
+	date := local.Format("2006-01-02")
+	for _, holiday := range holidays {
+		if holiday.Date == date {
+			return holiday, true
+		}
+	}
+	return models.Holiday{}, false
+}
+
+// minutes converts "15:04" to minutes after midnight; values are validated on save
+func minutes(clock string) int {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0
+	}
+	return t.Hour()*60 + t.Minute()
+}
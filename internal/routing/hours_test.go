@@ -0,0 +1,133 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"testing"
+	"time"
+)
+
+func TestOpenState(t *testing.T) {
+	// 2030-01-07 is a Monday
+	weekdays := []models.DayHours{
+		{Weekday: 1, Open: "09:00", Close: "17:00"},
+		{Weekday: 2, Open: "09:00", Close: "17:00"},
+	}
+	overnight := []models.DayHours{
+		{Weekday: 1, Open: "22:00", Close: "06:00"},
+	}
+	allDay := []models.DayHours{
+		{Weekday: 1, Open: "00:00", Close: "00:00"},
+	}
+	holidays := []models.Holiday{{Date: "2030-01-08", Name: "Founders Day"}}
+
+	tests := []struct {
+		name     string
+		days     []models.DayHours
+		timezone string
+		holidays []models.Holiday
+		now      time.Time
+		open     bool
+		holiday  string
+		nextOpen string
+	}{
+		{
+			name: "inside hours",
+			days: weekdays, timezone: "UTC",
+			now:  time.Date(2030, 1, 7, 12, 0, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "at closing time",
+			days: weekdays, timezone: "UTC",
+			now:      time.Date(2030, 1, 7, 17, 0, 0, 0, time.UTC),
+			nextOpen: "2030-01-08T09:00:00Z",
+		},
+		{
+			name: "before opening",
+			days: weekdays, timezone: "UTC",
+			now:      time.Date(2030, 1, 7, 8, 59, 0, 0, time.UTC),
+			nextOpen: "2030-01-07T09:00:00Z",
+		},
+		{
+			name: "evaluated in the queue timezone",
+			days: weekdays, timezone: "America/New_York",
+			// 14:30 UTC is 09:30 in New York
+			now:  time.Date(2030, 1, 7, 14, 30, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "holiday skipped for next opening",
+			days: weekdays, timezone: "UTC", holidays: holidays,
+			now:      time.Date(2030, 1, 8, 12, 0, 0, 0, time.UTC),
+			holiday:  "Founders Day",
+			nextOpen: "2030-01-14T09:00:00Z",
+		},
+		{
+			name: "overnight before midnight",
+			days: overnight, timezone: "UTC",
+			now:  time.Date(2030, 1, 7, 23, 0, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "overnight after midnight",
+			days: overnight, timezone: "UTC",
+			now:  time.Date(2030, 1, 8, 5, 59, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "overnight after close",
+			days: overnight, timezone: "UTC",
+			now:      time.Date(2030, 1, 8, 6, 0, 0, 0, time.UTC),
+			nextOpen: "2030-01-14T22:00:00Z",
+		},
+		{
+			name: "overnight before open",
+			days: overnight, timezone: "UTC",
+			now:      time.Date(2030, 1, 7, 5, 0, 0, 0, time.UTC),
+			nextOpen: "2030-01-07T22:00:00Z",
+		},
+		{
+			name: "24 hours at midnight",
+			days: allDay, timezone: "UTC",
+			now:  time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "24 hours before midnight",
+			days: allDay, timezone: "UTC",
+			now:  time.Date(2030, 1, 7, 23, 59, 0, 0, time.UTC),
+			open: true,
+		},
+		{
+			name: "24 hours the next day",
+			days: allDay, timezone: "UTC",
+			now:      time.Date(2030, 1, 8, 0, 0, 0, 0, time.UTC),
+			nextOpen: "2030-01-14T00:00:00Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hours := models.BusinessHours{Queue: "sales", Timezone: tt.timezone, Days: tt.days}
+			state := OpenState(hours, tt.holidays, tt.now)
+
+			if state.Open != tt.open {
+				t.Errorf("Open = %v, want %v", state.Open, tt.open)
+			}
+			if state.Holiday != tt.holiday {
+				t.Errorf("Holiday = %q, want %q", state.Holiday, tt.holiday)
+			}
+			switch {
+			case tt.nextOpen == "" && state.NextOpen != nil:
+				t.Errorf("NextOpen = %v, want none", state.NextOpen)
+			case tt.nextOpen != "" && state.NextOpen == nil:
+				t.Errorf("NextOpen = none, want %s", tt.nextOpen)
+			case tt.nextOpen != "":
+				want, _ := time.Parse(time.RFC3339, tt.nextOpen)
+				if !state.NextOpen.Equal(want) {
+					t.Errorf("NextOpen = %v, want %v", state.NextOpen, want)
+				}
+			}
+		})
+	}
+}
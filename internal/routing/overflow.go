@@ -0,0 +1,75 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"errors"
+	"time"
+)
+
+// queueExists reports whether name is a configured queue; the default queue always exists
+func (s *routingService) queueExists(name string) bool {
+	if name == models.DefaultQueue {
+		return true
+	}
+	_, err := s.GetQueue(name)
+	return err == nil
+}
+
+func (s *routingService) CreateOverflowRule(rule models.OverflowRule) (*models.OverflowRule, error) {
+	rule.ID = 0
+	if rule.MaxWaitSeconds < 0 || rule.MaxQueueSize < 0 {
+		return nil, errors.New("thresholds must not be negative")
+	}
+	if rule.MaxWaitSeconds == 0 && rule.MaxQueueSize == 0 {
+		return nil, errors.New("max_wait_seconds or max_queue_size is required")
+	}
+	if rule.TargetQueue == rule.Queue {
+		return nil, errors.New("target_queue must differ from the queue")
+	}
+	if !s.queueExists(rule.Queue) {
+		return nil, errors.New("queue not found")
+	}
+	if !s.queueExists(rule.TargetQueue) {
+		return nil, errors.New("target queue not found")
+	}
+
+	if err := s.db.Create(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListOverflowRules returns a queue's rules in evaluation order
+func (s *routingService) ListOverflowRules(queue string) ([]models.OverflowRule, error) {
+	var rules []models.OverflowRule
+	if err := s.db.Where("queue = ?", queue).Order("position ASC, id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (s *routingService) DeleteOverflowRule(id uint) error {
+	result := s.db.Delete(&models.OverflowRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("overflow rule not found")
+	}
+	return nil
+}
+
+// GetOverflowStats counts overflows between each pair of queues in [from, to)
+func (s *routingService) GetOverflowStats(from, to time.Time) ([]models.OverflowStat, error) {
+	var stats []models.OverflowStat
+	err := s.db.Model(&models.CallEvent{}).
+		Select("from_queue, queue AS to_queue, COUNT(*) AS count").
+		Where("type = ? AND created_at >= ? AND created_at < ?", models.CallEventOverflowed, from, to).
+		Group("from_queue, queue").
+		Order("count DESC").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,221 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// queueNamePattern keeps queue names safe to embed in Redis keys and URLs
+var queueNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+func validateQueue(queue models.Queue) error {
+	if !queueNamePattern.MatchString(queue.Name) {
+		return errors.New("queue name must be lowercase letters, digits, '-' or '_'")
+	}
+	switch queue.Strategy {
+	case models.StrategyRoundRobin, models.StrategyLongestIdle, models.StrategyWeighted:
+	default:
+		return fmt.Errorf("strategy must be %s, %s or %s",
+			models.StrategyRoundRobin, models.StrategyLongestIdle, models.StrategyWeighted)
+	}
+	if queue.SLATargetSeconds <= 0 {
+		return errors.New("sla_target_seconds must be positive")
+	}
+	if queue.SLATargetPercent <= 0 || queue.SLATargetPercent > 100 {
+		return errors.New("sla_target_percent must be between 0 and 100")
+	}
+	if queue.SLAWindowMinutes <= 0 || queue.SLAWindowMinutes > 1440 {
+		return errors.New("sla_window_minutes must be between 1 and 1440")
+	}
+	if queue.MaxWaitSeconds < 0 {
+		return errors.New("max_wait_seconds must not be negative")
+	}
+	if queue.AgentCapacity < 0 {
+		return errors.New("agent_capacity must not be negative")
+	}
+	if queue.WrapUpSeconds < 0 {
+		return errors.New("wrap_up_seconds must not be negative")
+	}
+	for disposition, seconds := range queue.DispositionWrapUp {
+		if seconds < 0 {
+			return fmt.Errorf("wrap-up for disposition %q must not be negative", disposition)
+		}
+	}
+	return nil
+}
+
+func applyQueueDefaults(queue *models.Queue) {
+	if queue.Strategy == "" {
+		queue.Strategy = models.StrategyRoundRobin
+	}
+	if queue.SLATargetSeconds == 0 {
+		queue.SLATargetSeconds = 20
+	}
+	if queue.SLATargetPercent == 0 {
+		queue.SLATargetPercent = 80
+	}
+	if queue.SLAWindowMinutes == 0 {
+		queue.SLAWindowMinutes = 30
+	}
+}
+
+func (s *routingService) CreateQueue(queue models.Queue) (*models.Queue, error) {
+	queue.ID = 0
+	applyQueueDefaults(&queue)
+	if err := validateQueue(queue); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(&queue).Error; err != nil {
+		return nil, err
+	}
+	return &queue, nil
+}
+
+func (s *routingService) UpdateQueue(name string, update models.UpdateQueueRequest) (*models.Queue, error) {
+	queue, err := s.GetQueue(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Description != "" {
+		queue.Description = update.Description
+	}
+	if update.Strategy != "" {
+		queue.Strategy = update.Strategy
+	}
+	// An explicit zero reaches validation instead of being silently ignored
+	if update.SLATargetSeconds != nil {
+		queue.SLATargetSeconds = *update.SLATargetSeconds
+	}
+	if update.SLATargetPercent != nil {
+		queue.SLATargetPercent = *update.SLATargetPercent
+	}
+	if update.SLAWindowMinutes != nil {
+		queue.SLAWindowMinutes = *update.SLAWindowMinutes
+	}
+	if update.MaxWaitSeconds != nil {
+		queue.MaxWaitSeconds = *update.MaxWaitSeconds
+	}
+	if update.AgentCapacity != nil {
+		queue.AgentCapacity = *update.AgentCapacity
+	}
+	if update.WrapUpSeconds != nil {
+		queue.WrapUpSeconds = *update.WrapUpSeconds
+	}
+	if update.DispositionWrapUp != nil {
+		queue.DispositionWrapUp = update.DispositionWrapUp
+	}
+	if update.Priority != nil {
+		queue.Priority = *update.Priority
+	}
+
+	if err := validateQueue(*queue); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(queue).Error; err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+func (s *routingService) GetQueue(name string) (*models.Queue, error) {
+	var queue models.Queue
+	if err := s.db.Where("name = ?", name).First(&queue).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("queue not found")
+		}
+		return nil, err
+	}
+	return &queue, nil
+}
+
+// ListQueues returns queues highest priority first, so callers can serve them in that order
+func (s *routingService) ListQueues() ([]models.Queue, error) {
+	var queues []models.Queue
+	if err := s.db.Order("priority DESC, name ASC").Find(&queues).Error; err != nil {
+		return nil, err
+	}
+	return queues, nil
+}
+
+// DeleteQueue removes a queue together with its memberships and any overflow rules involving it
+func (s *routingService) DeleteQueue(name string) error {
+	if name == models.DefaultQueue {
+		return errors.New("the default queue cannot be deleted")
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ?", name).Delete(&models.Queue{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("queue not found")
+		}
+		if err := tx.Where("queue = ? OR target_queue = ?", name, name).Delete(&models.OverflowRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("queue = ?", name).Delete(&models.QueueMembership{}).Error
+	})
+}
+
+// SetMembership adds an agent to a queue or updates their weight
+func (s *routingService) SetMembership(queue, agentID string, weight int) (*models.QueueMembership, error) {
+	if weight <= 0 {
+		weight = 1
+	}
+	if queue != models.DefaultQueue {
+		if _, err := s.GetQueue(queue); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.db.Where("id = ?", agentID).First(&models.Agent{}).Error; err != nil {
+		return nil, errors.New("agent not found")
+	}
+
+	membership := models.QueueMembership{Queue: queue, AgentID: agentID, Weight: weight}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "queue"}, {Name: "agent_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"weight", "updated_at"}),
+	}).Create(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("queue = ? AND agent_id = ?", queue, agentID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (s *routingService) RemoveMembership(queue, agentID string) error {
+	result := s.db.Where("queue = ? AND agent_id = ?", queue, agentID).Delete(&models.QueueMembership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("membership not found")
+	}
+	return nil
+}
+
+func (s *routingService) ListMembers(queue string) ([]models.QueueMembership, error) {
+	var memberships []models.QueueMembership
+	if err := s.db.Where("queue = ?", queue).Order("agent_id ASC").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+func (s *routingService) ListAgentQueues(agentID string) ([]models.QueueMembership, error) {
+	var memberships []models.QueueMembership
+	if err := s.db.Where("agent_id = ?", agentID).Order("queue ASC").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
@@ -0,0 +1,6 @@
+package routing
+
+// Repository interface for routing
+type Repository interface {
+	// Add any database operations here if needed in future
+}
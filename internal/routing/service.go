@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"call-center-api/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoutingService interface {
+	SaveBusinessHours(hours models.BusinessHours) (*models.BusinessHours, error)
+	ListBusinessHours() ([]models.BusinessHours, error)
+	DeleteBusinessHours(queue string) error
+	CreateHoliday(holiday models.Holiday) (*models.Holiday, error)
+	ListHolidays(queue string) ([]models.Holiday, error)
+	DeleteHoliday(id uint) error
+	QueueOpenState(queue string, now time.Time) (models.QueueOpenState, *models.BusinessHours, error)
+	CreateQueue(queue models.Queue) (*models.Queue, error)
+	UpdateQueue(name string, update models.UpdateQueueRequest) (*models.Queue, error)
+	GetQueue(name string) (*models.Queue, error)
+	ListQueues() ([]models.Queue, error)
+	DeleteQueue(name string) error
+	SetMembership(queue, agentID string, weight int) (*models.QueueMembership, error)
+	RemoveMembership(queue, agentID string) error
+	ListMembers(queue string) ([]models.QueueMembership, error)
+	ListAgentQueues(agentID string) ([]models.QueueMembership, error)
+	CreateOverflowRule(rule models.OverflowRule) (*models.OverflowRule, error)
+	ListOverflowRules(queue string) ([]models.OverflowRule, error)
+	DeleteOverflowRule(id uint) error
+	GetOverflowStats(from, to time.Time) ([]models.OverflowStat, error)
+}
+
+type routingService struct {
+	db *gorm.DB
+}
+
+func NewRoutingService(db *gorm.DB) RoutingService {
+	return &routingService{db: db}
+}
+
+// SaveBusinessHours creates or replaces the schedule for a queue
+func (s *routingService) SaveBusinessHours(hours models.BusinessHours) (*models.BusinessHours, error) {
+	if hours.Queue == "" {
+		hours.Queue = models.DefaultQueue
+	}
+	if hours.Timezone == "" {
+		hours.Timezone = "UTC"
+	}
+	if hours.AfterHoursAction == "" {
+		hours.AfterHoursAction = models.AfterHoursReject
+	}
+	if err := ValidateBusinessHours(hours); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "queue"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "days", "after_hours_action", "after_hours_reason", "updated_at"}),
+	}).Create(&hours).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var saved models.BusinessHours
+	if err := s.db.Where("queue = ?", hours.Queue).First(&saved).Error; err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (s *routingService) ListBusinessHours() ([]models.BusinessHours, error) {
+	var hours []models.BusinessHours
+	if err := s.db.Order("queue ASC").Find(&hours).Error; err != nil {
+		return nil, err
+	}
+	return hours, nil
+}
+
+// DeleteBusinessHours removes a queue's schedule, making it open around the clock again
+func (s *routingService) DeleteBusinessHours(queue string) error {
+	result := s.db.Where("queue = ?", queue).Delete(&models.BusinessHours{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("business hours not found")
+	}
+	return nil
+}
+
+func (s *routingService) CreateHoliday(holiday models.Holiday) (*models.Holiday, error) {
+	if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+		return nil, errors.New("date must be YYYY-MM-DD")
+	}
+	if err := s.db.Create(&holiday).Error; err != nil {
+		return nil, err
+	}
+	return &holiday, nil
+}
+
+// ListHolidays returns holidays for a queue, including those that apply to every queue
+func (s *routingService) ListHolidays(queue string) ([]models.Holiday, error) {
+	query := s.db.Order("date ASC")
+	if queue != "" {
+		query = query.Where("queue = ? OR queue = ''", queue)
+	}
+
+	var holidays []models.Holiday
+	if err := query.Find(&holidays).Error; err != nil {
+		return nil, err
+	}
+	return holidays, nil
+}
+
+func (s *routingService) DeleteHoliday(id uint) error {
+	result := s.db.Delete(&models.Holiday{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("holiday not found")
+	}
+	return nil
+}
+
+// QueueOpenState evaluates a queue's schedule. Queues without business hours are always open
+// and return a nil schedule.
+func (s *routingService) QueueOpenState(queue string, now time.Time) (models.QueueOpenState, *models.BusinessHours, error) {
+	var hours models.BusinessHours
+	err := s.db.Where("queue = ?", queue).First(&hours).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.QueueOpenState{Queue: queue, Open: true}, nil, nil
+	}
+	if err != nil {
+		return models.QueueOpenState{}, nil, err
+	}
+
+	holidays, err := s.ListHolidays(queue)
+	if err != nil {
+		return models.QueueOpenState{}, nil, err
+	}
+
+	return OpenState(hours, holidays, now), &hours, nil
+}
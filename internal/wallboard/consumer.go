@@ -0,0 +1,160 @@
+package wallboard
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// StartConsumer applies new incoming_calls, assigned_calls, agent_changes and alerts messages
+// to the board. History is not replayed: the board's starting state comes from reconcile.
+func (s *wallboardService) StartConsumer(ctx context.Context) error {
+	if s.consumer == nil {
+		return errors.New("wallboard consumer not initialized")
+	}
+
+	err := s.consumer.Consume(ctx, func(message *sarama.ConsumerMessage) {
+		if err := s.handleMessage(message.Topic, string(message.Key), message.Value); err != nil {
+			slog.ErrorContext(database.MessageContext(message), "Failed to process wallboard event", "topic", message.Topic, "key", string(message.Key), "error", err)
+		}
+	})
+	if ctx.Err() != nil {
+		slog.Info("Wallboard consumer context canceled")
+		return ctx.Err()
+	}
+	return err
+}
+
+// handleMessage updates the board from one Kafka message
+func (s *wallboardService) handleMessage(topic, key string, value []byte) error {
+	switch topic {
+	case "incoming_calls":
+		var call models.IncomingCall
+		if err := json.Unmarshal(value, &call); err != nil {
+			return fmt.Errorf("failed to decode incoming call: %w", err)
+		}
+		s.callArrived(call)
+
+	case "assigned_calls":
+		var call models.AssignedCall
+		if err := json.Unmarshal(value, &call); err != nil {
+			return fmt.Errorf("failed to decode assigned call: %w", err)
+		}
+		s.callUpdated(call)
+
+	case "agent_changes":
+		var agent models.Agent
+		if err := json.Unmarshal(value, &agent); err != nil {
+			return fmt.Errorf("failed to decode agent: %w", err)
+		}
+
+		action, _, _ := strings.Cut(key, ":")
+		switch action {
+		case "state_change":
+			s.mu.Lock()
+			if status, ok := s.agents[agent.ID]; ok {
+				status.state = agent.State
+				s.agents[agent.ID] = status
+			}
+			s.mu.Unlock()
+		case "create_agent", "session_change":
+			return s.reloadAgent(agent.ID)
+		case "delete_agent":
+			s.mu.Lock()
+			delete(s.agents, agent.ID)
+			s.mu.Unlock()
+		}
+
+	case "alerts":
+		var alert models.Alert
+		if err := json.Unmarshal(value, &alert); err != nil {
+			return fmt.Errorf("failed to decode alert: %w", err)
+		}
+
+		action, _, _ := strings.Cut(key, ":")
+		s.mu.Lock()
+		if action == models.AlertResolved {
+			delete(s.alerts, alert.Key)
+		} else {
+			s.alerts[alert.Key] = alert
+		}
+		s.mu.Unlock()
+
+		// Alerts go out right away instead of waiting for the next update
+		s.broadcast(Message{Type: "alert_" + action, Data: alert})
+	}
+
+	return nil
+}
+
+// callArrived puts a new call in its queue. Replayed arrivals for calls that already left
+// the queue, or that are older than the waiting horizon, are ignored.
+func (s *wallboardService) callArrived(call models.IncomingCall) {
+	if time.Since(call.Timestamp) > waitingHorizon {
+		return
+	}
+	queue := call.Queue
+	if queue == "" {
+		queue = models.DefaultQueue
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, done := s.outcomes[call.CallID]; done {
+		return
+	}
+	if _, open := s.openCalls[call.CallID]; open {
+		return
+	}
+	s.waiting[call.CallID] = waitingCall{queue: queue, since: call.Timestamp}
+}
+
+// callUpdated takes a call out of its queue and tracks it on its agent until it ends
+func (s *wallboardService) callUpdated(call models.AssignedCall) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.waiting, call.CallID)
+	if call.Status == models.CallStatusAssigned {
+		s.openCalls[call.CallID] = call.AssignedAgentID
+	} else {
+		delete(s.openCalls, call.CallID)
+	}
+
+	// Completions keep the answered outcome recorded at assignment
+	if _, seen := s.outcomes[call.CallID]; seen && call.Status != models.CallStatusAbandoned {
+		return
+	}
+	if o, ok := callOutcome(call); ok {
+		s.outcomes[call.CallID] = o
+	}
+}
+
+// reloadAgent refreshes one agent's state and sign-in from Postgres
+func (s *wallboardService) reloadAgent(agentID string) error {
+	var agent models.Agent
+	if err := s.db.Select("id", "state", "is_admin").Where("id = ?", agentID).First(&agent).Error; err != nil {
+		return fmt.Errorf("failed to load agent %s: %w", agentID, err)
+	}
+	if agent.IsAdmin {
+		return nil
+	}
+
+	var live int64
+	if err := s.db.Model(&models.Agent{}).Where("id = ?", agentID).Scopes(models.LiveSession).Count(&live).Error; err != nil {
+		return fmt.Errorf("failed to check agent session: %w", err)
+	}
+
+	s.mu.Lock()
+	s.agents[agentID] = agentStatus{state: agent.State, signedIn: live > 0}
+	s.mu.Unlock()
+	return nil
+}
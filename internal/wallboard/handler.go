@@ -0,0 +1,65 @@
+package wallboard
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/metrics"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+type WallboardHandler struct {
+	service WallboardService
+}
+
+func NewWallboardHandler(service WallboardService) *WallboardHandler {
+	return &WallboardHandler{service: service}
+}
+
+// GetWallboard returns the current wallboard once, for clients that cannot hold a socket open
+func (h *WallboardHandler) GetWallboard(c *fiber.Ctx) error {
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    h.service.Snapshot(),
+	})
+}
+
+// WebSocketHandler sends a snapshot on connect, then pushes wallboard updates and alerts
+func (h *WallboardHandler) WebSocketHandler(c *websocket.Conn) {
+	defer c.Close()
+
+	metrics.WebSocketConnections.WithLabelValues("wallboard").Inc()
+	defer metrics.WebSocketConnections.WithLabelValues("wallboard").Dec()
+
+	updates, unsubscribe := h.service.Subscribe()
+	defer unsubscribe()
+
+	if err := c.WriteJSON(Message{Type: "snapshot", Data: h.service.Snapshot()}); err != nil {
+		slog.Warn("Failed to send wallboard snapshot", "error", err)
+		return
+	}
+
+	// Reads only detect the supervisor going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case message := <-updates:
+			if err := c.WriteJSON(message); err != nil {
+				slog.Warn("Failed to send wallboard update", "error", err)
+				return
+			}
+		}
+	}
+}
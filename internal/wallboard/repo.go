@@ -0,0 +1,6 @@
+package wallboard
+
+// Repository interface for the wallboard
+type Repository interface {
+	// Add any database operations here if needed in future
+}
@@ -0,0 +1,427 @@
+package wallboard
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"call-center-api/pkg/metrics"
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// pushInterval is how often subscribers receive a fresh wallboard
+	pushInterval = time.Second
+	// reconcileInterval is how often the board is rebuilt from Postgres, correcting events
+	// that never reach Kafka (e.g. dropped calls, overflow moves, queued abandons)
+	reconcileInterval = 30 * time.Second
+	// waitingHorizon bounds how far back a call can still be considered waiting
+	waitingHorizon = 24 * time.Hour
+	// defaultSLATarget matches the queues table default for queues without a row
+	defaultSLATarget = 20
+	// subscriberBuffer holds alert messages for a subscriber while it writes an update
+	subscriberBuffer = 16
+)
+
+// serviceLevelWindows are the trailing periods service level is reported over, in minutes
+var serviceLevelWindows = []int{15, 30, 60}
+
+// Message is pushed to subscribers: "update" carries a Wallboard, "alert_fired" and
+// "alert_resolved" an Alert
+type Message struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type WallboardService interface {
+	Snapshot() models.Wallboard
+	Subscribe() (<-chan Message, func())
+	StartConsumer(ctx context.Context) error
+	StartReconciler(ctx context.Context)
+	StartPublisher(ctx context.Context)
+}
+
+// waitingCall is a call sitting in a queue
+type waitingCall struct {
+	queue string
+	since time.Time
+}
+
+// outcome is how a call stopped waiting, kept for the longest service level window
+type outcome struct {
+	queue    string
+	at       time.Time
+	answered bool
+	wait     time.Duration
+}
+
+// agentStatus is an agent's routing state and whether they are signed in
+type agentStatus struct {
+	state    string
+	signedIn bool
+}
+
+type wallboardService struct {
+	db       *gorm.DB
+	consumer *database.KafkaTail
+
+	mu        sync.Mutex
+	waiting   map[string]waitingCall
+	outcomes  map[string]outcome
+	agents    map[string]agentStatus
+	openCalls map[string]string // call ID -> agent ID
+	targets   map[string]int
+	alerts    map[string]models.Alert
+
+	subsMu      sync.Mutex
+	subscribers map[chan Message]struct{}
+}
+
+func NewWallboardService(db *gorm.DB, consumer *database.KafkaTail) WallboardService {
+	return &wallboardService{
+		db:          db,
+		consumer:    consumer,
+		waiting:     map[string]waitingCall{},
+		outcomes:    map[string]outcome{},
+		agents:      map[string]agentStatus{},
+		openCalls:   map[string]string{},
+		targets:     map[string]int{},
+		alerts:      map[string]models.Alert{},
+		subscribers: map[chan Message]struct{}{},
+	}
+}
+
+// Snapshot computes the wallboard from the current board state
+func (s *wallboardService) Snapshot() models.Wallboard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	horizon := now.Add(-time.Duration(serviceLevelWindows[len(serviceLevelWindows)-1]) * time.Minute)
+	for callID, o := range s.outcomes {
+		if o.at.Before(horizon) {
+			delete(s.outcomes, callID)
+		}
+	}
+
+	board := models.Wallboard{
+		GeneratedAt:   now,
+		AgentsByState: map[string]int{},
+		ServiceLevel:  newWindows(),
+	}
+	queues := map[string]*models.QueueWallboard{}
+	queue := func(name string) *models.QueueWallboard {
+		if q, ok := queues[name]; ok {
+			return q
+		}
+		q := &models.QueueWallboard{Queue: name, SLATargetSeconds: s.target(name), ServiceLevel: newWindows()}
+		queues[name] = q
+		return q
+	}
+	for name := range s.targets {
+		queue(name)
+	}
+
+	for _, call := range s.waiting {
+		wait := int64(now.Sub(call.since).Seconds())
+		q := queue(call.queue)
+		q.CallsWaiting++
+		board.CallsWaiting++
+		if wait > q.LongestWaitSeconds {
+			q.LongestWaitSeconds = wait
+		}
+		if wait > board.LongestWaitSeconds {
+			board.LongestWaitSeconds = wait
+		}
+	}
+
+	for _, o := range s.outcomes {
+		q := queue(o.queue)
+		inTarget := o.wait <= time.Duration(q.SLATargetSeconds)*time.Second
+		for i, minutes := range serviceLevelWindows {
+			if o.at.Before(now.Add(-time.Duration(minutes) * time.Minute)) {
+				continue
+			}
+			count(&board.ServiceLevel[i], o.answered, inTarget)
+			count(&q.ServiceLevel[i], o.answered, inTarget)
+		}
+	}
+
+	onCall := map[string]bool{}
+	for _, agentID := range s.openCalls {
+		onCall[agentID] = true
+	}
+	for agentID, agent := range s.agents {
+		switch {
+		case !agent.signedIn:
+			board.AgentsByState[models.WallboardStateOffline]++
+		case agent.state == models.AgentStateAvailable && onCall[agentID]:
+			board.AgentsByState[models.WallboardStateOnCall]++
+		default:
+			board.AgentsByState[agent.state]++
+		}
+	}
+
+	for i := range board.ServiceLevel {
+		rate(&board.ServiceLevel[i])
+	}
+	board.Queues = make([]models.QueueWallboard, 0, len(queues))
+	for _, q := range queues {
+		for i := range q.ServiceLevel {
+			rate(&q.ServiceLevel[i])
+		}
+		board.Queues = append(board.Queues, *q)
+	}
+	sort.Slice(board.Queues, func(i, j int) bool { return board.Queues[i].Queue < board.Queues[j].Queue })
+
+	board.Alerts = make([]models.Alert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		board.Alerts = append(board.Alerts, alert)
+	}
+	sort.Slice(board.Alerts, func(i, j int) bool { return board.Alerts[i].FiredAt.Before(board.Alerts[j].FiredAt) })
+
+	return board
+}
+
+// Subscribe registers for pushed messages; the returned func unsubscribes. Slow
+// subscribers skip messages rather than holding up the others.
+func (s *wallboardService) Subscribe() (<-chan Message, func()) {
+	ch := make(chan Message, subscriberBuffer)
+
+	s.subsMu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.subsMu.Unlock()
+
+	return ch, func() {
+		s.subsMu.Lock()
+		delete(s.subscribers, ch)
+		s.subsMu.Unlock()
+	}
+}
+
+// StartPublisher pushes the wallboard to subscribers every pushInterval until ctx is canceled.
+// The agents_by_state gauge is refreshed on the same tick whether or not anyone is watching.
+func (s *wallboardService) StartPublisher(ctx context.Context) {
+	ticker := time.NewTicker(pushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			board := s.Snapshot()
+			metrics.AgentsByState.Reset()
+			for state, count := range board.AgentsByState {
+				metrics.AgentsByState.WithLabelValues(state).Set(float64(count))
+			}
+
+			s.subsMu.Lock()
+			watched := len(s.subscribers) > 0
+			s.subsMu.Unlock()
+			if watched {
+				s.broadcast(Message{Type: "update", Data: board})
+			}
+		}
+	}
+}
+
+func (s *wallboardService) broadcast(message Message) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- message:
+		default:
+		}
+	}
+}
+
+// StartReconciler rebuilds the board from Postgres now and every reconcileInterval until ctx is canceled
+func (s *wallboardService) StartReconciler(ctx context.Context) {
+	if err := s.reconcile(); err != nil {
+		slog.ErrorContext(ctx, "Failed to load wallboard", "error", err)
+	}
+
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.reconcile(); err != nil {
+				slog.ErrorContext(ctx, "Failed to reconcile wallboard", "error", err)
+			}
+		}
+	}
+}
+
+// reconcile loads queues, agents, open calls, waiting calls, recent outcomes and open alerts and swaps
+// them in, replacing whatever the Kafka events built since the last run
+func (s *wallboardService) reconcile() error {
+	now := time.Now()
+
+	var queues []models.Queue
+	if err := s.db.Select("name", "sla_target_seconds").Find(&queues).Error; err != nil {
+		return err
+	}
+	targets := map[string]int{models.DefaultQueue: defaultSLATarget}
+	for _, q := range queues {
+		targets[q.Name] = q.SLATargetSeconds
+	}
+
+	var agentRows []models.Agent
+	if err := s.db.Select("id", "state").Where("is_admin = ?", false).Find(&agentRows).Error; err != nil {
+		return err
+	}
+	var signedIn []string
+	if err := s.db.Model(&models.Agent{}).Scopes(models.LiveSession).Pluck("id", &signedIn).Error; err != nil {
+		return err
+	}
+	live := map[string]bool{}
+	for _, id := range signedIn {
+		live[id] = true
+	}
+	agents := map[string]agentStatus{}
+	for _, agent := range agentRows {
+		agents[agent.ID] = agentStatus{state: agent.State, signedIn: live[agent.ID]}
+	}
+
+	var open []models.AssignedCall
+	if err := s.db.Select("call_id", "assigned_agent_id").
+		Where("status = ? AND assigned_agent_id <> ''", models.CallStatusAssigned).
+		Find(&open).Error; err != nil {
+		return err
+	}
+	openCalls := map[string]string{}
+	for _, call := range open {
+		openCalls[call.CallID] = call.AssignedAgentID
+	}
+
+	// A call is waiting while the latest entry on its timeline puts it in a queue
+	var waitingRows []struct {
+		CallID string
+		Queue  string
+		Since  time.Time
+	}
+	err := s.db.Raw(`
+		SELECT latest.call_id, latest.queue, first.since
+		FROM (
+			SELECT DISTINCT ON (call_id) call_id, type, queue
+			FROM call_events
+			WHERE created_at >= ?
+			ORDER BY call_id, created_at DESC, id DESC
+		) AS latest
+		JOIN (
+			SELECT call_id, MIN(created_at) AS since
+			FROM call_events
+			WHERE created_at >= ?
+			GROUP BY call_id
+		) AS first ON first.call_id = latest.call_id
+		WHERE latest.type IN ?`,
+		now.Add(-waitingHorizon), now.Add(-waitingHorizon),
+		[]string{models.CallEventQueued, models.CallEventOverflowed},
+	).Scan(&waitingRows).Error
+	if err != nil {
+		return err
+	}
+	waiting := map[string]waitingCall{}
+	for _, row := range waitingRows {
+		waiting[row.CallID] = waitingCall{queue: row.Queue, since: row.Since}
+	}
+
+	horizon := now.Add(-time.Duration(serviceLevelWindows[len(serviceLevelWindows)-1]) * time.Minute)
+	var recent []models.AssignedCall
+	if err := s.db.Where("created_at >= ? OR abandoned_at >= ?", horizon, horizon).Find(&recent).Error; err != nil {
+		return err
+	}
+	outcomes := map[string]outcome{}
+	for _, call := range recent {
+		if o, ok := callOutcome(call); ok {
+			outcomes[call.CallID] = o
+		}
+	}
+
+	var openAlerts []models.Alert
+	if err := s.db.Where("resolved_at IS NULL").Find(&openAlerts).Error; err != nil {
+		return err
+	}
+	alerts := make(map[string]models.Alert, len(openAlerts))
+	for _, alert := range openAlerts {
+		alerts[alert.Key] = alert
+	}
+
+	s.mu.Lock()
+	s.alerts = alerts
+	s.targets = targets
+	s.agents = agents
+	s.openCalls = openCalls
+	s.waiting = waiting
+	s.outcomes = outcomes
+	s.mu.Unlock()
+	return nil
+}
+
+// callOutcome classifies a call record: abandoned calls count against service level,
+// assigned calls count as answered after waiting from arrival to assignment. Calls that
+// never reached an agent for other reasons (dropped, after hours) are left out.
+func callOutcome(call models.AssignedCall) (outcome, bool) {
+	if call.Status == models.CallStatusAbandoned {
+		at := call.CreatedAt
+		if call.AbandonedAt != nil {
+			at = *call.AbandonedAt
+		}
+		return outcome{queue: call.Queue, at: at}, true
+	}
+	if call.AssignedAgentID == "" {
+		return outcome{}, false
+	}
+
+	assignedAt := call.CreatedAt
+	if assignedAt.IsZero() {
+		// Assignments are published before they are saved, so only Timestamp is set
+		assignedAt = call.Timestamp
+	}
+	return outcome{queue: call.Queue, at: assignedAt, answered: true, wait: assignedAt.Sub(call.ReceivedAt)}, true
+}
+
+func (s *wallboardService) target(queue string) int {
+	if target, ok := s.targets[queue]; ok {
+		return target
+	}
+	return defaultSLATarget
+}
+
+func newWindows() []models.ServiceLevelWindow {
+	windows := make([]models.ServiceLevelWindow, len(serviceLevelWindows))
+	for i, minutes := range serviceLevelWindows {
+		windows[i].WindowMinutes = minutes
+	}
+	return windows
+}
+
+func count(window *models.ServiceLevelWindow, answered, inTarget bool) {
+	if !answered {
+		window.Abandoned++
+		return
+	}
+	window.Answered++
+	if inTarget {
+		window.AnsweredInTarget++
+	}
+}
+
+// rate fills in service level; with no calls in the window it is reported as fully met
+func rate(window *models.ServiceLevelWindow) {
+	window.ServiceLevel = 1
+	if total := window.Answered + window.Abandoned; total > 0 {
+		window.ServiceLevel = float64(window.AnsweredInTarget) / float64(total)
+	}
+}
@@ -0,0 +1,160 @@
+package wallboard
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/testutil"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReconcile(t *testing.T) {
+	db, mock := testutil.MockDB(t)
+	s := NewWallboardService(db, nil).(*wallboardService)
+	now := time.Now()
+
+	// State built from Kafka that Postgres no longer backs, e.g. a call the distributor dropped
+	s.waiting["dropped"] = waitingCall{queue: "sales", since: now.Add(-time.Hour)}
+	s.openCalls["finished"] = "a2"
+
+	mock.ExpectQuery(`SELECT "name","sla_target_seconds" FROM "queues"`).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "sla_target_seconds"}).AddRow("sales", 30))
+	mock.ExpectQuery(`SELECT "id","state" FROM "agents" WHERE is_admin = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "state"}).
+			AddRow("a1", models.AgentStateAvailable).
+			AddRow("a2", models.AgentStateAvailable).
+			AddRow("a3", models.AgentStateAux).
+			AddRow("a4", models.AgentStateAvailable))
+	mock.ExpectQuery(`SELECT "id" FROM "agents" WHERE EXISTS \(SELECT 1 FROM agent_sessions`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1").AddRow("a2").AddRow("a3"))
+	mock.ExpectQuery(`SELECT "call_id","assigned_agent_id" FROM "assigned_calls" WHERE \(status = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"call_id", "assigned_agent_id"}).AddRow("open", "a1"))
+	mock.ExpectQuery(`SELECT latest.call_id, latest.queue, first.since`).
+		WillReturnRows(sqlmock.NewRows([]string{"call_id", "queue", "since"}).
+			AddRow("w1", "sales", now.Add(-40*time.Second)).
+			AddRow("w2", models.DefaultQueue, now.Add(-10*time.Second)))
+	abandonedAt := now.Add(-20 * time.Minute)
+	mock.ExpectQuery(`SELECT \* FROM "assigned_calls" WHERE \(created_at >= \$1 OR abandoned_at >= \$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"call_id", "queue", "assigned_agent_id", "status", "received_at", "created_at", "abandoned_at"}).
+			AddRow("answered", "sales", "a2", models.CallStatusCompleted, now.Add(-50*time.Second), now.Add(-35*time.Second), nil).
+			AddRow("abandoned", "sales", "", models.CallStatusAbandoned, now.Add(-21*time.Minute), now.Add(-21*time.Minute), abandonedAt).
+			AddRow("dropped", models.DefaultQueue, "", models.CallStatusDropped, now.Add(-time.Minute), now.Add(-time.Minute), nil))
+	mock.ExpectQuery(`SELECT \* FROM "alerts" WHERE resolved_at IS NULL`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key", "type", "queue", "fired_at"}).
+			AddRow(1, "sla_breach:sales", "sla_breach", "sales", now.Add(-time.Minute)))
+
+	if err := s.reconcile(); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	board := s.Snapshot()
+
+	if board.CallsWaiting != 2 {
+		t.Errorf("calls waiting = %d, want 2", board.CallsWaiting)
+	}
+	if board.LongestWaitSeconds < 40 || board.LongestWaitSeconds > 41 {
+		t.Errorf("longest wait = %ds, want 40s", board.LongestWaitSeconds)
+	}
+	wantStates := map[string]int{
+		models.WallboardStateOnCall:  1,
+		models.AgentStateAvailable:   1,
+		models.AgentStateAux:         1,
+		models.WallboardStateOffline: 1,
+	}
+	if !reflect.DeepEqual(board.AgentsByState, wantStates) {
+		t.Errorf("agents by state = %v, want %v", board.AgentsByState, wantStates)
+	}
+
+	// The abandon is older than the 15 minute window; the dropped call counts in none
+	wantWindows := []models.ServiceLevelWindow{
+		{WindowMinutes: 15, Answered: 1, AnsweredInTarget: 1, ServiceLevel: 1},
+		{WindowMinutes: 30, Answered: 1, AnsweredInTarget: 1, Abandoned: 1, ServiceLevel: 0.5},
+		{WindowMinutes: 60, Answered: 1, AnsweredInTarget: 1, Abandoned: 1, ServiceLevel: 0.5},
+	}
+	if !reflect.DeepEqual(board.ServiceLevel, wantWindows) {
+		t.Errorf("service level = %+v, want %+v", board.ServiceLevel, wantWindows)
+	}
+
+	if len(board.Queues) != 2 || board.Queues[0].Queue != models.DefaultQueue || board.Queues[1].Queue != "sales" {
+		t.Fatalf("queues = %+v, want default and sales", board.Queues)
+	}
+	if sales := board.Queues[1]; sales.CallsWaiting != 1 || sales.SLATargetSeconds != 30 {
+		t.Errorf("sales = %+v, want 1 waiting with a 30s target", sales)
+	}
+	if len(board.Alerts) != 1 || board.Alerts[0].Key != "sla_breach:sales" {
+		t.Errorf("alerts = %+v, want the open SLA breach", board.Alerts)
+	}
+}
+
+func TestHandleMessage(t *testing.T) {
+	s := NewWallboardService(nil, nil).(*wallboardService)
+	s.agents["a1"] = agentStatus{state: models.AgentStateAvailable, signedIn: true}
+	messages, unsubscribe := s.Subscribe()
+	defer unsubscribe()
+
+	now := time.Now()
+	send := func(topic, key string, value interface{}) {
+		t.Helper()
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.handleMessage(topic, key, data); err != nil {
+			t.Fatalf("handleMessage(%s, %s) error = %v", topic, key, err)
+		}
+	}
+	arrival := models.IncomingCall{CallID: "c1", Queue: "sales", Timestamp: now.Add(-5 * time.Second)}
+	assigned := models.AssignedCall{
+		CallID: "c1", Queue: "sales", AssignedAgentID: "a1", Status: models.CallStatusAssigned,
+		ReceivedAt: arrival.Timestamp, Timestamp: now,
+	}
+
+	send("incoming_calls", "c1", arrival)
+	send("incoming_calls", "old", models.IncomingCall{CallID: "old", Timestamp: now.Add(-25 * time.Hour)})
+	if board := s.Snapshot(); board.CallsWaiting != 1 {
+		t.Fatalf("calls waiting after arrival = %d, want 1 (stale arrivals ignored)", board.CallsWaiting)
+	}
+
+	send("assigned_calls", "c1", assigned)
+	board := s.Snapshot()
+	if board.CallsWaiting != 0 || board.AgentsByState[models.WallboardStateOnCall] != 1 {
+		t.Fatalf("after assignment: waiting = %d, by state = %v", board.CallsWaiting, board.AgentsByState)
+	}
+
+	completed := assigned
+	completed.Status = models.CallStatusCompleted
+	send("assigned_calls", "c1", completed)
+	// A replayed arrival for a call that already left the queue must not requeue it
+	send("incoming_calls", "c1", arrival)
+	board = s.Snapshot()
+	if board.CallsWaiting != 0 || board.AgentsByState[models.AgentStateAvailable] != 1 {
+		t.Errorf("after completion: waiting = %d, by state = %v", board.CallsWaiting, board.AgentsByState)
+	}
+	if window := board.ServiceLevel[0]; window.Answered != 1 || window.AnsweredInTarget != 1 {
+		t.Errorf("15m window = %+v, want the call answered in target once", window)
+	}
+
+	send("agent_changes", "state_change:a1", models.Agent{ID: "a1", State: models.AgentStateAux})
+	send("agent_changes", "state_change:a9", models.Agent{ID: "a9", State: models.AgentStateAux})
+	if board := s.Snapshot(); board.AgentsByState[models.AgentStateAux] != 1 || len(s.agents) != 1 {
+		t.Errorf("after state change: by state = %v, agents = %v", board.AgentsByState, s.agents)
+	}
+
+	alert := models.Alert{Key: "max_wait:c2", Type: "max_wait", Queue: "sales", FiredAt: now}
+	send("alerts", models.AlertFired+":max_wait:c2", alert)
+	if board := s.Snapshot(); len(board.Alerts) != 1 {
+		t.Errorf("alerts after firing = %d, want 1", len(board.Alerts))
+	}
+	if message := <-messages; message.Type != "alert_fired" {
+		t.Errorf("pushed %q, want alert_fired", message.Type)
+	}
+	send("alerts", models.AlertResolved+":max_wait:c2", alert)
+	if board := s.Snapshot(); len(board.Alerts) != 0 {
+		t.Errorf("alerts after resolving = %d, want 0", len(board.Alerts))
+	}
+	if message := <-messages; message.Type != "alert_resolved" {
+		t.Errorf("pushed %q, want alert_resolved", message.Type)
+	}
+}
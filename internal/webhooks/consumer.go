@@ -0,0 +1,103 @@
+package webhooks
+
+import (
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/IBM/sarama"
+)
+
+// StartConsumer turns assigned_calls and agent_changes messages into webhook deliveries
+func (s *webhookService) StartConsumer(ctx context.Context) error {
+	if s.consumer == nil {
+		return errors.New("webhook consumer not initialized")
+	}
+
+	handler := &eventHandler{
+		processMessage: s.handleMessage,
+	}
+
+	// Keep consuming until context is canceled
+	for {
+		topics := []string{"assigned_calls", "agent_changes"}
+		if err := s.consumer.ConsumeRawMessages(ctx, topics, handler); err != nil {
+			if ctx.Err() != nil {
+				slog.Info("Webhook consumer context canceled")
+				return ctx.Err()
+			}
+			slog.Error("Webhook consumer error", "error", err)
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// handleMessage maps a Kafka message to its webhook event type
+func (s *webhookService) handleMessage(topic, key string, value []byte) error {
+	switch topic {
+	case "assigned_calls":
+		var call models.AssignedCall
+		if err := json.Unmarshal(value, &call); err != nil {
+			return fmt.Errorf("failed to decode assigned call: %w", err)
+		}
+
+		switch call.Status {
+		case models.CallStatusAssigned:
+			return s.Emit(models.EventCallAssigned, call)
+		case models.CallStatusCompleted:
+			return s.Emit(models.EventCallCompleted, call)
+		case models.CallStatusAbandoned:
+			return s.Emit(models.EventCallAbandoned, call)
+		}
+
+	case "agent_changes":
+		var agent models.Agent
+		if err := json.Unmarshal(value, &agent); err != nil {
+			return fmt.Errorf("failed to decode agent: %w", err)
+		}
+
+		action, _, _ := strings.Cut(key, ":")
+		switch action {
+		case "create_agent":
+			return s.Emit(models.EventAgentCreated, agent)
+		case "delete_agent":
+			return s.Emit(models.EventAgentDeleted, agent)
+		}
+	}
+
+	return nil
+}
+
+// eventHandler implements sarama.ConsumerGroupHandler for the webhook topics
+type eventHandler struct {
+	processMessage func(topic, key string, value []byte) error
+}
+
+func (h *eventHandler) Setup(session sarama.ConsumerGroupSession) error {
+	slog.Info("Webhook consumer group handler setup", "member_id", session.MemberID(), "generation_id", session.GenerationID())
+	return nil
+}
+
+func (h *eventHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	slog.Info("Webhook consumer group handler cleanup")
+	return nil
+}
+
+func (h *eventHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		if err := h.processMessage(message.Topic, string(message.Key), message.Value); err != nil {
+			slog.ErrorContext(database.MessageContext(message), "Failed to process webhook event", "topic", message.Topic, "key", string(message.Key), "error", err)
+		}
+		session.MarkMessage(message, "")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package webhooks
+
+import (
+	"call-center-api/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	service WebhookService
+}
+
+func NewWebhookHandler(service WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) CreateSubscription(c *fiber.Ctx) error {
+	var req models.WebhookSubscriptionRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	subscription, err := h.service.CreateSubscription(req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to create webhook subscription",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.Status(201).JSON(models.Response{
+		Success: true,
+		Message: "Webhook subscription created successfully",
+		Data:    subscription,
+	})
+}
+
+func (h *WebhookHandler) UpdateSubscription(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid subscription ID",
+		})
+	}
+
+	var req models.WebhookSubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	subscription, err := h.service.UpdateSubscription(uint(id), req)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to update webhook subscription",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Webhook subscription updated successfully",
+		Data:    subscription,
+	})
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *fiber.Ctx) error {
+	subscriptions, err := h.service.ListSubscriptions()
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch webhook subscriptions",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    subscriptions,
+	})
+}
+
+func (h *WebhookHandler) DeleteSubscription(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid subscription ID",
+		})
+	}
+
+	if err := h.service.DeleteSubscription(uint(id)); err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Webhook subscription not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Webhook subscription deleted successfully",
+	})
+}
+
+func (h *WebhookHandler) ListDeliveries(c *fiber.Ctx) error {
+	subscriptionID, _ := strconv.ParseUint(c.Query("subscription_id"), 10, 64)
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	deliveries, err := h.service.ListDeliveries(uint(subscriptionID), c.Query("status"), limit)
+	if err != nil {
+		return c.Status(500).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Failed to fetch webhook deliveries",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Invalid delivery ID",
+		})
+	}
+
+	delivery, err := h.service.Redeliver(uint(id))
+	if err != nil {
+		return c.Status(404).JSON(models.ErrorResponse{
+			Success: false,
+			Message: "Webhook delivery not found",
+			Error:   err.Error(),
+		})
+	}
+
+	return c.JSON(models.Response{
+		Success: true,
+		Message: "Webhook delivery scheduled for redelivery",
+		Data:    delivery,
+	})
+}
@@ -0,0 +1,6 @@
+package webhooks
+
+// Repository interface for webhooks
+type Repository interface {
+	// Add any database operations here if needed in future
+}
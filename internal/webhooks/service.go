@@ -0,0 +1,380 @@
+package webhooks
+
+import (
+	"bytes"
+	"call-center-api/models"
+	"call-center-api/pkg/database"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// pollInterval is how often the dispatcher looks for due deliveries
+	pollInterval = 2 * time.Second
+	// claimBatchSize caps deliveries claimed per poll
+	claimBatchSize = 50
+	// claimLease keeps a claimed delivery away from other dispatchers while it is in flight
+	claimLease = time.Minute
+	// baseBackoff and maxBackoff bound the exponential retry delay
+	baseBackoff = 5 * time.Second
+	maxBackoff  = time.Hour
+)
+
+// validEventTypes lists the events a subscription may ask for
+var validEventTypes = map[string]bool{
+	models.EventCallAssigned:  true,
+	models.EventCallCompleted: true,
+	models.EventCallAbandoned: true,
+	models.EventAgentCreated:  true,
+	models.EventAgentDeleted:  true,
+	models.EventAlertFired:    true,
+	models.EventAlertResolved: true,
+}
+
+type WebhookService interface {
+	CreateSubscription(req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	UpdateSubscription(id uint, req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error)
+	ListSubscriptions() ([]models.WebhookSubscription, error)
+	DeleteSubscription(id uint) error
+	ListDeliveries(subscriptionID uint, status string, limit int) ([]models.WebhookDelivery, error)
+	Redeliver(id uint) (*models.WebhookDelivery, error)
+	Emit(eventType string, data interface{}) error
+	StartConsumer(ctx context.Context) error
+	StartDispatcher(ctx context.Context)
+}
+
+type webhookService struct {
+	db          *gorm.DB
+	consumer    *database.KafkaConsumer
+	client      *http.Client
+	maxAttempts int
+}
+
+func NewWebhookService(db *gorm.DB, consumer *database.KafkaConsumer, timeout time.Duration, maxAttempts int) WebhookService {
+	return &webhookService{
+		db:          db,
+		consumer:    consumer,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (s *webhookService) CreateSubscription(req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	if err := validateSubscription(req); err != nil {
+		return nil, err
+	}
+
+	subscription := &models.WebhookSubscription{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     req.Secret,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+	}
+
+	if err := s.db.Create(subscription).Error; err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (s *webhookService) UpdateSubscription(id uint, req models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, id).Error; err != nil {
+		return nil, errors.New("subscription not found")
+	}
+
+	// Keep the existing secret when none is supplied
+	if req.Secret == "" {
+		req.Secret = subscription.Secret
+	}
+	if err := validateSubscription(req); err != nil {
+		return nil, err
+	}
+
+	subscription.URL = req.URL
+	subscription.EventTypes = req.EventTypes
+	subscription.Secret = req.Secret
+	if req.IsActive != nil {
+		subscription.IsActive = *req.IsActive
+	}
+
+	if err := s.db.Save(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (s *webhookService) ListSubscriptions() ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Order("created_at DESC").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (s *webhookService) DeleteSubscription(id uint) error {
+	result := s.db.Delete(&models.WebhookSubscription{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("subscription not found")
+	}
+	return nil
+}
+
+func (s *webhookService) ListDeliveries(subscriptionID uint, status string, limit int) ([]models.WebhookDelivery, error) {
+	query := s.db.Order("created_at DESC").Limit(limit)
+	if subscriptionID != 0 {
+		query = query.Where("subscription_id = ?", subscriptionID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver resets a delivery so the dispatcher sends it again with a fresh retry budget
+func (s *webhookService) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := s.db.First(&delivery, id).Error; err != nil {
+		return nil, errors.New("delivery not found")
+	}
+
+	now := time.Now()
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.LastError = ""
+	delivery.NextAttemptAt = &now
+
+	if err := s.db.Save(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// Emit records a pending delivery for every active subscription interested in eventType
+func (s *webhookService) Emit(eventType string, data interface{}) error {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Where("is_active = ?", true).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	event := models.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		if !subscribesTo(subscription, eventType) {
+			continue
+		}
+
+		delivery := models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      eventType,
+			Payload:        string(payload),
+			Status:         models.DeliveryPending,
+			NextAttemptAt:  &now,
+		}
+		if err := s.db.Create(&delivery).Error; err != nil {
+			slog.Error("Failed to record webhook delivery", "subscription_id", subscription.ID, "event_type", eventType, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// StartDispatcher delivers due webhooks until ctx is canceled
+func (s *webhookService) StartDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deliveries, err := s.claimDueDeliveries()
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to claim webhook deliveries", "error", err)
+				continue
+			}
+			for _, delivery := range deliveries {
+				s.attempt(ctx, delivery)
+			}
+		}
+	}
+}
+
+// claimDueDeliveries locks due deliveries and pushes their next attempt out by claimLease,
+// so several dispatchers can run without sending the same delivery twice
+func (s *webhookService) claimDueDeliveries() ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(claimBatchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(claimLease)).Error
+	})
+
+	return deliveries, err
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry with exponential backoff
+func (s *webhookService) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, delivery.SubscriptionID).Error; err != nil {
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = "subscription no longer exists"
+		delivery.NextAttemptAt = nil
+		s.db.Save(&delivery)
+		return
+	}
+
+	statusCode, err := s.send(ctx, subscription, delivery)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	now := time.Now()
+
+	if err == nil {
+		delivery.Status = models.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= s.maxAttempts {
+			delivery.Status = models.DeliveryFailed
+			delivery.NextAttemptAt = nil
+			slog.Warn("Webhook delivery failed permanently", "delivery_id", delivery.ID, "url", subscription.URL, "attempts", delivery.Attempts, "error", err)
+		} else {
+			next := now.Add(backoff(delivery.Attempts))
+			delivery.NextAttemptAt = &next
+		}
+	}
+
+	if err := s.db.Save(&delivery).Error; err != nil {
+		slog.Error("Failed to update webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the payload signed with HMAC-SHA256 over "<timestamp>.<body>"
+func (s *webhookService) send(ctx context.Context, subscription models.WebhookSubscription, delivery models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", sign(subscription.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the delay after each failed attempt, capped at maxBackoff
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+func subscribesTo(subscription models.WebhookSubscription, eventType string) bool {
+	for _, t := range subscription.EventTypes {
+		if t == eventType || t == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSubscription(req models.WebhookSubscriptionRequest) error {
+	if !strings.HasPrefix(req.URL, "http://") && !strings.HasPrefix(req.URL, "https://") {
+		return errors.New("url must be http or https")
+	}
+	if req.Secret == "" {
+		return errors.New("secret is required")
+	}
+	if len(req.EventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	for _, t := range req.EventTypes {
+		if t != "*" && !validEventTypes[t] {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: baseBackoff},
+		{attempts: 1, want: baseBackoff},
+		{attempts: 2, want: 2 * baseBackoff},
+		{attempts: 3, want: 4 * baseBackoff},
+		{attempts: 10, want: 512 * baseBackoff},
+		{attempts: 11, want: maxBackoff},
+		{attempts: 100, want: maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
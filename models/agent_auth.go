@@ -6,16 +6,34 @@ import (
 	"gorm.io/gorm"
 )
 
-// Agent represents an agent in the system
+// Agent routing states
+const (
+	AgentStateAvailable = "available"
+	AgentStateWrapUp    = "wrap_up"
+	AgentStateAux       = "aux"
+	// AgentStateAuxOverrun is an aux code past its maximum duration. The agent stays out of
+	// routing until they make themselves available.
+	AgentStateAuxOverrun = "aux_overrun"
+)
+
+// Agent represents an agent in the system. MaxConcurrentCalls caps simultaneous calls;
+// zero falls back to the queue's agent_capacity. Only available agents are routed calls;
+// StateUntil is when a timed state such as wrap-up or a capped aux code ends. Team groups
+// agents for reporting.
 type Agent struct {
-	ID        string         `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"not null" json:"name"`
-	Password  string         `gorm:"not null" json:"-"`
-	IsAdmin   bool           `gorm:"default:false" json:"is_admin"`
-	IsActive  bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 string         `gorm:"primaryKey" json:"id"`
+	Name               string         `gorm:"not null" json:"name"`
+	Password           string         `gorm:"not null" json:"-"`
+	IsAdmin            bool           `gorm:"default:false" json:"is_admin"`
+	IsActive           bool           `gorm:"default:true" json:"is_active"`
+	Team               string         `gorm:"index" json:"team,omitempty"`
+	MaxConcurrentCalls int            `gorm:"not null;default:0" json:"max_concurrent_calls"`
+	State              string         `gorm:"not null;default:available" json:"state"`
+	AuxCode            string         `json:"aux_code,omitempty"`
+	StateUntil         *time.Time     `json:"state_until,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // LoginRequest represents agent login request
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session end reasons
+const (
+	SessionEndLogout     = "logout"
+	SessionEndDisconnect = "disconnect"
+	SessionEndExpired    = "expired"
+)
+
+// AgentSession is one sign-in of an agent. It starts at login and ends on logout, token
+// expiry, or when the session has no WebSocket open for longer than the grace period. Agents
+// are only routed calls while they have a session that has not ended and has a WebSocket
+// open to receive them.
+type AgentSession struct {
+	ID             string     `gorm:"primaryKey" json:"id"`
+	AgentID        string     `gorm:"index;not null" json:"agent_id"`
+	StartedAt      time.Time  `gorm:"index;not null" json:"started_at"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	ConnectedAt    *time.Time `json:"connected_at,omitempty"`
+	DisconnectedAt *time.Time `json:"disconnected_at,omitempty"`
+	// Connections counts the session's open WebSockets; HeartbeatAt is refreshed while any is open
+	Connections int        `gorm:"not null;default:0" json:"connections"`
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty"`
+	EndedAt     *time.Time `gorm:"index" json:"ended_at,omitempty"`
+	EndReason   string     `json:"end_reason,omitempty"`
+}
+
+// LiveSession scopes an agents query to agents with a session that has neither ended nor
+// expired and has a WebSocket open
+func LiveSession(db *gorm.DB) *gorm.DB {
+	return db.Where(`EXISTS (SELECT 1 FROM agent_sessions
+		WHERE agent_sessions.agent_id = agents.id
+		AND agent_sessions.ended_at IS NULL
+		AND agent_sessions.expires_at > ?
+		AND agent_sessions.connections > 0)`, time.Now())
+}
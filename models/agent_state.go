@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// AuxCode is an admin-defined reason for not taking calls (break, training, admin work).
+// MaxDurationSeconds caps how long an agent may stay in it; zero means no cap.
+type AuxCode struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Code               string    `gorm:"uniqueIndex;not null" json:"code"`
+	Name               string    `gorm:"not null" json:"name"`
+	MaxDurationSeconds int       `gorm:"not null;default:0" json:"max_duration_seconds"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// AgentStateInterval records one span of time an agent spent in a state. EndedAt is nil for
+// the current state; CappedAt is set when an aux code's maximum duration ended the interval.
+type AgentStateInterval struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	AgentID   string     `gorm:"index;not null" json:"agent_id"`
+	State     string     `gorm:"not null" json:"state"`
+	AuxCode   string     `json:"aux_code,omitempty"`
+	StartedAt time.Time  `gorm:"index;not null" json:"started_at"`
+	EndedAt   *time.Time `gorm:"index" json:"ended_at,omitempty"`
+	CappedAt  *time.Time `json:"capped_at,omitempty"`
+}
+
+func (AgentStateInterval) TableName() string {
+	return "agent_state_history"
+}
+
+// SetAgentStateRequest represents an agent changing their own state
+type SetAgentStateRequest struct {
+	State   string `json:"state"`
+	AuxCode string `json:"aux_code"`
+}
+
+// AgentStateReport summarizes how an agent spent a reporting period. LoggedInSeconds is time
+// on system across sign-in sessions. Occupancy is the share
+// of staffed time (available + wrap-up) spent handling calls or in wrap-up; adherence is the
+// share of aux intervals the agent ended within the code's cap.
+type AgentStateReport struct {
+	AgentID          string           `json:"agent_id"`
+	LoggedInSeconds  int64            `json:"logged_in_seconds"`
+	AvailableSeconds int64            `json:"available_seconds"`
+	WrapUpSeconds    int64            `json:"wrap_up_seconds"`
+	AuxSeconds       int64            `json:"aux_seconds"`
+	AuxByCode        map[string]int64 `json:"aux_by_code"`
+	HandleSeconds    int64            `json:"handle_seconds"`
+	Occupancy        float64          `json:"occupancy"`
+	AuxIntervals     int64            `json:"aux_intervals"`
+	AuxCapped        int64            `json:"aux_capped"`
+	Adherence        float64          `json:"adherence"`
+}
+
+// AgentStats aggregates an agent's calls over a reporting period. Handle time runs from
+// assignment to completion, wait time from arrival to assignment. Occupancy is handle plus
+// wrap-up time over staffed (available + wrap-up) time.
+type AgentStats struct {
+	AgentID          string  `json:"agent_id"`
+	AgentName        string  `json:"agent_name"`
+	Team             string  `json:"team,omitempty"`
+	Status           string  `json:"status"`
+	TotalCalls       int64   `json:"total_calls"`
+	CompletedCalls   int64   `json:"completed_calls"`
+	AbandonedCalls   int64   `json:"abandoned_calls"`
+	MissedCalls      int64   `json:"missed_calls"`
+	AvgHandleSeconds float64 `json:"avg_handle_seconds"`
+	AvgWaitSeconds   float64 `json:"avg_wait_seconds"`
+	Occupancy        float64 `json:"occupancy"`
+	// Inputs to Occupancy, see customeragent.occupancy
+	HandleSeconds  float64 `json:"-"`
+	WrapUpSeconds  float64 `json:"-"`
+	StaffedSeconds float64 `json:"-"`
+}
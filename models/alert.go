@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Alert types
+const (
+	AlertTypeSLABreach = "sla_breach"
+	AlertTypeMaxWait   = "max_wait"
+)
+
+// Alert actions, used as the key prefix on the alerts topic
+const (
+	AlertFired    = "fired"
+	AlertResolved = "resolved"
+)
+
+// Alert is a breached threshold. Key identifies the condition (e.g. "sla_breach:sales" or
+// "max_wait:<call_id>") and at most one alert per key is open, i.e. has no ResolvedAt.
+// Value is what was observed when it fired: service level as a fraction for SLA breaches,
+// seconds waited for max wait; Threshold is the limit it crossed in the same unit.
+type Alert struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Key        string     `gorm:"not null;uniqueIndex:idx_alerts_open_key,where:resolved_at IS NULL" json:"key"`
+	Type       string     `gorm:"not null;index" json:"type"`
+	Queue      string     `json:"queue"`
+	CallID     string     `json:"call_id,omitempty"`
+	Value      float64    `json:"value"`
+	Threshold  float64    `json:"threshold"`
+	Message    string     `json:"message"`
+	FiredAt    time.Time  `gorm:"index" json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
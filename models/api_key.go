@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey represents a credential issued to a telephony integration
+type APIKey struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"not null" json:"name"`
+	Integration string         `gorm:"not null;index" json:"integration"`
+	Prefix      string         `gorm:"not null" json:"prefix"`
+	KeyHash     string         `gorm:"uniqueIndex;not null" json:"-"`
+	LastUsedAt  *time.Time     `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CreateAPIKeyRequest represents an API key issue request
+type CreateAPIKeyRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Integration string `json:"integration" validate:"required"`
+}
+
+// CreateAPIKeyResponse carries the plaintext key, which is only shown once
+type CreateAPIKeyResponse struct {
+	APIKey *APIKey `json:"api_key"`
+	Key    string  `json:"key"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Blocklist match types
+const (
+	BlockMatchExact  = "exact"
+	BlockMatchPrefix = "prefix"
+)
+
+// Reason codes of blocked calls; the status reason carries the human-readable detail
+const (
+	BlockReasonBlocklist = "blocklist"
+	BlockReasonFlood     = "flood"
+)
+
+// BlockedNumber is an admin-managed rule that rejects calls from matching numbers
+type BlockedNumber struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Number    string         `gorm:"not null;index" json:"number"`
+	MatchType string         `gorm:"not null;default:exact" json:"match_type"`
+	Reason    string         `json:"reason"`
+	ExpiresAt *time.Time     `json:"expires_at,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CreateBlockedNumberRequest represents a blocklist entry request
+type CreateBlockedNumberRequest struct {
+	Number    string     `json:"number" validate:"required"`
+	MatchType string     `json:"match_type"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// BlockedCallStat counts blocked calls for one reason code
+type BlockedCallStat struct {
+	Reason string `json:"reason"` // blocklist or flood
+	Count  int64  `json:"count"`
+}
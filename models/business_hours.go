@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// DefaultQueue is the queue used when a call does not name one
+const DefaultQueue = "default"
+
+// After-hours behaviours
+const (
+	AfterHoursCallback  = "callback"
+	AfterHoursVoicemail = "voicemail"
+	AfterHoursReject    = "reject"
+)
+
+// BusinessHours defines when a queue accepts calls and what happens outside those hours
+type BusinessHours struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	Queue            string     `gorm:"uniqueIndex;not null" json:"queue"`
+	Timezone         string     `gorm:"not null;default:UTC" json:"timezone"`
+	Days             []DayHours `gorm:"serializer:json" json:"days"`
+	AfterHoursAction string     `gorm:"not null;default:reject" json:"after_hours_action"`
+	AfterHoursReason string     `json:"after_hours_reason,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// DayHours is one opening interval on a weekday (0 = Sunday) in "15:04" local time.
+// A weekday may have several intervals, e.g. around a lunch break.
+type DayHours struct {
+	Weekday int    `json:"weekday"`
+	Open    string `json:"open"`
+	Close   string `json:"close"`
+}
+
+// Holiday closes a queue for a whole local date; an empty Queue applies to every queue
+type Holiday struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Queue     string    `gorm:"index" json:"queue"`
+	Date      string    `gorm:"index;not null" json:"date"` // YYYY-MM-DD
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QueueOpenState reports whether a queue is currently accepting calls
+type QueueOpenState struct {
+	Queue    string     `json:"queue"`
+	Open     bool       `json:"open"`
+	Waiting  int64      `json:"waiting"`
+	Timezone string     `json:"timezone,omitempty"`
+	Holiday  string     `json:"holiday,omitempty"`
+	NextOpen *time.Time `json:"next_open,omitempty"`
+}
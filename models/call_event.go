@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Call timeline event types
+const (
+	CallEventQueued     = "queued"
+	CallEventOverflowed = "overflowed"
+	CallEventAssigned   = "assigned"
+)
+
+// CallEvent is one entry on a call's timeline. Outcomes other than the types above
+// (abandoned, dropped, after-hours actions) use the call status as their type.
+type CallEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CallID    string    `gorm:"index;not null" json:"call_id"`
+	Type      string    `gorm:"index;not null" json:"type"`
+	Queue     string    `json:"queue,omitempty"`
+	FromQueue string    `json:"from_queue,omitempty"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -6,21 +6,96 @@ import (
 	"gorm.io/gorm"
 )
 
-// IncomingCall represents a call received by the call center
+// Call statuses stored on AssignedCall
+const (
+	CallStatusAssigned  = "assigned"
+	CallStatusCompleted = "completed"
+	CallStatusBlocked   = "blocked"
+	CallStatusAbandoned = "abandoned"
+	CallStatusDropped   = "dropped"
+	CallStatusQueued    = "queued"
+	// CallStatusMissed is the disposition an agent reports when they did not pick up
+	CallStatusMissed = "missed"
+	// After-hours outcomes
+	CallStatusCallbackScheduled = "callback_scheduled"
+	CallStatusVoicemail         = "voicemail"
+	CallStatusRejected          = "rejected"
+)
+
+// How the distributor chose the agent, stored in AssignedCall.RoutedBy
+const (
+	RoutedByPreferred   = "preferred_agent"
+	RoutedByAffinity    = "affinity"
+	RoutedByRoundRobin  = StrategyRoundRobin
+	RoutedByLongestIdle = StrategyLongestIdle
+	RoutedByWeighted    = StrategyWeighted
+)
+
+// IncomingCall represents a call received by the call center. PreferredAgentID is internal:
+// only callbacks set it, and ingestion handlers clear any value a client sends. RawNumber is
+// always derived from CustomerNumber on ingestion.
 type IncomingCall struct {
-	CallID         string    `json:"call_id"`
-	CustomerNumber string    `json:"customer_number"`
-	Timestamp      time.Time `json:"timestamp"`
+	CallID           string    `json:"call_id"`
+	CustomerNumber   string    `json:"customer_number"`
+	RawNumber        string    `json:"raw_number,omitempty"`
+	Queue            string    `json:"queue,omitempty"`
+	CalledNumber     string    `json:"called_number,omitempty"`
+	Direction        string    `json:"direction,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	PreferredAgentID string    `json:"preferred_agent_id,omitempty"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// BatchCallResult reports the outcome of one item in a batch ingestion request
+type BatchCallResult struct {
+	Index   int    `json:"index"`
+	CallID  string `json:"call_id,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// BatchCallResponse summarizes a batch ingestion request
+type BatchCallResponse struct {
+	Total    int               `json:"total"`
+	Accepted int               `json:"accepted"`
+	Failed   int               `json:"failed"`
+	Results  []BatchCallResult `json:"results"`
+}
+
+// CallStatus is the ingestion-side view of where a call is in its lifecycle
+type CallStatus struct {
+	CallID               string     `json:"call_id"`
+	Queue                string     `json:"queue,omitempty"`
+	Status               string     `json:"status"`
+	StatusReason         string     `json:"status_reason,omitempty"`
+	QueuePosition        *int64     `json:"queue_position,omitempty"`
+	EstimatedWaitSeconds *int64     `json:"estimated_wait_seconds,omitempty"`
+	AssignedAgentID      string     `json:"assigned_agent_id,omitempty"`
+	ReceivedAt           *time.Time `json:"received_at,omitempty"`
+	AssignedAt           *time.Time `json:"assigned_at,omitempty"`
+	AbandonedAt          *time.Time `json:"abandoned_at,omitempty"`
 }
 
 // AssignedCall represents a call assigned to an agent
 type AssignedCall struct {
 	ID              uint           `gorm:"primaryKey" json:"id"`
 	CallID          string         `gorm:"uniqueIndex;not null" json:"call_id"`
-	CustomerNumber  string         `gorm:"not null" json:"customer_number"`
+	CustomerNumber  string         `gorm:"not null;index" json:"customer_number"`
+	RawNumber       string         `json:"raw_number,omitempty"`
+	Queue           string         `gorm:"index" json:"queue"`
+	OriginalQueue   string         `json:"original_queue,omitempty"`
+	OverflowCount   int            `json:"overflow_count,omitempty"`
+	ReceivedAt      time.Time      `gorm:"index" json:"received_at"`
 	Timestamp       time.Time      `json:"timestamp"`
 	AssignedAgentID string         `gorm:"not null" json:"assigned_agent_id"`
-	Status          string         `json:"status"`
+	RoutedBy        string         `json:"routed_by,omitempty"`
+	AffinityAgentID string         `json:"affinity_agent_id,omitempty"`
+	Status          string         `gorm:"index" json:"status"`
+	StatusReason    string         `json:"status_reason,omitempty"`
+	ReasonCode      string         `gorm:"index" json:"reason_code,omitempty"` // stable code for StatusReason, e.g. flood
+	AbandonedAt     *time.Time     `json:"abandoned_at,omitempty"`
+	TimeToAbandon   int64          `json:"time_to_abandon_seconds,omitempty"`
 	Notes           string         `json:"notes"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Callback statuses
+const (
+	CallbackScheduled  = "scheduled"
+	CallbackDispatched = "dispatched"
+	CallbackCompleted  = "completed"
+	CallbackFailed     = "failed"
+	CallbackCanceled   = "canceled"
+)
+
+// DispositionCallbackNeeded is the CompleteCall status an agent uses to request a callback
+const DispositionCallbackNeeded = "callback-needed"
+
+// Callback is a request to call a customer back within a preferred window
+type Callback struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	CustomerNumber   string         `gorm:"not null;index" json:"customer_number"`
+	RawNumber        string         `json:"raw_number,omitempty"`
+	Queue            string         `json:"queue"`
+	Reason           string         `json:"reason"`
+	WindowStart      time.Time      `json:"window_start"`
+	WindowEnd        *time.Time     `json:"window_end,omitempty"`
+	PreferredAgentID string         `json:"preferred_agent_id,omitempty"`
+	OriginCallID     string         `gorm:"index" json:"origin_call_id,omitempty"`
+	Status           string         `gorm:"index;not null" json:"status"`
+	Attempts         int            `json:"attempts"`
+	NextAttemptAt    *time.Time     `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastCallID       string         `gorm:"index" json:"last_call_id,omitempty"`
+	DispatchedAt     *time.Time     `json:"dispatched_at,omitempty"`
+	LastError        string         `json:"last_error,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CreateCallbackRequest represents a customer callback request
+type CreateCallbackRequest struct {
+	CustomerNumber   string     `json:"customer_number" validate:"required"`
+	Queue            string     `json:"queue"`
+	Reason           string     `json:"reason"`
+	WindowStart      *time.Time `json:"window_start"`
+	WindowEnd        *time.Time `json:"window_end"`
+	PreferredAgentID string     `json:"preferred_agent_id"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Forecast methods
+const (
+	ForecastSeasonalNaive = "seasonal_naive"
+	ForecastMovingAverage = "moving_average"
+)
+
+// ForecastParams configures a staffing forecast for one UTC day. Volume for each interval
+// comes from the same interval in past weeks: seasonal naive repeats last week, moving
+// average takes the mean of the last Weeks weeks. Shrinkage is the share of paid time agents
+// are unavailable (breaks, training) and inflates required agents into scheduled agents.
+type ForecastParams struct {
+	Queue            string
+	Date             time.Time
+	Method           string
+	Weeks            int
+	ServiceLevel     float64
+	TargetSeconds    int
+	TargetASASeconds float64
+	Shrinkage        float64
+}
+
+// ForecastInterval is the predicted load and staffing for one 15-minute interval. Required
+// agents is the smallest Erlang C staffing that meets both the service level and ASA targets;
+// ServiceLevel and ASASeconds are what that staffing is expected to achieve; ASASeconds is null
+// when the load is beyond any staffing the search considers.
+type ForecastInterval struct {
+	IntervalStart   time.Time `json:"interval_start"`
+	ForecastCalls   float64   `json:"forecast_calls"`
+	AHTSeconds      float64   `json:"aht_seconds"`
+	TrafficErlangs  float64   `json:"traffic_erlangs"`
+	RequiredAgents  int       `json:"required_agents"`
+	ScheduledAgents int       `json:"scheduled_agents"`
+	ServiceLevel    float64   `json:"service_level"`
+	ASASeconds      *float64  `json:"asa_seconds"`
+}
+
+// Forecast is a day of interval forecasts with the targets they were sized for. Weeks is the
+// number of past weeks that had rollups and went into the average.
+type Forecast struct {
+	Queue              string             `json:"queue,omitempty"`
+	Date               string             `json:"date"`
+	Method             string             `json:"method"`
+	Weeks              int                `json:"weeks"`
+	TargetServiceLevel float64            `json:"target_service_level"`
+	TargetSeconds      int                `json:"target_seconds"`
+	TargetASASeconds   float64            `json:"target_asa_seconds,omitempty"`
+	Shrinkage          float64            `json:"shrinkage"`
+	TotalCalls         float64            `json:"total_calls"`
+	PeakAgents         int                `json:"peak_agents"`
+	Intervals          []ForecastInterval `json:"intervals"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Report granularities
+const (
+	GranularityInterval = "15m"
+	GranularityDay      = "day"
+)
+
+// IntervalStat is the rollup of calls that arrived in one 15-minute interval, per queue and
+// agent. Calls that never reached an agent are rolled up with an empty AgentID. Sums are
+// stored instead of averages so intervals can be added up into longer periods.
+type IntervalStat struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	IntervalStart     time.Time `gorm:"uniqueIndex:idx_interval_queue_agent;not null" json:"interval_start"`
+	Queue             string    `gorm:"uniqueIndex:idx_interval_queue_agent;not null" json:"queue"`
+	AgentID           string    `gorm:"uniqueIndex:idx_interval_queue_agent;not null;index" json:"agent_id"`
+	Offered           int64     `json:"offered"`
+	Answered          int64     `json:"answered"`
+	Abandoned         int64     `json:"abandoned"`
+	AnsweredInTarget  int64     `json:"answered_in_target"`
+	Handled           int64     `json:"handled"`
+	HandleSeconds     float64   `json:"handle_seconds"`
+	AnswerWaitSeconds float64   `json:"answer_wait_seconds"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// IntervalReportRow is one period of a historical report. AHT is average handle time of
+// completed calls, ASA average speed of answer; service level is answered in target over
+// answered plus abandoned.
+type IntervalReportRow struct {
+	PeriodStart      time.Time `json:"period_start"`
+	Queue            string    `json:"queue"`
+	AgentID          string    `json:"agent_id,omitempty"`
+	Offered          int64     `json:"offered"`
+	Answered         int64     `json:"answered"`
+	Abandoned        int64     `json:"abandoned"`
+	AnsweredInTarget int64     `json:"answered_in_target"`
+	AHTSeconds       float64   `json:"aht_seconds"`
+	ASASeconds       float64   `json:"asa_seconds"`
+	ServiceLevel     float64   `json:"service_level"`
+}
+
+// IntervalReportFilter selects and groups rollups. Rows are grouped per queue, and also
+// per agent when ByAgent is set or an agent is selected.
+type IntervalReportFilter struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+	Queue       string
+	AgentID     string
+	ByAgent     bool
+}
+
+// BackfillRequest asks for rollups to be rebuilt for a past period
+type BackfillRequest struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// BackfillResult reports what a backfill rebuilt
+type BackfillResult struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Intervals int       `json:"intervals"`
+	Rows      int64     `json:"rows"`
+}
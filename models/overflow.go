@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// OverflowRule moves waiting calls out of Queue into TargetQueue once either threshold is crossed.
+// Rules for a queue are evaluated in Position order.
+type OverflowRule struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Queue          string    `gorm:"index;not null" json:"queue"`
+	TargetQueue    string    `gorm:"not null" json:"target_queue"`
+	MaxWaitSeconds int       `json:"max_wait_seconds,omitempty"`
+	MaxQueueSize   int       `json:"max_queue_size,omitempty"`
+	KeepPosition   bool      `json:"keep_position"`
+	Position       int       `gorm:"not null;default:0" json:"position"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// OverflowStat counts calls that overflowed from one queue to another
+type OverflowStat struct {
+	FromQueue string `json:"from_queue"`
+	ToQueue   string `json:"to_queue"`
+	Count     int64  `json:"count"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Queue routing strategies
+const (
+	StrategyRoundRobin  = "round_robin"
+	StrategyLongestIdle = "longest_idle"
+	StrategyWeighted    = "weighted"
+)
+
+// Queue is a named line of calls (e.g. sales, support, billing) served by its member agents.
+// AgentCapacity is the default limit on simultaneous calls for agents without their own.
+// WrapUpSeconds is the after-call work period, which DispositionWrapUp can override per
+// CompleteCall status. Service level is measured over the last SLAWindowMinutes; a call
+// waiting longer than MaxWaitSeconds raises an alert (zero disables it).
+type Queue struct {
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	Name              string         `gorm:"uniqueIndex;not null" json:"name"`
+	Description       string         `json:"description"`
+	Strategy          string         `gorm:"not null;default:round_robin" json:"strategy"`
+	Priority          int            `gorm:"not null;default:0" json:"priority"`
+	SLATargetSeconds  int            `gorm:"not null;default:20" json:"sla_target_seconds"`
+	SLATargetPercent  float64        `gorm:"not null;default:80" json:"sla_target_percent"`
+	SLAWindowMinutes  int            `gorm:"not null;default:30" json:"sla_window_minutes"`
+	MaxWaitSeconds    int            `gorm:"not null;default:0" json:"max_wait_seconds"`
+	AgentCapacity     int            `gorm:"not null;default:0" json:"agent_capacity"`
+	WrapUpSeconds     int            `gorm:"not null;default:0" json:"wrap_up_seconds"`
+	DispositionWrapUp map[string]int `gorm:"serializer:json" json:"disposition_wrap_up,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+}
+
+// UpdateQueueRequest is a partial queue update; fields left out of the request keep their
+// current value, and pointer fields can be set to zero
+type UpdateQueueRequest struct {
+	Description       string         `json:"description"`
+	Strategy          string         `json:"strategy"`
+	Priority          *int           `json:"priority"`
+	SLATargetSeconds  *int           `json:"sla_target_seconds"`
+	SLATargetPercent  *float64       `json:"sla_target_percent"`
+	SLAWindowMinutes  *int           `json:"sla_window_minutes"`
+	MaxWaitSeconds    *int           `json:"max_wait_seconds"`
+	AgentCapacity     *int           `json:"agent_capacity"`
+	WrapUpSeconds     *int           `json:"wrap_up_seconds"`
+	DispositionWrapUp map[string]int `json:"disposition_wrap_up"`
+}
+
+// QueueMembership puts an agent in a queue; higher weights receive more calls under the weighted strategy
+type QueueMembership struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Queue     string    `gorm:"uniqueIndex:idx_queue_agent;not null" json:"queue"`
+	AgentID   string    `gorm:"uniqueIndex:idx_queue_agent;index;not null" json:"agent_id"`
+	Weight    int       `gorm:"not null;default:1" json:"weight"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QueueMembershipRequest represents a membership create/update request
+type QueueMembershipRequest struct {
+	Weight int `json:"weight"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Wallboard-only agent states, derived from open calls and sign-in sessions
+const (
+	WallboardStateOnCall  = "on_call"
+	WallboardStateOffline = "offline"
+)
+
+// Wallboard is a live view of the contact center for supervisors. AgentsByState counts
+// signed-in agents by routing state, with available agents holding a call shown as on_call
+// and agents without a live session as offline. Alerts lists the open alerts.
+type Wallboard struct {
+	GeneratedAt        time.Time            `json:"generated_at"`
+	CallsWaiting       int                  `json:"calls_waiting"`
+	LongestWaitSeconds int64                `json:"longest_wait_seconds"`
+	AgentsByState      map[string]int       `json:"agents_by_state"`
+	ServiceLevel       []ServiceLevelWindow `json:"service_level"`
+	Queues             []QueueWallboard     `json:"queues"`
+	Alerts             []Alert              `json:"alerts"`
+}
+
+// QueueWallboard is one queue's share of the wallboard
+type QueueWallboard struct {
+	Queue              string               `json:"queue"`
+	CallsWaiting       int                  `json:"calls_waiting"`
+	LongestWaitSeconds int64                `json:"longest_wait_seconds"`
+	SLATargetSeconds   int                  `json:"sla_target_seconds"`
+	ServiceLevel       []ServiceLevelWindow `json:"service_level"`
+}
+
+// ServiceLevelWindow covers calls that stopped waiting in the last WindowMinutes. A call is
+// answered in target when an agent was assigned within its queue's SLA target; service level
+// is answered in target over answered plus abandoned.
+type ServiceLevelWindow struct {
+	WindowMinutes    int     `json:"window_minutes"`
+	Answered         int     `json:"answered"`
+	AnsweredInTarget int     `json:"answered_in_target"`
+	Abandoned        int     `json:"abandoned"`
+	ServiceLevel     float64 `json:"service_level"`
+}
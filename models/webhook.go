@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outbound webhook event types
+const (
+	EventCallAssigned  = "call.assigned"
+	EventCallCompleted = "call.completed"
+	EventCallAbandoned = "call.abandoned"
+	EventAgentCreated  = "agent.created"
+	EventAgentDeleted  = "agent.deleted"
+	EventAlertFired    = "alert.fired"
+	EventAlertResolved = "alert.resolved"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is an admin-configured endpoint that receives lifecycle events
+type WebhookSubscription struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	URL        string         `gorm:"not null" json:"url"`
+	EventTypes []string       `gorm:"serializer:json" json:"event_types"`
+	Secret     string         `gorm:"not null" json:"-"`
+	IsActive   bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// WebhookSubscriptionRequest represents a subscription create/update request
+type WebhookSubscriptionRequest struct {
+	URL        string   `json:"url" validate:"required,url"`
+	EventTypes []string `json:"event_types" validate:"required"`
+	Secret     string   `json:"secret" validate:"required"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// WebhookEvent is the JSON envelope delivered to subscribers
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery logs one event sent to one subscription, including retries
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint       `gorm:"index;not null" json:"subscription_id"`
+	EventID        string     `gorm:"index;not null" json:"event_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"index;not null" json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
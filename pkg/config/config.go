@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +20,11 @@ type Config struct {
 	KafkaBrokers string
 	KafkaGroupID string
 
+	// Routing
+	AffinityWindow            time.Duration
+	DefaultAgentCapacity      int
+	CapacityReconcileInterval time.Duration
+
 	// Redis
 	RedisAddr     string
 	RedisPassword string
@@ -28,6 +35,46 @@ type Config struct {
 	// Admin
 	AdminPassword string
 
+	// Agent sessions
+	SessionDisconnectGrace time.Duration
+
+	// Phone numbers
+	PhoneDefaultRegion string
+
+	// Call screening
+	CallFloodLimit int
+	// CallFloodBatchLimit applies to batch ingestion instead of CallFloodLimit; 0 disables it
+	CallFloodBatchLimit int
+	CallFloodWindow     time.Duration
+
+	// Callbacks
+	CallbackMaxAttempts int
+	CallbackRetryDelay  time.Duration
+
+	// Webhooks
+	WebhookSecret       string
+	WebhookProfilesFile string
+	WebhookReplayWindow time.Duration
+
+	// Outbound webhooks
+	OutboundWebhookMaxAttempts int
+	OutboundWebhookTimeout     time.Duration
+
+	// Reporting rollups
+	RollupInterval time.Duration
+	RollupLookback time.Duration
+
+	// SLA alerts
+	AlertFireAfter    time.Duration
+	AlertResolveAfter time.Duration
+
+	// Logging
+	LogLevel  string
+	LogFormat string
+
+	// Tracing (OTLP/HTTP collector URL; tracing is off when empty)
+	OTLPEndpoint string
+
 	// Server Ports
 	CallCenterPort    string
 	CustomerAgentPort string
@@ -47,6 +94,10 @@ func Load() *Config {
 		KafkaBrokers: getEnv("KAFKA_BROKERS", "kafka:9092"),
 		KafkaGroupID: getEnv("KAFKA_GROUP_ID", "distributor-group"),
 
+		AffinityWindow:            getEnvDuration("AFFINITY_WINDOW", 24*time.Hour),
+		DefaultAgentCapacity:      getEnvInt("DEFAULT_AGENT_CAPACITY", 1),
+		CapacityReconcileInterval: getEnvDuration("CAPACITY_RECONCILE_INTERVAL", time.Minute),
+
 		RedisAddr:     getEnv("REDIS_ADDR", "redis:6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 
@@ -54,6 +105,35 @@ func Load() *Config {
 
 		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
 
+		SessionDisconnectGrace: getEnvDuration("SESSION_DISCONNECT_GRACE", 30*time.Second),
+
+		PhoneDefaultRegion: getEnv("PHONE_DEFAULT_REGION", "US"),
+
+		CallFloodLimit:      getEnvInt("CALL_FLOOD_LIMIT", 5),
+		CallFloodBatchLimit: getEnvInt("CALL_FLOOD_BATCH_LIMIT", 0),
+		CallFloodWindow:     getEnvDuration("CALL_FLOOD_WINDOW", time.Minute),
+
+		CallbackMaxAttempts: getEnvInt("CALLBACK_MAX_ATTEMPTS", 3),
+		CallbackRetryDelay:  getEnvDuration("CALLBACK_RETRY_DELAY", 15*time.Minute),
+
+		WebhookSecret:       getEnv("WEBHOOK_SECRET", ""),
+		WebhookProfilesFile: getEnv("WEBHOOK_PROFILES_FILE", ""),
+		WebhookReplayWindow: getEnvDuration("WEBHOOK_REPLAY_WINDOW", 5*time.Minute),
+
+		OutboundWebhookMaxAttempts: getEnvInt("OUTBOUND_WEBHOOK_MAX_ATTEMPTS", 8),
+		OutboundWebhookTimeout:     getEnvDuration("OUTBOUND_WEBHOOK_TIMEOUT", 10*time.Second),
+
+		RollupInterval: getEnvDuration("ROLLUP_INTERVAL", 5*time.Minute),
+		RollupLookback: getEnvDuration("ROLLUP_LOOKBACK", 24*time.Hour),
+
+		AlertFireAfter:    getEnvDuration("ALERT_FIRE_AFTER", time.Minute),
+		AlertResolveAfter: getEnvDuration("ALERT_RESOLVE_AFTER", 2*time.Minute),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "json"),
+
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+
 		CallCenterPort:    getEnv("CALL_CENTER_PORT", "8081"),
 		CustomerAgentPort: getEnv("CUSTOMER_AGENT_PORT", "8082"),
 		DistributorPort:   getEnv("DISTRIBUTOR_PORT", "8083"),
@@ -67,3 +147,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
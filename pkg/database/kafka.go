@@ -2,13 +2,26 @@ package database
 
 import (
 	"call-center-api/models"
+	"call-center-api/pkg/logger"
+	"call-center-api/pkg/metrics"
+	"call-center-api/pkg/tracing"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("call-center-api/kafka")
+
+// correlationHeader carries the correlation ID of the request or call a message belongs to
+const correlationHeader = "correlation_id"
+
 type KafkaProducer struct {
 	producer sarama.SyncProducer
 	topic    string
@@ -42,10 +55,60 @@ func (p *KafkaProducer) PublishIncomingCall(ctx context.Context, call models.Inc
 		Value: sarama.ByteEncoder(data),
 	}
 
+	span := startPublish(ctx, p.topic, msg)
+	start := time.Now()
 	_, _, err = p.producer.SendMessage(msg)
+	metrics.ObserveProduce(p.topic, start, err)
+	tracing.End(span, err)
 	return err
 }
 
+// PublishIncomingCalls sends calls in a single batch and returns a per-call error slice (nil on success)
+func (p *KafkaProducer) PublishIncomingCalls(ctx context.Context, calls []models.IncomingCall) []error {
+	errs := make([]error, len(calls))
+	msgs := make([]*sarama.ProducerMessage, 0, len(calls))
+
+	for i, call := range calls {
+		data, err := json.Marshal(call)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		msgs = append(msgs, &sarama.ProducerMessage{
+			Topic:    p.topic,
+			Key:      sarama.StringEncoder(call.CallID),
+			Value:    sarama.ByteEncoder(data),
+			Metadata: i,
+		})
+	}
+
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	span := startPublish(ctx, p.topic, msgs...)
+	start := time.Now()
+	err := p.producer.SendMessages(msgs)
+	tracing.End(span, err)
+	if err != nil {
+		var producerErrs sarama.ProducerErrors
+		if !errors.As(err, &producerErrs) {
+			for _, msg := range msgs {
+				errs[msg.Metadata.(int)] = err
+			}
+		} else {
+			for _, pe := range producerErrs {
+				errs[pe.Msg.Metadata.(int)] = pe.Err
+			}
+		}
+	}
+
+	for _, msg := range msgs {
+		metrics.ObserveProduce(p.topic, start, errs[msg.Metadata.(int)])
+	}
+	return errs
+}
+
 func (p *KafkaProducer) PublishAssignedCall(ctx context.Context, call models.AssignedCall) error {
 	data, err := json.Marshal(call)
 	if err != nil {
@@ -58,12 +121,16 @@ func (p *KafkaProducer) PublishAssignedCall(ctx context.Context, call models.Ass
 		Value: sarama.ByteEncoder(data),
 	}
 
+	span := startPublish(ctx, msg.Topic, msg)
+	start := time.Now()
 	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.ObserveProduce(msg.Topic, start, err)
+	tracing.End(span, err)
 	if err != nil {
-		fmt.Printf("Error publishing assigned call to Kafka: %v\n", err)
+		slog.ErrorContext(ctx, "Failed to publish assigned call", "call_id", call.CallID, "agent_id", call.AssignedAgentID, "error", err)
 		return err
 	}
-	fmt.Printf("Published assigned call %s to Kafka topic assigned_calls partition %d offset %d\n", call.CallID, partition, offset)
+	slog.DebugContext(ctx, "Published assigned call", "call_id", call.CallID, "agent_id", call.AssignedAgentID, "topic", msg.Topic, "partition", partition, "offset", offset)
 	return nil
 }
 
@@ -75,15 +142,52 @@ func (p *KafkaProducer) PublishMessage(ctx context.Context, key string, value []
 		Value: sarama.ByteEncoder(value),
 	}
 
+	span := startPublish(ctx, p.topic, msg)
+	start := time.Now()
 	partition, offset, err := p.producer.SendMessage(msg)
+	metrics.ObserveProduce(p.topic, start, err)
+	tracing.End(span, err)
 	if err != nil {
-		fmt.Printf("Error publishing message to Kafka: %v\n", err)
+		slog.ErrorContext(ctx, "Failed to publish message", "topic", p.topic, "key", key, "error", err)
 		return err
 	}
-	fmt.Printf("Published message with key '%s' to Kafka topic %s partition %d offset %d\n", key, p.topic, partition, offset)
+	slog.DebugContext(ctx, "Published message", "topic", p.topic, "key", key, "partition", partition, "offset", offset)
 	return nil
 }
 
+// startPublish opens a producer span and carries its trace context and the correlation ID in
+// each message's headers
+func startPublish(ctx context.Context, topic string, msgs ...*sarama.ProducerMessage) trace.Span {
+	ctx, span := tracer.Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.Int("messaging.batch.message_count", len(msgs)),
+		),
+	)
+	correlationID := logger.CorrelationID(ctx)
+	for _, msg := range msgs {
+		tracing.InjectKafka(ctx, msg)
+		if correlationID != "" {
+			msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(correlationHeader), Value: []byte(correlationID)})
+		}
+	}
+	return span
+}
+
+// MessageContext returns a context carrying the trace and correlation ID a message was
+// published with. It is not tied to the consumer session, so processing outlives a rebalance.
+func MessageContext(msg *sarama.ConsumerMessage) context.Context {
+	ctx := tracing.ExtractKafka(context.Background(), msg)
+	for _, header := range msg.Headers {
+		if string(header.Key) == correlationHeader {
+			return logger.WithCorrelationID(ctx, string(header.Value))
+		}
+	}
+	return ctx
+}
+
 func (p *KafkaProducer) Close() error {
 	return p.producer.Close()
 }
@@ -92,6 +196,7 @@ func (p *KafkaProducer) Close() error {
 type KafkaConsumer struct {
 	consumer sarama.ConsumerGroup
 	topic    string
+	groupID  string
 }
 
 func NewKafkaConsumer(brokers []string, topic, groupID string) (*KafkaConsumer, error) {
@@ -107,27 +212,28 @@ func NewKafkaConsumer(brokers []string, topic, groupID string) (*KafkaConsumer,
 	return &KafkaConsumer{
 		consumer: consumer,
 		topic:    topic,
+		groupID:  groupID,
 	}, nil
 }
 
-func (c *KafkaConsumer) ConsumeMessages(ctx context.Context, handler func(models.IncomingCall) error) error {
-	handlerWrapper := &consumerGroupHandler{
-		handler: c.topic,
-		processIncoming: func(call models.IncomingCall) error {
-			return handler(call)
-		},
-	}
+// ConsumeMessages passes each incoming call to handler with a context continuing the trace
+// it was published under
+func (c *KafkaConsumer) ConsumeMessages(ctx context.Context, handler func(context.Context, models.IncomingCall) error) error {
+	handlerWrapper := metrics.ConsumerHandler(c.groupID, &consumerGroupHandler{
+		handler:         c.topic,
+		processIncoming: handler,
+	})
 
 	// Keep consuming until context is canceled
 	for {
 		if err := c.consumer.Consume(ctx, []string{c.topic}, handlerWrapper); err != nil {
 			// Check if it's a context cancellation
 			if ctx.Err() != nil {
-				fmt.Printf("Consumer context canceled for topic %s\n", c.topic)
+				slog.Info("Consumer context canceled", "topic", c.topic)
 				return ctx.Err()
 			}
 			// For other errors, log and return
-			fmt.Printf("Consumer error for topic %s: %v\n", c.topic, err)
+			slog.Error("Consumer error", "topic", c.topic, "error", err)
 			return err
 		}
 
@@ -139,24 +245,24 @@ func (c *KafkaConsumer) ConsumeMessages(ctx context.Context, handler func(models
 	}
 }
 
-func (c *KafkaConsumer) ConsumeAssignedCalls(ctx context.Context, handler func(models.AssignedCall) error) error {
-	handlerWrapper := &consumerGroupHandler{
-		handler: c.topic,
-		processAssigned: func(call models.AssignedCall) error {
-			return handler(call)
-		},
-	}
+// ConsumeAssignedCalls passes each assigned_calls record to handler with a context continuing
+// the trace it was published under
+func (c *KafkaConsumer) ConsumeAssignedCalls(ctx context.Context, handler func(context.Context, models.AssignedCall) error) error {
+	handlerWrapper := metrics.ConsumerHandler(c.groupID, &consumerGroupHandler{
+		handler:         c.topic,
+		processAssigned: handler,
+	})
 
 	// Keep consuming until context is canceled
 	for {
 		if err := c.consumer.Consume(ctx, []string{c.topic}, handlerWrapper); err != nil {
 			// Check if it's a context cancellation
 			if ctx.Err() != nil {
-				fmt.Printf("Consumer context canceled for topic %s\n", c.topic)
+				slog.Info("Consumer context canceled", "topic", c.topic)
 				return ctx.Err()
 			}
 			// For other errors, log and return
-			fmt.Printf("Consumer error for topic %s: %v\n", c.topic, err)
+			slog.Error("Consumer error", "topic", c.topic, "error", err)
 			return err
 		}
 
@@ -170,61 +276,167 @@ func (c *KafkaConsumer) ConsumeAssignedCalls(ctx context.Context, handler func(m
 
 // ConsumeRawMessages consumes raw messages with access to key and value
 func (c *KafkaConsumer) ConsumeRawMessages(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
-	return c.consumer.Consume(ctx, topics, handler)
+	return c.consumer.Consume(ctx, topics, metrics.ConsumerHandler(c.groupID, handler))
 }
 
 func (c *KafkaConsumer) Close() error {
 	return c.consumer.Close()
 }
 
+// KafkaTail reads every partition of its topics from the newest offset without a consumer
+// group. It suits instances that each need every event from now on, such as live views that
+// load their starting state elsewhere; nothing is committed and no group is left behind.
+type KafkaTail struct {
+	consumer sarama.Consumer
+	topics   []string
+	name     string
+}
+
+// NewKafkaTail creates a tail over topics; name labels its metrics
+func NewKafkaTail(brokers []string, name string, topics ...string) (*KafkaTail, error) {
+	consumer, err := sarama.NewConsumer(brokers, sarama.NewConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+
+	return &KafkaTail{
+		consumer: consumer,
+		topics:   topics,
+		name:     name,
+	}, nil
+}
+
+// Consume passes each new message on any partition of the tail's topics to handler, one at
+// a time, until ctx is canceled
+func (t *KafkaTail) Consume(ctx context.Context, handler func(*sarama.ConsumerMessage)) error {
+	var partitions []sarama.PartitionConsumer
+	defer func() {
+		for _, pc := range partitions {
+			pc.AsyncClose()
+		}
+	}()
+
+	type received struct {
+		msg           *sarama.ConsumerMessage
+		highWaterMark int64
+	}
+	messages := make(chan received)
+	for _, topic := range t.topics {
+		ids, err := t.consumer.Partitions(topic)
+		if err != nil {
+			return fmt.Errorf("failed to list partitions of %s: %w", topic, err)
+		}
+		for _, id := range ids {
+			pc, err := t.consumer.ConsumePartition(topic, id, sarama.OffsetNewest)
+			if err != nil {
+				return fmt.Errorf("failed to consume %s/%d: %w", topic, id, err)
+			}
+			partitions = append(partitions, pc)
+
+			go func(pc sarama.PartitionConsumer) {
+				for msg := range pc.Messages() {
+					select {
+					case messages <- received{msg, pc.HighWaterMarkOffset()}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(pc)
+			go func(pc sarama.PartitionConsumer) {
+				for err := range pc.Errors() {
+					slog.Error("Consumer error", "topic", err.Topic, "partition", err.Partition, "error", err.Err)
+				}
+			}(pc)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case r := <-messages:
+			handler(r.msg)
+			metrics.ObserveConsumed(t.name, r.msg, r.highWaterMark)
+		}
+	}
+}
+
+func (t *KafkaTail) Close() error {
+	return t.consumer.Close()
+}
+
 // consumerGroupHandler implements sarama.ConsumerGroupHandler
 type consumerGroupHandler struct {
 	handler         string
-	processIncoming func(models.IncomingCall) error
-	processAssigned func(models.AssignedCall) error
+	processIncoming func(context.Context, models.IncomingCall) error
+	processAssigned func(context.Context, models.AssignedCall) error
 }
 
 func (h *consumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
-	fmt.Printf("Consumer group handler setup - MemberID: %s, GenerationID: %d\n", session.MemberID(), session.GenerationID())
+	slog.Info("Consumer group handler setup", "topic", h.handler, "member_id", session.MemberID(), "generation_id", session.GenerationID())
 	return nil
 }
 
 func (h *consumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error {
-	fmt.Printf("Consumer group handler cleanup\n")
+	slog.Info("Consumer group handler cleanup", "topic", h.handler)
 	return nil
 }
 
 func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	fmt.Printf("Starting ConsumeClaim for topic %s partition %d at offset %d\n", claim.Topic(), claim.Partition(), claim.InitialOffset())
+	slog.Debug("Starting ConsumeClaim", "topic", claim.Topic(), "partition", claim.Partition(), "offset", claim.InitialOffset())
 
 	for message := range claim.Messages() {
-		fmt.Printf("Received Kafka message from topic %s partition %d offset %d\n", message.Topic, message.Partition, message.Offset)
-
-		// Try to unmarshal as AssignedCall first (has more fields)
-		var assignedCall models.AssignedCall
-		if err := json.Unmarshal(message.Value, &assignedCall); err == nil && assignedCall.AssignedAgentID != "" {
-			fmt.Printf("Decoded as AssignedCall: %s for agent %s\n", assignedCall.CallID, assignedCall.AssignedAgentID)
-			if h.processAssigned != nil {
-				if err := h.processAssigned(assignedCall); err != nil {
-					fmt.Printf("Error processing assigned call: %v\n", err)
-				}
+		h.process(message)
+		session.MarkMessage(message, "")
+	}
+	slog.Debug("ConsumeClaim loop exited", "topic", claim.Topic(), "partition", claim.Partition())
+	return nil
+}
+
+// process decodes one message and hands it on inside a consumer span that continues the
+// producer's trace
+func (h *consumerGroupHandler) process(message *sarama.ConsumerMessage) {
+	ctx, span := tracer.Start(MessageContext(message), message.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", message.Topic),
+			attribute.Int("messaging.destination.partition.id", int(message.Partition)),
+			attribute.Int64("messaging.kafka.offset", message.Offset),
+		),
+	)
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	slog.DebugContext(ctx, "Received Kafka message", "topic", message.Topic, "partition", message.Partition, "offset", message.Offset)
+
+	// Try to unmarshal as AssignedCall first (has more fields)
+	var assignedCall models.AssignedCall
+	if err = json.Unmarshal(message.Value, &assignedCall); err == nil && assignedCall.AssignedAgentID != "" {
+		span.SetAttributes(attribute.String("call.id", assignedCall.CallID))
+		ctx = logger.WithAgentID(logger.WithCallID(ctx, assignedCall.CallID), assignedCall.AssignedAgentID)
+		slog.DebugContext(ctx, "Decoded assigned call", "status", assignedCall.Status)
+		if h.processAssigned != nil {
+			if err = h.processAssigned(ctx, assignedCall); err != nil {
+				slog.ErrorContext(ctx, "Failed to process assigned call", "error", err)
 			}
-		} else {
-			// Try to unmarshal as IncomingCall
-			var incomingCall models.IncomingCall
-			if err := json.Unmarshal(message.Value, &incomingCall); err == nil && incomingCall.CallID != "" {
-				fmt.Printf("Decoded as IncomingCall: %s\n", incomingCall.CallID)
-				if h.processIncoming != nil {
-					if err := h.processIncoming(incomingCall); err != nil {
-						fmt.Printf("Error processing incoming call: %v\n", err)
-					}
-				}
-			} else {
-				fmt.Printf("Error unmarshaling message: %v, data: %s\n", err, string(message.Value))
+		}
+		return
+	}
+
+	// Try to unmarshal as IncomingCall
+	var incomingCall models.IncomingCall
+	if err = json.Unmarshal(message.Value, &incomingCall); err == nil && incomingCall.CallID != "" {
+		span.SetAttributes(attribute.String("call.id", incomingCall.CallID))
+		ctx = logger.WithCallID(ctx, incomingCall.CallID)
+		slog.DebugContext(ctx, "Decoded incoming call", "queue", incomingCall.Queue)
+		if h.processIncoming != nil {
+			if err = h.processIncoming(ctx, incomingCall); err != nil {
+				slog.ErrorContext(ctx, "Failed to process incoming call", "error", err)
 			}
 		}
-		session.MarkMessage(message, "")
+		return
 	}
-	fmt.Printf("ConsumeClaim loop exited for topic %s partition %d\n", claim.Topic(), claim.Partition())
-	return nil
+
+	slog.ErrorContext(ctx, "Failed to decode Kafka message", "topic", message.Topic, "offset", message.Offset, "error", err, "data", string(message.Value))
 }
@@ -24,6 +24,22 @@ func NewPostgres(host, user, password, dbname, port string) (*gorm.DB, error) {
 	if err := db.AutoMigrate(
 		&models.Agent{},
 		&models.AssignedCall{},
+		&models.APIKey{},
+		&models.BlockedNumber{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.Callback{},
+		&models.BusinessHours{},
+		&models.Holiday{},
+		&models.Queue{},
+		&models.QueueMembership{},
+		&models.OverflowRule{},
+		&models.CallEvent{},
+		&models.AuxCode{},
+		&models.AgentStateInterval{},
+		&models.AgentSession{},
+		&models.IntervalStat{},
+		&models.Alert{},
 	); err != nil {
 		return nil, err
 	}
@@ -1,16 +1,124 @@
 package logger
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
-var (
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
+type contextKey int
+
+const (
+	correlationIDKey contextKey = iota
+	callIDKey
+	agentIDKey
 )
 
-func Init() {
-	InfoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// Init makes a structured logger the slog default for the service. level is debug, info,
+// warn or error (default info); format is json (default) or text. Every record carries the
+// service name plus any correlation, call, agent and trace IDs found in its context.
+func Init(service, level, format string) {
+	opts := &slog.HandlerOptions{Level: parseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}).With("service", service))
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Fatal logs at error level and exits, for startup failures
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// WithCorrelationID tags ctx with the ID tying together everything done for one request or call
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// CorrelationID returns the correlation ID on ctx, or "" if there is none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// WithCallID tags ctx with the call being handled
+func WithCallID(ctx context.Context, callID string) context.Context {
+	if callID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, callIDKey, callID)
+}
+
+// WithAgentID tags ctx with the agent being handled
+func WithAgentID(ctx context.Context, agentID string) context.Context {
+	if agentID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, agentIDKey, agentID)
+}
+
+// contextHandler adds the IDs carried by a record's context, unless the call site already
+// logged them explicitly
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		return h.Handler.Handle(ctx, r)
+	}
+
+	present := make(map[string]bool, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		present[a.Key] = true
+		return true
+	})
+	add := func(key string, value any) {
+		if s, ok := value.(string); ok && s != "" && !present[key] {
+			r.AddAttrs(slog.String(key, s))
+		}
+	}
+
+	add("correlation_id", ctx.Value(correlationIDKey))
+	add("call_id", ctx.Value(callIDKey))
+	add("agent_id", ctx.Value(agentIDKey))
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		add("trace_id", sc.TraceID().String())
+		add("span_id", sc.SpanID().String())
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextHandler(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		args []any
+		want map[string]string // attributes expected on the record; "" means absent
+	}{
+		{
+			name: "ids from context",
+			ctx:  WithAgentID(WithCallID(WithCorrelationID(context.Background(), "req-1"), "c1"), "a1"),
+			want: map[string]string{"correlation_id": "req-1", "call_id": "c1", "agent_id": "a1", "trace_id": ""},
+		},
+		{
+			name: "explicit attribute wins",
+			ctx:  WithCallID(context.Background(), "c1"),
+			args: []any{"call_id", "c2"},
+			want: map[string]string{"call_id": "c2"},
+		},
+		{
+			name: "empty ids are not tagged",
+			ctx:  WithCallID(WithCorrelationID(context.Background(), ""), ""),
+			want: map[string]string{"correlation_id": "", "call_id": ""},
+		},
+		{
+			name: "trace ids",
+			ctx:  WithCorrelationID(traced, "req-2"),
+			want: map[string]string{"correlation_id": "req-2", "trace_id": traceID.String(), "span_id": spanID.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			log := slog.New(contextHandler{slog.NewJSONHandler(&buf, nil)}).With("service", "test")
+			log.InfoContext(tt.ctx, "Handled", tt.args...)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid record %q: %v", buf.String(), err)
+			}
+			if record["service"] != "test" {
+				t.Errorf("service = %v, want test", record["service"])
+			}
+			for key, want := range tt.want {
+				got, ok := record[key]
+				if want == "" {
+					if ok {
+						t.Errorf("%s = %v, want absent", key, got)
+					}
+					continue
+				}
+				if got != want {
+					t.Errorf("%s = %v, want %s", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"Error":   slog.LevelError,
+		"":        slog.LevelInfo,
+		"verbose": slog.LevelInfo,
+	}
+	for level, want := range tests {
+		if got := parseLevel(level); got != want {
+			t.Errorf("parseLevel(%q) = %v, want %v", level, got, want)
+		}
+	}
+}
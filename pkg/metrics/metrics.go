@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	KafkaMessagesProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_produced_total",
+		Help: "Messages sent to Kafka by topic and result (ok or error).",
+	}, []string{"topic", "result"})
+
+	KafkaProduceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_produce_duration_seconds",
+		Help:    "Time for Kafka to acknowledge a send.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	KafkaMessagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "kafka_messages_consumed_total",
+		Help: "Messages processed from Kafka by topic and consumer group.",
+	}, []string{"topic", "group"})
+
+	KafkaConsumeLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "kafka_consume_latency_seconds",
+		Help:    "Time from a message being produced to it being processed.",
+		Buckets: []float64{.005, .01, .05, .1, .5, 1, 5, 15, 60, 300},
+	}, []string{"topic", "group"})
+
+	KafkaConsumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kafka_consumer_lag",
+		Help: "Messages behind the partition's high water mark after the last processed message.",
+	}, []string{"topic", "partition", "group"})
+
+	AssignmentLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "call_assignment_latency_seconds",
+		Help:    "Time from a call arriving to it being assigned to an agent.",
+		Buckets: []float64{1, 5, 10, 20, 30, 60, 120, 300, 600},
+	}, []string{"queue"})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "queue_depth",
+		Help: "Calls waiting in each queue.",
+	}, []string{"queue"})
+
+	AgentsByState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "agents_by_state",
+		Help: "Agents in each state, including on_call and offline.",
+	}, []string{"state"})
+
+	WebSocketConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "websocket_connections_active",
+		Help: "Open WebSocket connections by endpoint.",
+	}, []string{"endpoint"})
+)
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+// Middleware records request latency labeled with the route pattern rather than the raw
+// path, so IDs in URLs do not create a series each
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler has not written the response yet
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+
+		HTTPRequestDuration.
+			WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// ObserveProduce records the outcome and latency of a Kafka send
+func ObserveProduce(topic string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	KafkaMessagesProduced.WithLabelValues(topic, result).Inc()
+	KafkaProduceDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+}
+
+// ConsumerHandler wraps a consumer group handler so every message it marks as processed is
+// counted, with its end-to-end latency and the partition's remaining lag
+func ConsumerHandler(group string, handler sarama.ConsumerGroupHandler) sarama.ConsumerGroupHandler {
+	return &meteredHandler{ConsumerGroupHandler: handler, group: group}
+}
+
+type meteredHandler struct {
+	sarama.ConsumerGroupHandler
+	group string
+}
+
+func (h *meteredHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	return h.ConsumerGroupHandler.ConsumeClaim(&meteredSession{ConsumerGroupSession: session, claim: claim, group: h.group}, claim)
+}
+
+// meteredSession observes MarkMessage, which handlers call once a message is processed
+type meteredSession struct {
+	sarama.ConsumerGroupSession
+	claim sarama.ConsumerGroupClaim
+	group string
+}
+
+func (s *meteredSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.ConsumerGroupSession.MarkMessage(msg, metadata)
+	ObserveConsumed(s.group, msg, s.claim.HighWaterMarkOffset())
+}
+
+// ObserveConsumed records a processed message, its end-to-end latency and the remaining lag
+// of its partition given the partition's high water mark
+func ObserveConsumed(group string, msg *sarama.ConsumerMessage, highWaterMark int64) {
+	KafkaMessagesConsumed.WithLabelValues(msg.Topic, group).Inc()
+	if !msg.Timestamp.IsZero() {
+		KafkaConsumeLatency.WithLabelValues(msg.Topic, group).Observe(time.Since(msg.Timestamp).Seconds())
+	}
+	lag := highWaterMark - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	KafkaConsumerLag.WithLabelValues(msg.Topic, strconv.Itoa(int(msg.Partition)), group).Set(float64(lag))
+}
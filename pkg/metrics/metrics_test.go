@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestMiddleware(t *testing.T) {
+	app := fiber.New()
+	app.Use(Middleware())
+	app.Get("/calls/:id", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/missing/:id", func(c *fiber.Ctx) error { return fiber.ErrNotFound })
+	app.Get("/broken", func(c *fiber.Ctx) error { return errors.New("boom") })
+
+	tests := []struct {
+		path   string
+		route  string
+		status string
+	}{
+		{path: "/calls/c1", route: "/calls/:id", status: "200"},
+		{path: "/calls/c2", route: "/calls/:id", status: "200"},
+		{path: "/missing/m1", route: "/missing/:id", status: "404"},
+		{path: "/broken", route: "/broken", status: "500"},
+	}
+
+	for _, tt := range tests {
+		if _, err := app.Test(httptest.NewRequest("GET", tt.path, nil)); err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+	}
+
+	// Requests are labeled by route, so both call IDs share one series
+	want := map[[2]string]int{
+		{"/calls/:id", "200"}:   2,
+		{"/missing/:id", "404"}: 1,
+		{"/broken", "500"}:      1,
+	}
+	for labels, count := range want {
+		if n := sampleCount(t, HTTPRequestDuration.WithLabelValues("GET", labels[0], labels[1])); n != count {
+			t.Errorf("%s %s observed %d times, want %d", labels[0], labels[1], n, count)
+		}
+	}
+	if n := sampleCount(t, HTTPRequestDuration.WithLabelValues("GET", "/calls/c1", "200")); n != 0 {
+		t.Errorf("raw path recorded as a route %d times", n)
+	}
+}
+
+// sampleCount returns how many observations a histogram series holds
+func sampleCount(t *testing.T, observer prometheus.Observer) int {
+	t.Helper()
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return int(metric.GetHistogram().GetSampleCount())
+}
+
+func TestObserveProduce(t *testing.T) {
+	before := testutil.ToFloat64(KafkaMessagesProduced.WithLabelValues("produce_test", "error"))
+	ObserveProduce("produce_test", time.Now(), errors.New("broker down"))
+	ObserveProduce("produce_test", time.Now(), nil)
+
+	if got := testutil.ToFloat64(KafkaMessagesProduced.WithLabelValues("produce_test", "error")); got != before+1 {
+		t.Errorf("errors = %v, want %v", got, before+1)
+	}
+	if got := testutil.ToFloat64(KafkaMessagesProduced.WithLabelValues("produce_test", "ok")); got != 1 {
+		t.Errorf("ok = %v, want 1", got)
+	}
+}
+
+func TestObserveConsumed(t *testing.T) {
+	tests := []struct {
+		name          string
+		offset        int64
+		highWaterMark int64
+		wantLag       float64
+	}{
+		{name: "behind", offset: 10, highWaterMark: 15, wantLag: 4},
+		{name: "caught up", offset: 14, highWaterMark: 15, wantLag: 0},
+		// The high water mark can be read before the message it covers
+		{name: "stale high water mark", offset: 20, highWaterMark: 15, wantLag: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &sarama.ConsumerMessage{Topic: "consume_test", Partition: 3, Offset: tt.offset, Timestamp: time.Now()}
+			ObserveConsumed("group", msg, tt.highWaterMark)
+
+			if got := testutil.ToFloat64(KafkaConsumerLag.WithLabelValues("consume_test", "3", "group")); got != tt.wantLag {
+				t.Errorf("lag = %v, want %v", got, tt.wantLag)
+			}
+		})
+	}
+	if got := testutil.ToFloat64(KafkaMessagesConsumed.WithLabelValues("consume_test", "group")); got != float64(len(tests)) {
+		t.Errorf("consumed = %v, want %d", got, len(tests))
+	}
+}
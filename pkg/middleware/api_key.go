@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"call-center-api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyAuthenticator resolves a plaintext API key to the integration it belongs to
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*models.APIKey, error)
+}
+
+func APIKeyMiddleware(auth APIKeyAuthenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-API-Key")
+		if key == "" {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "API key missing",
+			})
+		}
+
+		apiKey, err := auth.AuthenticateAPIKey(key)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Invalid API key",
+			})
+		}
+
+		c.Locals("api_key_id", apiKey.ID)
+		c.Locals("integration", apiKey.Integration)
+		return c.Next()
+	}
+}
+
+// SessionChecker reports whether an agent's login session is still open
+type SessionChecker interface {
+	SessionActive(sessionID string) bool
+}
+
+// ActiveSession rejects agent tokens whose session has ended, e.g. after logout; use after
+// AuthMiddleware. Admin tokens carry no session and are let through.
+func ActiveSession(sessions SessionChecker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if agentID, _ := c.Locals("agent_id").(string); agentID == "admin" {
+			return c.Next()
+		}
+		sessionID, _ := c.Locals("session_id").(string)
+		if sessionID == "" || !sessions.SessionActive(sessionID) {
+			return c.Status(401).JSON(fiber.Map{
+				"success": false,
+				"message": "Session ended",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// AdminOnly rejects requests whose JWT was not issued to the admin; use after AuthMiddleware
+func AdminOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if agentID, _ := c.Locals("agent_id").(string); agentID != "admin" {
+			return c.Status(403).JSON(fiber.Map{
+				"success": false,
+				"message": "Admin access required",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -9,7 +9,8 @@ import (
 )
 
 type Claims struct {
-	AgentID string `json:"agent_id"`
+	AgentID   string `json:"agent_id"`
+	SessionID string `json:"session_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -46,6 +47,7 @@ func AuthMiddleware() fiber.Handler {
 
 		if claims, ok := token.Claims.(*Claims); ok && token.Valid {
 			c.Locals("agent_id", claims.AgentID)
+			c.Locals("session_id", claims.SessionID)
 			return c.Next()
 		}
 
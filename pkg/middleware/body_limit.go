@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"io"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than limit. It is meant for apps started with
+// StreamRequestBody, where bodies over the server limit arrive as a stream instead of being
+// refused; the streamed paths skip it and read their body incrementally with their own cap.
+func BodyLimit(limit int, streamed ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if slices.Contains(streamed, c.Path()) {
+			return c.Next()
+		}
+
+		if c.Request().Header.ContentLength() > limit {
+			return bodyTooLarge(c)
+		}
+		if c.Request().IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(c.Request().BodyStream(), int64(limit)+1))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"success": false,
+					"message": "Failed to read request body",
+				})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c)
+			}
+			c.Request().SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
+
+func bodyTooLarge(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"success": false,
+		"message": "Request body too large",
+	})
+}
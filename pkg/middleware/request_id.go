@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"call-center-api/pkg/logger"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the correlation ID in and out of every HTTP request
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID puts the caller's X-Request-ID, or a new one, on the request context as its
+// correlation ID and echoes it in the response
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+
+		c.Set(RequestIDHeader, id)
+		c.SetUserContext(logger.WithCorrelationID(c.UserContext(), id))
+		return c.Next()
+	}
+}
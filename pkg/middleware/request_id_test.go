@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"call-center-api/pkg/logger"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "caller's id is kept", incoming: "req-123", keep: true},
+		{name: "missing id is generated"},
+		{name: "oversized id is replaced", incoming: strings.Repeat("x", 129)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(RequestID())
+			var seen string
+			app.Get("/", func(c *fiber.Ctx) error {
+				seen = logger.CorrelationID(c.UserContext())
+				return nil
+			})
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			echoed := resp.Header.Get(RequestIDHeader)
+			if echoed == "" || echoed != seen {
+				t.Fatalf("echoed %q, handler saw %q; want the same non-empty id", echoed, seen)
+			}
+			if tt.keep != (echoed == tt.incoming) {
+				t.Errorf("id = %q, incoming %q, keep %v", echoed, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package phone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// Error codes returned in ValidationError.Code
+const (
+	CodeEmpty         = "empty"
+	CodeUnparseable   = "unparseable"
+	CodeInvalidNumber = "invalid_number"
+)
+
+// ValidationError describes why a phone number was rejected
+type ValidationError struct {
+	Code  string
+	Input string
+}
+
+func (e *ValidationError) Error() string {
+	switch e.Code {
+	case CodeEmpty:
+		return "phone number is required"
+	case CodeUnparseable:
+		return fmt.Sprintf("phone number %q could not be parsed", e.Input)
+	default:
+		return fmt.Sprintf("phone number %q is not a valid number", e.Input)
+	}
+}
+
+// Normalize converts a number to E.164, interpreting numbers without a
+// country code as belonging to defaultRegion (ISO 3166-1 alpha-2, e.g. "US").
+func Normalize(raw, defaultRegion string) (string, error) {
+	input := strings.TrimSpace(raw)
+	if input == "" {
+		return "", &ValidationError{Code: CodeEmpty, Input: raw}
+	}
+
+	number, err := phonenumbers.Parse(input, strings.ToUpper(defaultRegion))
+	if err != nil {
+		return "", &ValidationError{Code: CodeUnparseable, Input: raw}
+	}
+
+	if !phonenumbers.IsValidNumber(number) {
+		return "", &ValidationError{Code: CodeInvalidNumber, Input: raw}
+	}
+
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
@@ -0,0 +1,48 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		region string
+		want   string
+		code   string
+	}{
+		{name: "national number in default region", raw: "(650) 253-0000", region: "US", want: "+16502530000"},
+		{name: "lowercase region", raw: "650 253 0000", region: "us", want: "+16502530000"},
+		{name: "international format ignores region", raw: "+44 20 7031 3000", region: "US", want: "+442070313000"},
+		{name: "national number in other region", raw: "020 7031 3000", region: "GB", want: "+442070313000"},
+		{name: "surrounding whitespace", raw: "  +16502530000 ", region: "US", want: "+16502530000"},
+		{name: "empty", raw: "", region: "US", code: CodeEmpty},
+		{name: "only whitespace", raw: "   ", region: "US", code: CodeEmpty},
+		{name: "letters", raw: "not a number", region: "US", code: CodeUnparseable},
+		{name: "too short", raw: "12345", region: "US", code: CodeInvalidNumber},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.raw, tt.region)
+			if tt.code != "" {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("Normalize(%q) error = %v, want ValidationError", tt.raw, err)
+				}
+				if validationErr.Code != tt.code {
+					t.Errorf("Normalize(%q) code = %q, want %q", tt.raw, validationErr.Code, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q) error = %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel"
+)
+
+// InjectKafka writes ctx's trace context into the message headers
+func InjectKafka(ctx context.Context, msg *sarama.ProducerMessage) {
+	otel.GetTextMapPropagator().Inject(ctx, producerHeaders{msg})
+}
+
+// ExtractKafka continues the trace carried in the message headers, if any, on top of ctx
+func ExtractKafka(ctx context.Context, msg *sarama.ConsumerMessage) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, consumerHeaders(msg.Headers))
+}
+
+// producerHeaders adapts outgoing Kafka headers to propagation.TextMapCarrier
+type producerHeaders struct {
+	msg *sarama.ProducerMessage
+}
+
+func (h producerHeaders) Get(key string) string {
+	for _, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func (h producerHeaders) Set(key, value string) {
+	for i, header := range h.msg.Headers {
+		if string(header.Key) == key {
+			h.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	h.msg.Headers = append(h.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+func (h producerHeaders) Keys() []string {
+	keys := make([]string, len(h.msg.Headers))
+	for i, header := range h.msg.Headers {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
+
+// consumerHeaders adapts received Kafka headers to propagation.TextMapCarrier
+type consumerHeaders []*sarama.RecordHeader
+
+func (h consumerHeaders) Get(key string) string {
+	for _, header := range h {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set is unused; received headers are read-only
+func (h consumerHeaders) Set(string, string) {}
+
+func (h consumerHeaders) Keys() []string {
+	keys := make([]string, len(h))
+	for i, header := range h {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestKafkaPropagation(t *testing.T) {
+	tracer, _ := newRecorder(t)
+	ctx, span := tracer.Start(context.Background(), "publish")
+	defer span.End()
+
+	// A retried send is injected again and must not carry two traceparent headers
+	msg := &sarama.ProducerMessage{
+		Topic:   "incoming_calls",
+		Headers: []sarama.RecordHeader{{Key: []byte("correlation_id"), Value: []byte("req-1")}},
+	}
+	InjectKafka(ctx, msg)
+	InjectKafka(ctx, msg)
+
+	count := 0
+	for _, header := range msg.Headers {
+		if string(header.Key) == "traceparent" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("%d traceparent headers, want 1", count)
+	}
+	if len(msg.Headers) != 2 {
+		t.Errorf("headers = %d, want the existing one kept plus traceparent", len(msg.Headers))
+	}
+
+	received := &sarama.ConsumerMessage{Topic: msg.Topic}
+	for i := range msg.Headers {
+		received.Headers = append(received.Headers, &msg.Headers[i])
+	}
+	got := trace.SpanContextFromContext(ExtractKafka(context.Background(), received))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("consumer continued %v/%v, want %v/%v", got.TraceID(), got.SpanID(), span.SpanContext().TraceID(), span.SpanContext().SpanID())
+	}
+
+	untraced := trace.SpanContextFromContext(ExtractKafka(context.Background(), &sarama.ConsumerMessage{}))
+	if untraced.IsValid() {
+		t.Errorf("extracted %v from a message without trace headers", untraced)
+	}
+}
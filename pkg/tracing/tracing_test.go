@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecorder returns a tracer whose ended spans are kept by the recorder, and installs the
+// propagators Init would
+func newRecorder(t *testing.T) (trace.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	if _, err := Init(context.Background(), "test", ""); err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider.Tracer("test"), recorder
+}
+
+func TestInjectExtract(t *testing.T) {
+	tracer, _ := newRecorder(t)
+	ctx, span := tracer.Start(context.Background(), "publish")
+	defer span.End()
+
+	// A queued call stores its trace context as a map until it is assigned
+	carrier := map[string]string{}
+	Inject(ctx, carrier)
+	if carrier["traceparent"] == "" {
+		t.Fatalf("carrier = %v, want a traceparent", carrier)
+	}
+
+	got := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	if got.TraceID() != span.SpanContext().TraceID() || got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("extracted %v/%v, want %v/%v", got.TraceID(), got.SpanID(), span.SpanContext().TraceID(), span.SpanContext().SpanID())
+	}
+	if !got.IsRemote() {
+		t.Error("extracted span context is not remote")
+	}
+
+	if empty := trace.SpanContextFromContext(Extract(context.Background(), nil)); empty.IsValid() {
+		t.Errorf("extracted %v from no carrier", empty)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	tracer, recorder := newRecorder(t)
+
+	_, ok := tracer.Start(context.Background(), "ok")
+	End(ok, nil)
+	_, failed := tracer.Start(context.Background(), "failed")
+	End(failed, errors.New("kafka unavailable"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ended %d spans, want 2", len(spans))
+	}
+	if status := spans[0].Status(); status.Code != codes.Unset {
+		t.Errorf("ok span status = %v, want unset", status)
+	}
+	if status := spans[1].Status(); status.Code != codes.Error || status.Description != "kafka unavailable" {
+		t.Errorf("failed span status = %v, want the error", status)
+	}
+	if events := spans[1].Events(); len(events) != 1 || events[0].Name != "exception" {
+		t.Errorf("failed span events = %v, want the recorded error", events)
+	}
+}